@@ -0,0 +1,132 @@
+package audit_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/audit"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+)
+
+func TestFileSinkSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := audit.NewFileSink(path, audit.WithMaxSize(512), audit.WithMaxBackups(10))
+	require.NoError(t, err)
+
+	logger := audit.NewLogger("memory", sink)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		client := "certbot."
+		name := "_acme-challenge.example.com."
+		if i%2 == 1 {
+			client = "dhcp."
+			name = "host.lan."
+		}
+
+		logger.Log(audit.Record{
+			Time:   start.Add(time.Duration(i) * time.Hour),
+			Client: client,
+			Names:  []string{name},
+			Before: []string{name + "\t60\tIN\tTXT\t\"old\""},
+			After:  []string{name + "\t60\tIN\tTXT\t\"new\""},
+			Result: audit.ResultOK,
+		})
+	}
+	require.NoError(t, logger.Close())
+
+	_, err = os.Stat(path + ".1")
+	require.NoError(t, err, "log must be rotated")
+
+	all, err := audit.Search(path, audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 10)
+	for i, rec := range all {
+		require.Equal(t, start.Add(time.Duration(i)*time.Hour), rec.Time, "records must be ordered")
+		require.Equal(t, "memory", rec.Upstream)
+		require.NotEmpty(t, rec.ID)
+	}
+
+	byClient, err := audit.Search(path, audit.Filter{Client: "CERTBOT."})
+	require.NoError(t, err)
+	require.Len(t, byClient, 5)
+
+	byName, err := audit.Search(path, audit.Filter{Name: "example.com"})
+	require.NoError(t, err)
+	require.Len(t, byName, 5)
+	require.Equal(t, "certbot.", byName[0].Client)
+
+	byTime, err := audit.Search(path, audit.Filter{
+		Name:  "lan.",
+		Since: start.Add(2 * time.Hour),
+		Until: start.Add(5 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, byTime, 2)
+
+	require.Equal(t,
+		[]string{"- _acme-challenge.example.com.\t60\tIN\tTXT\t\"old\"", "+ _acme-challenge.example.com.\t60\tIN\tTXT\t\"new\""},
+		audit.Diff(all[0]),
+	)
+}
+
+func TestNilLogger(t *testing.T) {
+	var logger *audit.Logger
+	require.False(t, logger.Enabled())
+	logger.Log(audit.Record{})
+	require.NoError(t, logger.Close())
+}
+
+func TestSnapshot(t *testing.T) {
+	upc := &countingUpstream{Upstream: umemory.NewUpstream()}
+	ctx := context.Background()
+	tx, err := upc.Tx(ctx)
+	require.NoError(t, err)
+	for _, s := range []string{
+		`a.example.com. 60 IN TXT "a"`,
+		`a.example.com. 60 IN A 10.0.0.1`,
+		`b.example.com. 60 IN TXT "b"`,
+		`c.example.com. 60 IN TXT "c"`,
+	} {
+		rr, err := dns.NewRR(s)
+		require.NoError(t, err)
+		rule, err := upstream.RuleFromRR(rr)
+		require.NoError(t, err)
+		require.NoError(t, tx.Append(rule))
+	}
+	require.NoError(t, tx.Commit(ctx))
+
+	// touched RRsets are matched locally within the single query
+	snap, err := audit.Snapshot(ctx, upc, []upstream.Rule{
+		{Name: "a.example.com.", Type: dns.TypeTXT},
+		{Name: "b.example.com.", Type: dns.TypeNone},
+		{Name: "d.example.com.", Type: dns.TypeTXT},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, upc.queries)
+	require.Equal(t, []string{
+		"a.example.com.\t0\tIN\tTXT\t\"a\"",
+		"b.example.com.\t0\tIN\tTXT\t\"b\"",
+	}, snap)
+
+	snap, err = audit.Snapshot(ctx, upc, nil)
+	require.NoError(t, err)
+	require.Empty(t, snap)
+	require.Equal(t, 1, upc.queries)
+}
+
+type countingUpstream struct {
+	upstream.Upstream
+	queries int
+}
+
+func (u *countingUpstream) Query(ctx context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	u.queries++
+	return u.Upstream.Query(ctx, q)
+}
@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+const (
+	DefaultMaxSize    = 100 << 20
+	DefaultMaxBackups = 5
+)
+
+var _ Sink = (*FileSink)(nil)
+
+// FileSink writes JSON lines into the file, rotating it into path.1 ... path.N once it grows over the max size.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+	mu         sync.Mutex
+}
+
+type FileOption func(*FileSink)
+
+// WithMaxSize sets the file size in bytes which triggers the rotation.
+func WithMaxSize(size int64) FileOption {
+	return func(s *FileSink) {
+		if size > 0 {
+			s.maxSize = size
+		}
+	}
+}
+
+// WithMaxBackups sets how many rotated files are kept.
+func WithMaxBackups(n int) FileOption {
+	return func(s *FileSink) {
+		if n > 0 {
+			s.maxBackups = n
+		}
+	}
+}
+
+func NewFileSink(path string, opts ...FileOption) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    DefaultMaxSize,
+		maxBackups: DefaultMaxBackups,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) Write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+
+	s.f = f
+	s.size = fi.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(backupPath(s.path, i), backupPath(s.path, i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := os.Rename(s.path, backupPath(s.path, 1)); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Search reads the audit log with its rotated files and returns matched records, the oldest first.
+func Search(path string, filter Filter) ([]Record, error) {
+	var files []string
+	for i := 1; ; i++ {
+		backup := backupPath(path, i)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+
+		files = append([]string{backup}, files...)
+	}
+	files = append(files, path)
+
+	var out []Record
+	for _, file := range files {
+		recs, err := searchFile(file, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, recs...)
+	}
+
+	return out, nil
+}
+
+func searchFile(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var out []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid record: %w", path, lineNo, err)
+		}
+
+		if filter.Match(rec) {
+			out = append(out, rec)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// Sink stores audit records, e.g. into the file or syslog.
+type Sink interface {
+	Write(rec Record) error
+	Close() error
+}
+
+// Logger writes every record into all the sinks, nil Logger drops records.
+type Logger struct {
+	upstream string
+	sinks    []Sink
+	log      zerolog.Logger
+}
+
+// NewLogger creates the audit logger, upstream is the upstream name stamped into every record.
+func NewLogger(upstream string, sinks ...Sink) *Logger {
+	return &Logger{
+		upstream: upstream,
+		sinks:    sinks,
+		log: log.With().
+			Str("source", "audit").
+			Logger(),
+	}
+}
+
+// Enabled reports whether records are written somewhere, so callers may skip collecting them.
+func (l *Logger) Enabled() bool {
+	return l != nil && len(l.sinks) > 0
+}
+
+// Log fills the record ID, time and upstream, then writes it. Sink errors are logged, but never fail the caller,
+// because the change is already committed at this point.
+func (l *Logger) Log(rec Record) {
+	if !l.Enabled() {
+		return
+	}
+
+	if rec.ID == "" {
+		rec.ID = NewID()
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+
+	if rec.Upstream == "" {
+		rec.Upstream = l.upstream
+	}
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(rec); err != nil {
+			l.log.Error().Err(err).Str("id", rec.ID).Msg("unable to write audit record")
+		}
+	}
+}
+
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewID returns the random transaction ID.
+func NewID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// Snapshot returns the current records matched by queries in the zone file format, sorted and deduplicated.
+// Records are fetched with a single AXFR query of the queries scope and matched locally,
+// because some upstreams (e.g. AdGuard Home) fetch everything for every query anyway.
+func Snapshot(ctx context.Context, upc upstream.Upstream, queries []upstream.Rule) ([]string, error) {
+	if len(queries) == 0 {
+		return []string{}, nil
+	}
+
+	scope := queries[0].Scope
+	for _, q := range queries[1:] {
+		if !q.Scope.Equal(scope) {
+			scope = upstream.AnyScope()
+			break
+		}
+	}
+
+	rules, err := upc.Query(ctx, upstream.Rule{
+		Type:  dns.TypeAXFR,
+		Scope: scope,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+
+	out := make([]string, 0, len(queries))
+	for _, r := range rules {
+		for _, q := range queries {
+			if r.Same(&q) {
+				out = append(out, r.String())
+				break
+			}
+		}
+	}
+
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
@@ -0,0 +1,81 @@
+// Package audit keeps the queryable log of committed upstream changes.
+package audit
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Record describes the single committed (or failed to commit) upstream transaction.
+type Record struct {
+	Time     time.Time `json:"time"`
+	ID       string    `json:"id"`
+	Client   string    `json:"client"`
+	Source   string    `json:"source,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Upstream string    `json:"upstream"`
+	// Names are the upstream names touched by the transaction.
+	Names []string `json:"names"`
+	// Updates are the requested changes as received from the client.
+	Updates []string `json:"updates,omitempty"`
+	// Before and After are the touched RRsets in the zone file format, before and after the commit.
+	Before []string `json:"before"`
+	After  []string `json:"after"`
+	Result string   `json:"result"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Diff returns removed ("- rr") and added ("+ rr") records of the committed record.
+func Diff(rec Record) []string {
+	if rec.Result != ResultOK {
+		return nil
+	}
+
+	return upstream.LinesDiff(rec.Before, rec.After)
+}
+
+type Filter struct {
+	// Name matches records touching the name or any of its subdomains.
+	Name   string
+	Client string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f Filter) Match(rec Record) bool {
+	if f.Client != "" && !equalNames(f.Client, rec.Client) {
+		return false
+	}
+
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && rec.Time.After(f.Until) {
+		return false
+	}
+
+	if f.Name == "" {
+		return true
+	}
+
+	for _, name := range rec.Names {
+		if dns.IsSubDomain(dns.Fqdn(f.Name), dns.Fqdn(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func equalNames(a, b string) bool {
+	return dns.CanonicalName(a) == dns.CanonicalName(b)
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+var _ Sink = (*SyslogSink)(nil)
+
+// SyslogSink sends records as JSON messages with the LOG_AUTHPRIV facility.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog daemon, empty network and addr mean the local one.
+func NewSyslogSink(network, addr, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+
+	return &SyslogSink{
+		w: w,
+	}, nil
+}
+
+func (s *SyslogSink) Write(rec Record) error {
+	msg, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal record: %w", err)
+	}
+
+	if rec.Result != ResultOK {
+		return s.w.Warning(string(msg))
+	}
+
+	return s.w.Info(string(msg))
+}
+
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
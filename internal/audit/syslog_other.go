@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package audit
+
+import "errors"
+
+func NewSyslogSink(_, _, _ string) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard/rules"
+)
+
+const adguardCmdTimeout = time.Minute
+
+var adguardCmd = &cobra.Command{
+	Use:   "adguard",
+	Short: "AdGuard Home upstream maintenance",
+}
+
+var adguardQuarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "Manage unparseable lines inside DNSGateway rules",
+}
+
+var adguardQuarantineListCmd = &cobra.Command{
+	Use:           "list",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Lists quarantined lines",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		upc, err := newAdguardUpstream()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), adguardCmdTimeout)
+		defer cancel()
+
+		quarantined, err := upc.QuarantinedRules(ctx)
+		if err != nil {
+			return fmt.Errorf("get quarantined lines: %w", err)
+		}
+
+		printQuarantined(quarantined)
+		return nil
+	},
+}
+
+var adguardQuarantineCleanCmd = &cobra.Command{
+	Use:           "clean",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Removes quarantined lines",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		upc, err := newAdguardUpstream()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), adguardCmdTimeout)
+		defer cancel()
+
+		dropped, err := upc.DropQuarantinedRules(ctx)
+		if err != nil {
+			return fmt.Errorf("drop quarantined lines: %w", err)
+		}
+
+		printQuarantined(dropped)
+		_, _ = fmt.Fprintf(os.Stderr, "removed %d line(s)\n", len(dropped))
+		return nil
+	},
+}
+
+func init() {
+	adguardQuarantineCmd.AddCommand(
+		adguardQuarantineListCmd,
+		adguardQuarantineCleanCmd,
+	)
+
+	adguardCmd.AddCommand(
+		adguardQuarantineCmd,
+	)
+}
+
+func newAdguardUpstream() (*uadguard.Upstream, error) {
+	runtime, err := cfg.NewRuntime()
+	if err != nil {
+		return nil, fmt.Errorf("create runtime: %w", err)
+	}
+
+	upc, err := runtime.NewAdguardUpstream()
+	if err != nil {
+		return nil, fmt.Errorf("create upstream: %w", err)
+	}
+
+	return upc, nil
+}
+
+func printQuarantined(lines []rules.Quarantined) {
+	for _, q := range lines {
+		fmt.Printf("%s\t# %v\n", q.Line, q.Err)
+	}
+}
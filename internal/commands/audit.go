@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/audit"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit log of committed changes",
+}
+
+var auditSearchArgs struct {
+	Name   string
+	Client string
+	Since  string
+	Until  string
+	JSON   bool
+}
+
+var auditSearchCmd = &cobra.Command{
+	Use:           "search",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Searches the audit log by name, client or time range",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		runtime, err := cfg.NewRuntime()
+		if err != nil {
+			return fmt.Errorf("create runtime: %w", err)
+		}
+
+		path, err := runtime.AuditLogPath()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		filter := audit.Filter{
+			Name:   auditSearchArgs.Name,
+			Client: auditSearchArgs.Client,
+		}
+
+		if filter.Since, err = parseAuditTime(auditSearchArgs.Since, now); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+
+		if filter.Until, err = parseAuditTime(auditSearchArgs.Until, now); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+
+		recs, err := audit.Search(path, filter)
+		if err != nil {
+			return err
+		}
+
+		if auditSearchArgs.JSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, rec := range recs {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, rec := range recs {
+			printAuditRecord(rec)
+		}
+		return nil
+	},
+}
+
+func init() {
+	flags := auditSearchCmd.Flags()
+	flags.StringVar(&auditSearchArgs.Name, "name", "", "name to search for, subdomains are matched as well")
+	flags.StringVar(&auditSearchArgs.Client, "client", "", "client name")
+	flags.StringVar(&auditSearchArgs.Since, "since", "", "start of the time range: RFC 3339 time or duration ago, e.g. 24h")
+	flags.StringVar(&auditSearchArgs.Until, "until", "", "end of the time range: RFC 3339 time or duration ago")
+	flags.BoolVar(&auditSearchArgs.JSON, "json", false, "print records as JSON lines")
+
+	auditCmd.AddCommand(
+		auditSearchCmd,
+	)
+}
+
+func parseAuditTime(in string, now time.Time) (time.Time, error) {
+	if in == "" {
+		return time.Time{}, nil
+	}
+
+	if ago, err := time.ParseDuration(in); err == nil {
+		return now.Add(-ago), nil
+	}
+
+	return time.Parse(time.RFC3339, in)
+}
+
+func printAuditRecord(rec audit.Record) {
+	fmt.Printf("%s\t%s\tclient=%s source=%s upstream=%s result=%s\n",
+		rec.Time.Format(time.RFC3339), rec.ID, rec.Client, rec.Source, rec.Upstream, rec.Result,
+	)
+
+	if rec.Error != "" {
+		fmt.Printf("\t! %s\n", rec.Error)
+	}
+
+	for _, line := range audit.Diff(rec) {
+		fmt.Printf("\t%s\n", line)
+	}
+}
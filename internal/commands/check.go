@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/config"
+)
+
+var checkConfigArgs struct {
+	Upstream bool
+	Timeout  time.Duration
+}
+
+var checkConfigCmd = &cobra.Command{
+	Use:           "check-config",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Validates config, optionally checks upstream connectivity and credentials",
+	Args:          cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		runtime, err := cfg.NewRuntime()
+		if err != nil {
+			return reportProblems("config is invalid", err)
+		}
+
+		if checkConfigArgs.Upstream {
+			ctx, cancel := context.WithTimeout(context.Background(), checkConfigArgs.Timeout)
+			defer cancel()
+
+			if err := runtime.CheckUpstreams(ctx); err != nil {
+				return reportProblems("upstream check failed", err)
+			}
+		}
+
+		fmt.Println("config is valid")
+		return nil
+	},
+}
+
+// reportProblems returns the error listing every problem on its own line.
+func reportProblems(summary string, err error) error {
+	var sb strings.Builder
+	problems := config.Problems(err)
+	_, _ = fmt.Fprintf(&sb, "%s, %d problem(s) found:", summary, len(problems))
+	for _, p := range problems {
+		_, _ = fmt.Fprintf(&sb, "\n  - %v", p)
+	}
+
+	return errors.New(sb.String())
+}
+
+func init() {
+	flags := checkConfigCmd.Flags()
+	flags.BoolVar(&checkConfigArgs.Upstream, "upstream", false, "query the upstream records to check connectivity and credentials")
+	flags.DurationVar(&checkConfigArgs.Timeout, "timeout", 30*time.Second, "upstream check timeout")
+}
@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/config"
+	"github.com/buglloc/DNSGateway/internal/secret"
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+)
+
+var clientArgs struct {
+	Zones      []string
+	Types      []string
+	XFR        bool
+	Algorithm  string
+	SecretFile string
+	Server     string
+	Snippets   []string
+}
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "RFC 2136 clients provisioning",
+}
+
+var clientListCmd = &cobra.Command{
+	Use:           "list",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Lists configured clients",
+	Args:          cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		for _, cl := range cfg.Listener.RFC2136.Clients {
+			types := "any"
+			if len(cl.Types) > 0 {
+				types = strings.Join(cl.Types, ",")
+			}
+
+			fmt.Printf("%s\tzones=%s\ttypes=%s\txfr=%t\tsecret=%s\n",
+				cl.Name, strings.Join(cl.Zones, ","), types, cl.XFRAllowed, cl.Secret.Source(),
+			)
+		}
+		return nil
+	},
+}
+
+var clientAddCmd = &cobra.Command{
+	Use:           "add <name>",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Adds the client with the generated key to the config and prints its snippets",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		key, err := tsigkey.NewKey(args[0], clientArgs.Algorithm)
+		if err != nil {
+			return err
+		}
+
+		zones := make([]string, len(clientArgs.Zones))
+		for i, zone := range clientArgs.Zones {
+			zones[i] = dns.Fqdn(zone)
+		}
+
+		f, err := config.OpenClientsFile(rootArgs.Configs...)
+		if err != nil {
+			return err
+		}
+
+		cl := config.Client{
+			Name:       key.Name,
+			Secret:     secret.Ref{Value: key.Secret},
+			XFRAllowed: clientArgs.XFR,
+			Zones:      zones,
+			Types:      clientArgs.Types,
+		}
+		if clientArgs.SecretFile != "" {
+			cl.Secret = secret.Ref{File: clientArgs.SecretFile}
+		}
+
+		if err := f.Add(cl); err != nil {
+			return err
+		}
+
+		if clientArgs.SecretFile != "" {
+			// the existing file may be the key of another client, so it's never replaced
+			if err := config.CreateSecretFile(clientArgs.SecretFile, key.Secret); err != nil {
+				return err
+			}
+		}
+
+		if err := f.Save(); err != nil {
+			if clientArgs.SecretFile != "" {
+				_ = os.Remove(clientArgs.SecretFile)
+			}
+			return err
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "client %q is added to %s, reload the running server with SIGHUP\n", key.Name, f.Path())
+		return printSnippets(key, zones)
+	},
+}
+
+var clientRotateCmd = &cobra.Command{
+	Use:           "rotate <name>",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Replaces the client key with the generated one and prints its snippets",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		key, err := tsigkey.NewKey(args[0], clientArgs.Algorithm)
+		if err != nil {
+			return err
+		}
+
+		f, err := config.OpenClientsFile(rootArgs.Configs...)
+		if err != nil {
+			return err
+		}
+
+		where, err := f.SetSecret(key.Name, key.Secret)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Save(); err != nil {
+			return err
+		}
+
+		var zones []string
+		for _, cl := range cfg.Listener.RFC2136.Clients {
+			if dns.Fqdn(cl.Name) == key.Name {
+				zones = cl.Zones
+			}
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "client %q key is rotated in %s, reload the running server with SIGHUP\n", key.Name, where)
+		return printSnippets(key, zones)
+	},
+}
+
+var clientRemoveCmd = &cobra.Command{
+	Use:           "remove <name>",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Removes the client from the config",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		f, err := config.OpenClientsFile(rootArgs.Configs...)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Remove(args[0]); err != nil {
+			return err
+		}
+
+		if err := f.Save(); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "client %q is removed from %s, reload the running server with SIGHUP\n", dns.Fqdn(args[0]), f.Path())
+		return nil
+	},
+}
+
+var tsigKeygenCmd = &cobra.Command{
+	Use:           "tsig-keygen [name]",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Generates TSIG key and prints it in the BIND format",
+	Args:          cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		name := "tsig-key."
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		key, err := tsigkey.NewKey(name, clientArgs.Algorithm)
+		if err != nil {
+			return err
+		}
+
+		out, err := tsigkey.Snippet(tsigkey.SnippetNSUpdate, key, tsigkey.Target{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(out)
+		return nil
+	},
+}
+
+func printSnippets(key tsigkey.Key, zones []string) error {
+	server := clientArgs.Server
+	if server == "" {
+		server = cfg.Listener.RFC2136.Addr
+	}
+
+	target, err := tsigkey.NewTarget(server, zones)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range clientArgs.Snippets {
+		out, err := tsigkey.Snippet(name, key, target)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("# ---- %s ----\n%s", name, out)
+	}
+
+	return nil
+}
+
+func init() {
+	algorithmHelp := fmt.Sprintf("TSIG algorithm: %s", strings.Join(tsigkey.Algorithms(), ", "))
+	for _, cmd := range []*cobra.Command{clientAddCmd, clientRotateCmd} {
+		flags := cmd.Flags()
+		flags.StringVar(&clientArgs.Algorithm, "algorithm", "hmac-sha256", algorithmHelp)
+		flags.StringVar(&clientArgs.Server, "server", "", "DNSGateway address for snippets, listener.rfc2136.addr by default")
+		flags.StringSliceVar(&clientArgs.Snippets, "snippet", tsigkey.Snippets, "snippets to print: "+strings.Join(tsigkey.Snippets, ", "))
+	}
+
+	addFlags := clientAddCmd.Flags()
+	addFlags.StringSliceVar(&clientArgs.Zones, "zone", nil, "zone the client may update, may be repeated")
+	addFlags.StringSliceVar(&clientArgs.Types, "type", nil, "record type the client may update, may be repeated; any by default")
+	addFlags.BoolVar(&clientArgs.XFR, "xfr", false, "allow zone transfers, e.g. for ExternalDNS")
+	addFlags.StringVar(&clientArgs.SecretFile, "secret-file", "", "write the key into the new file and reference it instead of the inline secret")
+	_ = clientAddCmd.MarkFlagRequired("zone")
+
+	tsigKeygenCmd.Flags().StringVar(&clientArgs.Algorithm, "algorithm", "hmac-sha256", algorithmHelp)
+
+	clientCmd.AddCommand(
+		clientListCmd,
+		clientAddCmd,
+		clientRotateCmd,
+		clientRemoveCmd,
+	)
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/nsupdate"
+)
+
+const dryRunCmdTimeout = time.Minute
+
+var dryRunArgs struct {
+	Client string
+}
+
+var dryRunCmd = &cobra.Command{
+	Use:           "dry-run [flags] <nsupdate script|->",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Applies nsupdate script on behalf of the client without committing and prints the upstream diff",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		msgs, err := parseScriptFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		runtime, err := cfg.NewRuntime()
+		if err != nil {
+			return fmt.Errorf("create runtime: %w", err)
+		}
+		defer func() { _ = runtime.Close() }()
+
+		instance, err := runtime.NewRFC2136Listener()
+		if err != nil {
+			return fmt.Errorf("create listener: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dryRunCmdTimeout)
+		defer cancel()
+
+		for i, msg := range msgs {
+			fmt.Printf("; update #%d, zone %s\n", i+1, msg.Zone)
+			diff, err := instance.DryRun(ctx, dryRunArgs.Client, msg.Updates)
+			if err != nil {
+				return fmt.Errorf("update #%d: %w", i+1, err)
+			}
+
+			if len(diff) == 0 {
+				fmt.Println("; no changes")
+				continue
+			}
+
+			for _, line := range diff {
+				fmt.Println(line)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	flags := dryRunCmd.Flags()
+	flags.StringVar(&dryRunArgs.Client, "client", "", "client (TSIG key) name to apply the script on behalf of")
+	_ = dryRunCmd.MarkFlagRequired("client")
+}
+
+func parseScriptFile(path string) ([]nsupdate.Message, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open script: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		r = f
+	}
+
+	msgs, err := nsupdate.ParseScript(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse script: %w", err)
+	}
+
+	return msgs, nil
+}
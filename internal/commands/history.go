@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/history"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+const historyCmdTimeout = 5 * time.Minute
+
+var historyArgs struct {
+	Upstream string
+	DryRun   bool
+	Yes      bool
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Snapshots of the upstream records taken before every commit",
+}
+
+var historyListCmd = &cobra.Command{
+	Use:           "list",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Lists snapshots",
+	Args:          cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		store, _, err := newHistory()
+		if err != nil {
+			return err
+		}
+
+		snaps, err := store.List()
+		if err != nil {
+			return err
+		}
+
+		for _, snap := range snaps {
+			fmt.Printf("%d\t%s\t%d records\tactor=%s\n", snap.ID, snap.Time.Format(time.RFC3339), len(snap.Records), snap.Actor)
+		}
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:           "show <id>",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Prints records of the snapshot",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		store, _, err := newHistory()
+		if err != nil {
+			return err
+		}
+
+		snap, err := getSnapshot(store, args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("; snapshot #%d of %s, %s, actor=%s\n", snap.ID, snap.Upstream, snap.Time.Format(time.RFC3339), snap.Actor)
+		for _, r := range snap.Records {
+			fmt.Println(r.String())
+		}
+		return nil
+	},
+}
+
+var historyDiffCmd = &cobra.Command{
+	Use:           "diff <id> [<id>]",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Prints changes made since the snapshot, up to the other snapshot or the current upstream state",
+	Args:          cobra.RangeArgs(1, 2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		store, upc, err := newHistory()
+		if err != nil {
+			return err
+		}
+
+		from, err := getSnapshot(store, args[0])
+		if err != nil {
+			return err
+		}
+
+		var to history.Snapshot
+		if len(args) == 2 {
+			to, err = getSnapshot(store, args[1])
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), historyCmdTimeout)
+			defer cancel()
+
+			to, err = history.NewSnapshot(ctx, upc)
+		}
+		if err != nil {
+			return err
+		}
+
+		printLines(history.DiffLines(history.Diff(from.Records, to.Records)), "; no changes")
+		return nil
+	},
+}
+
+var historyRollbackCmd = &cobra.Command{
+	Use:           "rollback <id>",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Restores the upstream records to the snapshot state",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		store, upc, err := newHistory()
+		if err != nil {
+			return err
+		}
+
+		snap, err := getSnapshot(store, args[0])
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), historyCmdTimeout)
+		defer cancel()
+		ctx = history.WithActor(ctx, fmt.Sprintf("rollback to #%d", snap.ID))
+
+		tx, changes, err := history.Restore(ctx, upc, snap)
+		if err != nil {
+			return err
+		}
+		defer tx.Close()
+
+		if len(changes) == 0 {
+			fmt.Println("; upstream is already in the snapshot state")
+			return nil
+		}
+
+		printLines(changes, "")
+		if historyArgs.DryRun {
+			diff, err := upstream.TxDiff(ctx, tx)
+			if err != nil {
+				if errors.Is(err, upstream.ErrDiffUnsupported) {
+					return nil
+				}
+
+				return err
+			}
+
+			fmt.Println("; upstream changes:")
+			printLines(diff, "")
+			return nil
+		}
+
+		if !historyArgs.Yes && !confirm(fmt.Sprintf("Apply %d changes to %s?", len(changes), store.Upstream())) {
+			return errors.New("rollback cancelled")
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("upstream tx commit: %w", err)
+		}
+
+		fmt.Printf("; restored snapshot #%d\n", snap.ID)
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.PersistentFlags().StringVar(&historyArgs.Upstream, "upstream", "", "upstream history name, the main upstream by default")
+
+	rollbackFlags := historyRollbackCmd.Flags()
+	rollbackFlags.BoolVar(&historyArgs.DryRun, "dry-run", false, "print changes without applying them")
+	rollbackFlags.BoolVarP(&historyArgs.Yes, "yes", "y", false, "don't ask for confirmation")
+
+	historyCmd.AddCommand(
+		historyListCmd,
+		historyShowCmd,
+		historyDiffCmd,
+		historyRollbackCmd,
+	)
+}
+
+func newHistory() (*history.Store, upstream.Upstream, error) {
+	runtime, err := cfg.NewRuntime()
+	if err != nil {
+		return nil, nil, fmt.Errorf("create runtime: %w", err)
+	}
+
+	return runtime.NewHistoryUpstream(historyArgs.Upstream)
+}
+
+func getSnapshot(store *history.Store, idStr string) (history.Snapshot, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(idStr, "#"))
+	if err != nil {
+		return history.Snapshot{}, fmt.Errorf("invalid snapshot id %q", idStr)
+	}
+
+	return store.Get(id)
+}
+
+func printLines(lines []string, empty string) {
+	if len(lines) == 0 && empty != "" {
+		fmt.Println(empty)
+		return
+	}
+
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var leasesCmd = &cobra.Command{
+	Use:   "leases",
+	Short: "Records leases maintenance",
+}
+
+var leasesListCmd = &cobra.Command{
+	Use:           "list",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Lists leased records with their expiration time",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		runtime, err := cfg.NewRuntime()
+		if err != nil {
+			return fmt.Errorf("create runtime: %w", err)
+		}
+
+		store, err := runtime.NewLeaseStore()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, l := range store.Leases() {
+			state := "expires in " + l.Expires.Sub(now).Round(time.Second).String()
+			if l.IsExpired(now) {
+				state = "expired"
+			}
+
+			fmt.Printf("%s\t%s\t# owner=%s, %s\n", l.Expires.Format(time.RFC3339), l.RR, l.Owner, state)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	leasesCmd.AddCommand(
+		leasesListCmd,
+	)
+}
@@ -35,6 +35,17 @@ func init() {
 
 	rootCmd.AddCommand(
 		startCmd,
+		adguardCmd,
+		leasesCmd,
+		dryRunCmd,
+		auditCmd,
+		historyCmd,
+		checkConfigCmd,
+		clientCmd,
+		tsigKeygenCmd,
+		updateCmd,
+		queryCmd,
+		axfrCmd,
 	)
 }
 
@@ -10,8 +10,14 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/config"
 )
 
+var startArgs struct {
+	Watch bool
+}
+
 var startCmd = &cobra.Command{
 	Use:           "start",
 	SilenceUsage:  true,
@@ -22,14 +28,46 @@ var startCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("create runtime: %w", err)
 		}
+		defer func() {
+			// the current runtime, it's replaced on reload
+			if err := runtime.Close(); err != nil {
+				log.Error().Err(err).Msg("upstream close failed")
+			}
+		}()
+
+		tracer, err := runtime.NewTracingProvider(context.Background())
+		if err != nil {
+			return fmt.Errorf("create tracing provider: %w", err)
+		}
+
+		if tracer != nil {
+			defer func() {
+				// flush pending spans
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				if err := tracer.Shutdown(ctx); err != nil {
+					log.Error().Err(err).Msg("tracing shutdown failed")
+				}
+			}()
+		}
 
 		instance, err := runtime.NewListener()
 		if err != nil {
 			return fmt.Errorf("create gateway: %w", err)
 		}
 
-		errChan := make(chan error, 1)
+		errChan := make(chan error, 2)
 		okChan := make(chan struct{})
+		metricsSrv := runtime.NewMetricsServer()
+		if metricsSrv != nil {
+			go func() {
+				if err := metricsSrv.ListenAndServe(); err != nil {
+					errChan <- err
+				}
+			}()
+		}
+
 		go func() {
 			err := instance.ListenAndServe()
 			if err != nil {
@@ -40,21 +78,56 @@ var startCmd = &cobra.Command{
 			close(okChan)
 		}()
 
+		reloadChan := make(chan os.Signal, 1)
+		signal.Notify(reloadChan, syscall.SIGHUP)
+		if startArgs.Watch {
+			stopWatch, err := config.WatchFiles(rootArgs.Configs, func() {
+				select {
+				case reloadChan <- syscall.SIGHUP:
+				default:
+					// reload is already pending
+				}
+			})
+			if err != nil {
+				return err
+			}
+			defer stopWatch()
+		}
+
 		stopChan := make(chan os.Signal, 1)
 		signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
-		select {
-		case <-stopChan:
-			log.Info().Msg("shutting down gracefully by signal")
-
-			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-			defer cancel()
-
-			return instance.Shutdown(ctx)
-		case err := <-errChan:
-			log.Error().Err(err).Msg("start failed")
-			return err
-		case <-okChan:
+		for {
+			select {
+			case <-reloadChan:
+				next, err := config.Reload(runtime, instance, rootArgs.Configs...)
+				if err != nil {
+					// Reload has logged and counted the failure, the listener keeps serving with the current runtime
+					continue
+				}
+				runtime = next
+			case <-stopChan:
+				log.Info().Msg("shutting down gracefully by signal")
+
+				ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+				defer cancel()
+
+				if metricsSrv != nil {
+					if err := metricsSrv.Shutdown(ctx); err != nil {
+						log.Error().Err(err).Msg("metrics server shutdown failed")
+					}
+				}
+
+				return instance.Shutdown(ctx)
+			case err := <-errChan:
+				log.Error().Err(err).Msg("start failed")
+				return err
+			case <-okChan:
+				return nil
+			}
 		}
-		return nil
 	},
 }
+
+func init() {
+	startCmd.Flags().BoolVar(&startArgs.Watch, "watch", false, "reload config on config files changes, in addition to SIGHUP")
+}
@@ -0,0 +1,333 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/DNSGateway/internal/listener/lrfc2136"
+	"github.com/buglloc/DNSGateway/internal/nsupdate"
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var connArgs struct {
+	Server    string
+	Client    string
+	Key       string
+	KeyFile   string
+	Algorithm string
+	TCP       bool
+	Timeout   time.Duration
+}
+
+var updateArgs struct {
+	File string
+	Zone string
+	TTL  uint32
+}
+
+var updateCmd = &cobra.Command{
+	Use: "update [flags] (add|delete|replace) <args...>",
+	Example: `  dns-gateway update --client tst. add a.example.com. 60 A 192.0.2.1
+  dns-gateway update --key hmac-sha256:tst.:c2VjcmV0 replace a.example.com. 60 A 192.0.2.2
+  dns-gateway update --key-file tst.key delete a.example.com. A
+  dns-gateway update --client tst. --file script.txt`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Sends RFC 2136 updates to the running gateway, like nsupdate",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		msgs, err := updateMessages(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		client, zones, err := newDNSClient()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		for i, msg := range msgs {
+			zone := msg.Zone
+			if zone == "" {
+				zone, err = findZone(ctx, client, zones, msg.Updates[0].Header().Name)
+				if err != nil {
+					return fmt.Errorf("update #%d: %w", i+1, err)
+				}
+			}
+
+			for _, rr := range msg.Updates {
+				fmt.Println(";", formatUpdate(rr))
+			}
+
+			if _, err := client.Update(ctx, zone, msg.Updates); err != nil {
+				return fmt.Errorf("update #%d, zone %s: %w", i+1, zone, err)
+			}
+
+			fmt.Printf("; update #%d, zone %s: %s\n", i+1, zone, dns.RcodeToString[dns.RcodeSuccess])
+		}
+
+		return nil
+	},
+}
+
+var queryCmd = &cobra.Command{
+	Use:           "query [flags] <name> [type]",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Queries the running gateway, signed with the client key if any",
+	Args:          cobra.RangeArgs(1, 2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		qtype := dns.TypeA
+		if len(args) > 1 {
+			var err error
+			qtype, err = upstream.ParseType(args[1])
+			if err != nil {
+				return err
+			}
+		}
+
+		client, _, err := newDNSClient()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		r, err := client.Query(ctx, args[0], qtype)
+		if r != nil {
+			fmt.Printf(";; status: %s\n", dns.RcodeToString[r.Rcode])
+			for _, rr := range r.Answer {
+				fmt.Println(rr)
+			}
+			for _, rr := range r.Ns {
+				fmt.Println(rr)
+			}
+		}
+
+		return err
+	},
+}
+
+var axfrCmd = &cobra.Command{
+	Use:           "axfr [flags] <zone>",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Transfers the zone from the running gateway",
+	Args:          cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		client, _, err := newDNSClient()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		rrs, err := client.Transfer(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("transfer %s: %w", dns.Fqdn(args[0]), err)
+		}
+
+		for _, rr := range rrs {
+			fmt.Println(rr)
+		}
+
+		fmt.Printf(";; %d records\n", len(rrs))
+		return nil
+	},
+}
+
+func updateMessages(cmd *cobra.Command, args []string) ([]nsupdate.Message, error) {
+	if updateArgs.File != "" {
+		if len(args) > 0 {
+			return nil, errors.New("operation args and --file are mutually exclusive")
+		}
+
+		msgs, err := parseScriptFile(updateArgs.File)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range msgs {
+			if msgs[i].Zone == "" {
+				msgs[i].Zone = dnsZone(updateArgs.Zone)
+			}
+		}
+
+		if len(msgs) == 0 {
+			return nil, errors.New("script has no updates")
+		}
+
+		return msgs, nil
+	}
+
+	if len(args) < 2 {
+		return nil, errors.New("operation (add, delete or replace) with its args or --file is required")
+	}
+
+	var ttl *uint32
+	if cmd.Flags().Changed("ttl") {
+		ttl = &updateArgs.TTL
+	}
+
+	updates, err := nsupdate.ParseOperation(args[0], strings.Join(args[1:], " "), ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return []nsupdate.Message{
+		{
+			Zone:    dnsZone(updateArgs.Zone),
+			Updates: updates,
+		},
+	}, nil
+}
+
+// newDNSClient creates the client signing requests with the key from flags or the config, the unsigned one otherwise.
+// Zones of the configured client are returned as well.
+func newDNSClient() (*nsupdate.Client, []string, error) {
+	opts := []nsupdate.ClientOption{
+		nsupdate.WithTCP(connArgs.TCP),
+		nsupdate.WithTimeout(connArgs.Timeout),
+	}
+
+	var zones []string
+	var key tsigkey.Key
+	var err error
+	switch {
+	case connArgs.Key != "":
+		key, err = tsigkey.ParseKey(connArgs.Key)
+	case connArgs.KeyFile != "":
+		key, err = tsigkey.ReadBindKeyFile(connArgs.KeyFile)
+	case connArgs.Client != "":
+		key, zones, err = configClientKey(connArgs.Client)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.Name != "" {
+		opts = append(opts, nsupdate.WithKey(key))
+	}
+
+	server, err := serverAddr()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nsupdate.NewClient(server, opts...), zones, nil
+}
+
+func configClientKey(name string) (tsigkey.Key, []string, error) {
+	runtime, err := cfg.NewRuntime()
+	if err != nil {
+		return tsigkey.Key{}, nil, fmt.Errorf("create runtime: %w", err)
+	}
+
+	cl, err := runtime.Client(name)
+	if err != nil {
+		return tsigkey.Key{}, nil, err
+	}
+
+	algorithm, err := tsigkey.ParseAlgorithm(connArgs.Algorithm)
+	if err != nil {
+		return tsigkey.Key{}, nil, err
+	}
+
+	return tsigkey.Key{
+		Name:      dns.Fqdn(cl.Name),
+		Algorithm: algorithm,
+		Secret:    cl.Secret.Reveal(),
+	}, cl.Zones, nil
+}
+
+// serverAddr returns the --server with the default port, or the address of the configured listener.
+func serverAddr() (string, error) {
+	if connArgs.Server == "" {
+		target, err := tsigkey.NewTarget(cfg.Listener.RFC2136.Addr, nil)
+		if err != nil {
+			return "", err
+		}
+
+		return target.Addr(), nil
+	}
+
+	if _, _, err := net.SplitHostPort(connArgs.Server); err != nil {
+		return net.JoinHostPort(connArgs.Server, "53"), nil
+	}
+
+	return connArgs.Server, nil
+}
+
+// findZone picks the zone of the configured client, or asks the server otherwise.
+func findZone(ctx context.Context, client *nsupdate.Client, zones []string, name string) (string, error) {
+	if zone := lrfc2136.MatchZone(zones, name); zone != "" {
+		return dns.Fqdn(zone), nil
+	}
+
+	zone, err := client.FindZone(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("find zone of %q, use --zone: %w", name, err)
+	}
+
+	return zone, nil
+}
+
+// formatUpdate prints the update in the nsupdate syntax.
+func formatUpdate(rr dns.RR) string {
+	hdr := rr.Header()
+	switch hdr.Class {
+	case dns.ClassANY:
+		if hdr.Rrtype == dns.TypeANY {
+			return "delete " + hdr.Name
+		}
+		return "delete " + hdr.Name + " " + dns.TypeToString[hdr.Rrtype]
+	case dns.ClassNONE:
+		rr = dns.Copy(rr)
+		rr.Header().Class = dns.ClassINET
+		return "delete " + rr.String()
+	default:
+		return "add " + rr.String()
+	}
+}
+
+func dnsZone(zone string) string {
+	if zone == "" {
+		return ""
+	}
+
+	return dns.Fqdn(zone)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{updateCmd, queryCmd, axfrCmd} {
+		flags := cmd.Flags()
+		flags.StringVar(&connArgs.Server, "server", "", "gateway address, listener.rfc2136.addr of the config by default")
+		flags.StringVar(&connArgs.Client, "client", "", "sign requests with the key of the configured client")
+		flags.StringVarP(&connArgs.Key, "key", "y", "", "sign requests with the key: [algorithm:]name:secret")
+		flags.StringVarP(&connArgs.KeyFile, "key-file", "k", "", "sign requests with the key from the BIND key file")
+		flags.StringVar(&connArgs.Algorithm, "algorithm", "hmac-sha256", "TSIG algorithm of the configured client key")
+		flags.DurationVar(&connArgs.Timeout, "timeout", nsupdate.DefaultTimeout, "request timeout")
+		cmd.MarkFlagsMutuallyExclusive("client", "key", "key-file")
+	}
+
+	for _, cmd := range []*cobra.Command{updateCmd, queryCmd} {
+		cmd.Flags().BoolVar(&connArgs.TCP, "tcp", false, "use TCP instead of UDP")
+	}
+
+	flags := updateCmd.Flags()
+	flags.StringVarP(&updateArgs.File, "file", "f", "", "nsupdate script file or - for stdin")
+	flags.StringVar(&updateArgs.Zone, "zone", "", "zone to update, the configured client zone or the one found by SOA queries by default")
+	flags.Uint32Var(&updateArgs.TTL, "ttl", 0, "TTL of added records without the explicit one")
+}
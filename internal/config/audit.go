@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/buglloc/DNSGateway/internal/audit"
+)
+
+type AuditFile struct {
+	Path       string `koanf:"path"`
+	MaxSizeMB  int64  `koanf:"max_size_mb"`
+	MaxBackups int    `koanf:"max_backups"`
+}
+
+type AuditSyslog struct {
+	Enabled bool   `koanf:"enabled"`
+	Network string `koanf:"network"`
+	Addr    string `koanf:"addr"`
+	Tag     string `koanf:"tag"`
+}
+
+type Audit struct {
+	File   AuditFile   `koanf:"file"`
+	Syslog AuditSyslog `koanf:"syslog"`
+}
+
+// NewAuditLogger creates the audit logger of committed changes, it's nil if no sinks are configured.
+func (r *Runtime) NewAuditLogger() (*audit.Logger, error) {
+	cfg := r.cfg.Audit
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid audit config: %w", err)
+	}
+
+	var sinks []audit.Sink
+	if cfg.File.Path != "" {
+		sink, err := audit.NewFileSink(cfg.File.Path,
+			audit.WithMaxSize(cfg.File.MaxSizeMB<<20),
+			audit.WithMaxBackups(cfg.File.MaxBackups),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	if cfg.Syslog.Enabled {
+		tag := cfg.Syslog.Tag
+		if tag == "" {
+			tag = "dns-gateway"
+		}
+
+		sink, err := audit.NewSyslogSink(cfg.Syslog.Network, cfg.Syslog.Addr, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	return audit.NewLogger(string(r.cfg.Upstream.Kind), sinks...), nil
+}
+
+// AuditLogPath returns the audit log file to search in.
+func (r *Runtime) AuditLogPath() (string, error) {
+	if r.cfg.Audit.File.Path == "" {
+		return "", errors.New("audit log is not configured: audit.file.path is empty")
+	}
+
+	return r.cfg.Audit.File.Path, nil
+}
+
+func (a *Audit) Validate() error {
+	var errs []error
+	if a.File.MaxSizeMB < 0 {
+		errs = append(errs, fieldErrorf("file.max_size_mb", "must be non-negative"))
+	}
+
+	if a.File.MaxBackups < 0 {
+		errs = append(errs, fieldErrorf("file.max_backups", "must be non-negative"))
+	}
+
+	return errors.Join(errs...)
+}
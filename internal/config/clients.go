@@ -0,0 +1,428 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"go.yaml.in/yaml/v3"
+)
+
+// ClientsFile edits clients of the rfc2136 listener in place, comments and the rest of the config file are kept.
+type ClientsFile struct {
+	path  string
+	files []string
+	doc   yaml.Node
+	dirty bool
+	// secretFiles are new secrets of file references, they are written by Save along with the config
+	secretFiles map[*yaml.Node]string
+}
+
+// OpenClientsFile opens the config file to edit clients in: the last of files defining them, or the last one.
+// All files are needed to validate the result.
+func OpenClientsFile(files ...string) (*ClientsFile, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no config file to edit, use --config")
+	}
+
+	var out *ClientsFile
+	for _, fpath := range slices.Backward(files) {
+		f, err := readClientsFile(fpath)
+		if err != nil {
+			return nil, err
+		}
+
+		if out == nil {
+			out = f
+		}
+
+		if f.clients(false) != nil {
+			out = f
+			break
+		}
+	}
+
+	out.files = files
+	return out, nil
+}
+
+func readClientsFile(path string) (*ClientsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	out := &ClientsFile{
+		path: path,
+	}
+	if err := yaml.Unmarshal(data, &out.doc); err != nil {
+		return nil, fmt.Errorf("parse %q config: %w", path, err)
+	}
+
+	if out.doc.Kind == 0 {
+		// empty file
+		out.doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	if out.doc.Kind != yaml.DocumentNode || len(out.doc.Content) != 1 || out.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parse %q config: mapping is expected at the top level", path)
+	}
+
+	return out, nil
+}
+
+// Path returns the edited config file.
+func (f *ClientsFile) Path() string {
+	return f.path
+}
+
+// Add appends the client with the inline or file secret.
+func (f *ClientsFile) Add(cl Client) error {
+	if f.find(cl.Name) != nil {
+		return fmt.Errorf("client %q already exists in %q", cl.Name, f.path)
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	setKey(node, "name", strNode(cl.Name))
+	switch {
+	case cl.Secret.File != "":
+		ref := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setKey(ref, "file", strNode(cl.Secret.File))
+		setKey(node, "secret", ref)
+	default:
+		setKey(node, "secret", strNode(cl.Secret.Value))
+	}
+
+	if cl.XFRAllowed {
+		setKey(node, "xfr_allowed", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+	}
+
+	setKey(node, "zones", strsNode(cl.Zones))
+	if len(cl.Types) > 0 {
+		setKey(node, "types", strsNode(cl.Types))
+	}
+
+	clients := f.clients(true)
+	clients.Content = append(clients.Content, node)
+	f.dirty = true
+	return nil
+}
+
+// Remove deletes the client.
+func (f *ClientsFile) Remove(name string) error {
+	clients := f.clients(false)
+	node := f.find(name)
+	if node == nil {
+		return fmt.Errorf("client %q is not found in %q", name, f.path)
+	}
+
+	clients.Content = slices.DeleteFunc(clients.Content, func(n *yaml.Node) bool {
+		return n == node
+	})
+	f.dirty = true
+	return nil
+}
+
+// SetSecret replaces the client secret and returns the file it's kept in: the config or the secret file one.
+// The secret file is rewritten by Save, other references can't be updated.
+func (f *ClientsFile) SetSecret(name, value string) (string, error) {
+	node := f.find(name)
+	if node == nil {
+		return "", fmt.Errorf("client %q is not found in %q", name, f.path)
+	}
+
+	where := f.path
+	ref := mappingValue(node, "secret")
+	switch {
+	case ref == nil:
+		setKey(node, "secret", strNode(value))
+	case ref.Kind == yaml.ScalarNode:
+		if strings.Contains(ref.Value, "${") {
+			return "", fmt.Errorf("secret of client %q is interpolated from environment, update it there", name)
+		}
+		ref.Value = value
+		ref.Style = 0
+	case ref.Kind == yaml.MappingNode && mappingValue(ref, "file") != nil:
+		fileNode := mappingValue(ref, "file")
+		if f.secretFiles == nil {
+			f.secretFiles = make(map[*yaml.Node]string)
+		}
+		f.secretFiles[fileNode] = value
+		where = fileNode.Value
+	case ref.Kind == yaml.MappingNode && mappingValue(ref, "value") != nil:
+		mappingValue(ref, "value").Value = value
+	default:
+		return "", fmt.Errorf("secret of client %q is kept outside of the config, update it there", name)
+	}
+
+	f.dirty = true
+	return where, nil
+}
+
+// Save validates the config with changes applied and replaces the file. Secret files are replaced only along with
+// the valid config, while the validation reads new secrets from their temporary copies.
+func (f *ClientsFile) Save() error {
+	if !f.dirty {
+		return nil
+	}
+
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(f.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	data, err := f.encode()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := writeTemp(f.path, data, mode)
+	if err != nil {
+		return fmt.Errorf("write temporary config: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	validated := tmp
+	secrets := make(map[string]string, len(f.secretFiles))
+	if len(f.secretFiles) > 0 {
+		for node, value := range f.secretFiles {
+			tmpSecret, err := writeTemp(node.Value, []byte(value+"\n"), 0o600)
+			if err != nil {
+				return fmt.Errorf("write temporary secret file: %w", err)
+			}
+			defer func() { _ = os.Remove(tmpSecret) }()
+			secrets[node.Value] = tmpSecret
+		}
+
+		validated, err = f.writeValidated(mode, secrets)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(validated) }()
+	}
+
+	files := slices.Clone(f.files)
+	files[slices.Index(files, f.path)] = validated
+	cfg, err := LoadConfig(files...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := cfg.NewRuntime(); err != nil {
+		return fmt.Errorf("changed config is invalid: %w", err)
+	}
+
+	backups := make(map[string]string, len(secrets))
+	defer func() {
+		for _, backup := range backups {
+			_ = os.Remove(backup)
+		}
+	}()
+	for path := range secrets {
+		backup, err := backupFile(path)
+		if err != nil {
+			return fmt.Errorf("backup secret file: %w", err)
+		}
+		backups[path] = backup
+	}
+
+	// the replaced secret files are restored unless the config is replaced as well
+	for path, tmpSecret := range secrets {
+		if err := os.Rename(tmpSecret, path); err != nil {
+			restoreFiles(backups)
+			return fmt.Errorf("replace secret file: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		restoreFiles(backups)
+		return fmt.Errorf("replace config: %w", err)
+	}
+
+	f.dirty = false
+	f.secretFiles = nil
+	return nil
+}
+
+// writeValidated writes the config copy referencing temporary secret files instead of the replaced ones.
+func (f *ClientsFile) writeValidated(mode os.FileMode, secrets map[string]string) (string, error) {
+	for node := range f.secretFiles {
+		path := node.Value
+		node.Value = secrets[path]
+		defer func() { node.Value = path }()
+	}
+
+	data, err := f.encode()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := writeTemp(f.path, data, mode)
+	if err != nil {
+		return "", fmt.Errorf("write temporary config: %w", err)
+	}
+
+	return out, nil
+}
+
+func (f *ClientsFile) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&f.doc); err != nil {
+		return nil, fmt.Errorf("encode config: %w", err)
+	}
+	_ = enc.Close()
+
+	return buf.Bytes(), nil
+}
+
+// writeTemp writes the hidden temporary file next to the target, so it can be renamed over it.
+func writeTemp(target string, data []byte, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// backupFile copies the file into the temporary file next to it, so it can be renamed back.
+func backupFile(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return writeTemp(path, data, fi.Mode().Perm())
+}
+
+// restoreFiles renames the backups over the files they were taken from.
+func restoreFiles(backups map[string]string) {
+	for path, backup := range backups {
+		_ = os.Rename(backup, path)
+	}
+}
+
+// CreateSecretFile creates the secret file readable by the owner only, the existing file is never replaced.
+func CreateSecretFile(path, value string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("create secret file: %w", err)
+	}
+
+	if _, err := file.WriteString(value + "\n"); err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("write secret file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("write secret file: %w", err)
+	}
+
+	return nil
+}
+
+// clients returns the listener.rfc2136.clients sequence, it's created if asked.
+func (f *ClientsFile) clients(create bool) *yaml.Node {
+	node := f.doc.Content[0]
+	for _, key := range []string{"listener", "rfc2136", "clients"} {
+		next := mappingValue(node, key)
+		if next == nil {
+			if !create {
+				return nil
+			}
+
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if key == "clients" {
+				next = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			}
+			setKey(node, key, next)
+		}
+
+		node = next
+	}
+
+	if node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	return node
+}
+
+func (f *ClientsFile) find(name string) *yaml.Node {
+	clients := f.clients(false)
+	if clients == nil {
+		return nil
+	}
+
+	for _, n := range clients.Content {
+		if v := mappingValue(n, "name"); v != nil && dns.Fqdn(v.Value) == dns.Fqdn(name) {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func setKey(node *yaml.Node, key string, value *yaml.Node) {
+	node.Content = append(node.Content, strNode(key), value)
+}
+
+func strNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func strsNode(values []string) *yaml.Node {
+	out := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, v := range values {
+		out.Content = append(out.Content, strNode(v))
+	}
+
+	return out
+}
@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/config"
+	"github.com/buglloc/DNSGateway/internal/secret"
+)
+
+const newSecret = "ZOvYYHzkbda/XAF+i8U2H0qD9E5BQlzw6seSNB3TQcQ="
+
+func TestClientsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("# keep me\n"+validConfig), 0o640))
+
+	f, err := config.OpenClientsFile(path)
+	require.NoError(t, err)
+
+	require.Error(t, f.Add(config.Client{Name: "tst."}))
+	require.NoError(t, f.Add(config.Client{
+		Name:       "acme.",
+		Secret:     secret.Ref{Value: newSecret},
+		XFRAllowed: true,
+		Zones:      []string{"acme.example.com."},
+	}))
+	require.NoError(t, f.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "# keep me\n")
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Listener.RFC2136.Clients, 2)
+	acme := cfg.Listener.RFC2136.Clients[1]
+	require.Equal(t, "acme.", acme.Name)
+	require.Equal(t, newSecret, acme.Secret.Value)
+	require.True(t, acme.XFRAllowed)
+
+	// rotate and remove
+	f, err = config.OpenClientsFile(path)
+	require.NoError(t, err)
+	where, err := f.SetSecret("tst", newSecret)
+	require.NoError(t, err)
+	require.Equal(t, path, where)
+	require.NoError(t, f.Remove("acme."))
+	require.Error(t, f.Remove("acme."))
+	require.NoError(t, f.Save())
+
+	cfg, err = config.LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Listener.RFC2136.Clients, 1)
+	require.Equal(t, newSecret, cfg.Listener.RFC2136.Clients[0].Secret.Value)
+
+	// the invalid result is never saved
+	f, err = config.OpenClientsFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Remove("tst."))
+	require.Error(t, f.Save())
+
+	cfg, err = config.LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Listener.RFC2136.Clients, 1)
+}
+
+func TestClientsFileSecretRef(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "tst.key")
+	require.NoError(t, config.CreateSecretFile(keyPath, newSecret))
+	require.ErrorIs(t, config.CreateSecretFile(keyPath, newSecret), os.ErrExist)
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(validConfig), 0o600))
+
+	f, err := config.OpenClientsFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Add(config.Client{
+		Name:   "file.",
+		Secret: secret.Ref{File: keyPath},
+		Zones:  []string{"file.example.com."},
+	}))
+	require.NoError(t, f.Save())
+
+	f, err = config.OpenClientsFile(path)
+	require.NoError(t, err)
+	rotated := "p+hDz1gyvfnDxkS7zPU26nY0ZeUm5L4+EcpTn1muQM0="
+	where, err := f.SetSecret("file.", rotated)
+	require.NoError(t, err)
+	require.Equal(t, keyPath, where)
+
+	// the secret file is replaced only along with the valid config
+	data, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, newSecret+"\n", string(data))
+	_, err = f.SetSecret("tst.", "not-a-base64-secret")
+	require.NoError(t, err)
+	require.Error(t, f.Save())
+
+	data, err = os.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, newSecret+"\n", string(data))
+
+	f, err = config.OpenClientsFile(path)
+	require.NoError(t, err)
+	_, err = f.SetSecret("file.", rotated)
+	require.NoError(t, err)
+	require.NoError(t, f.Save())
+
+	data, err = os.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, rotated+"\n", string(data))
+
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	runtime, err := cfg.NewRuntime()
+	require.NoError(t, err)
+	cl, err := runtime.Client("file.")
+	require.NoError(t, err)
+	require.Equal(t, rotated, cl.Secret.Reveal())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "temporary files are left")
+}
+
+func TestClientsFileSecretRestore(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "tst.key")
+	require.NoError(t, config.CreateSecretFile(keyPath, newSecret))
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(validConfig), 0o600))
+
+	f, err := config.OpenClientsFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Add(config.Client{
+		Name:   "file.",
+		Secret: secret.Ref{File: keyPath},
+		Zones:  []string{"file.example.com."},
+	}))
+	require.NoError(t, f.Save())
+
+	f, err = config.OpenClientsFile(path)
+	require.NoError(t, err)
+	_, err = f.SetSecret("file.", "p+hDz1gyvfnDxkS7zPU26nY0ZeUm5L4+EcpTn1muQM0=")
+	require.NoError(t, err)
+
+	// the config can't be renamed over the directory
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Mkdir(path, 0o700))
+	require.Error(t, f.Save())
+
+	data, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, newSecret+"\n", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "temporary files are left")
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
@@ -12,14 +15,17 @@ import (
 type Config struct {
 	Listener Listener `koanf:"listener"`
 	Upstream Upstream `koanf:"upstream"`
-}
-
-func (c *Config) Validate() error {
-	return nil
+	Metrics  Metrics  `koanf:"metrics"`
+	Tracing  Tracing  `koanf:"tracing"`
+	Audit    Audit    `koanf:"audit"`
+	History  History  `koanf:"history"`
+	Notify   Notify   `koanf:"notify"`
 }
 
 type Runtime struct {
 	cfg *Config
+	// closers are the created upstream backends holding resources, e.g. the sqlite database
+	closers []io.Closer
 }
 
 func LoadConfig(files ...string) (*Config, error) {
@@ -52,11 +58,29 @@ func LoadConfig(files ...string) (*Config, error) {
 }
 
 func (c *Config) NewRuntime() (*Runtime, error) {
+	// secrets are validated too, so they are resolved first
+	if err := c.resolveSecrets(context.Background()); err != nil {
+		return nil, err
+	}
+
 	if err := c.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+		return nil, err
 	}
 
 	return &Runtime{
 		cfg: c,
 	}, nil
 }
+
+// Close releases resources of upstreams created by the runtime, it's called once they are not used anymore.
+func (r *Runtime) Close() error {
+	var errs []error
+	for _, closer := range r.closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	r.closers = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/buglloc/DNSGateway/internal/history"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+type History struct {
+	Dir  string `koanf:"dir"`
+	Keep int    `koanf:"keep"`
+}
+
+func (h *History) Validate() error {
+	if h.Keep < 0 {
+		return fieldErrorf("keep", "must be non-negative")
+	}
+
+	return nil
+}
+
+// historyName names the history of the main upstream after its kind.
+func (u *Upstream) historyName() string {
+	return string(u.Kind)
+}
+
+// reverseHistoryName names the history of the auto_ptr reverse upstream, so it never mixes with the main one.
+func (u *Upstream) reverseHistoryName() string {
+	return "reverse-" + string(u.Kind)
+}
+
+// HistoryNames returns names of the configured upstream histories, the main one first.
+func (r *Runtime) HistoryNames() []string {
+	out := []string{r.cfg.Upstream.historyName()}
+	if reverse := r.cfg.Upstream.AutoPTR.Upstream; r.cfg.Upstream.AutoPTR.Enabled && reverse != nil {
+		out = append(out, reverse.reverseHistoryName())
+	}
+
+	return out
+}
+
+// NewHistoryUpstream returns the named upstream history together with the bare (without auto PTR management)
+// upstream to restore it through. Empty name means the main upstream.
+func (r *Runtime) NewHistoryUpstream(name string) (*history.Store, upstream.Upstream, error) {
+	if r.cfg.History.Dir == "" {
+		return nil, nil, errors.New("history is not configured: history.dir is empty")
+	}
+
+	names := r.HistoryNames()
+	if name == "" {
+		name = names[0]
+	}
+
+	if !slices.Contains(names, name) {
+		return nil, nil, fmt.Errorf("unknown upstream %q, configured ones: %v", name, names)
+	}
+
+	cfg := r.cfg.Upstream
+	if name != names[0] {
+		cfg = *cfg.AutoPTR.Upstream
+	}
+
+	upc, err := r.newUpstream(cfg, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := r.newHistoryStore(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return store, upc, nil
+}
+
+func (r *Runtime) newHistoryStore(name string) (*history.Store, error) {
+	store, err := history.NewStore(r.cfg.History.Dir, name, r.cfg.History.Keep)
+	if err != nil {
+		return nil, fmt.Errorf("open %q history: %w", name, err)
+	}
+
+	return store, nil
+}
@@ -1,14 +1,21 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
+	"slices"
 	"strings"
+	"time"
 
 	_ "github.com/knadh/koanf/v2"
+	"github.com/miekg/dns"
 
+	"github.com/buglloc/DNSGateway/internal/lease"
 	"github.com/buglloc/DNSGateway/internal/listener"
 	"github.com/buglloc/DNSGateway/internal/listener/lrfc2136"
+	"github.com/buglloc/DNSGateway/internal/secret"
 	"github.com/buglloc/DNSGateway/internal/upstream"
 )
 
@@ -35,44 +42,174 @@ func (k ListenerKind) MarshalText() ([]byte, error) {
 	return []byte(k), nil
 }
 
+type ClientScope struct {
+	Clients []string `koanf:"clients"`
+	CTags   []string `koanf:"ctags"`
+}
+
+type NameMapping struct {
+	From string `koanf:"from"`
+	To   string `koanf:"to"`
+}
+
 type Client struct {
-	Name       string   `koanf:"name"`
-	Secret     string   `koanf:"secret"`
-	XFRAllowed bool     `koanf:"xfr_allowed"`
-	AutoDelete bool     `koanf:"auto_delete"`
-	Zones      []string `koanf:"zones"`
-	Types      []string `koanf:"types"`
+	Name       string        `koanf:"name"`
+	Secret     secret.Ref    `koanf:"secret"`
+	XFRAllowed bool          `koanf:"xfr_allowed"`
+	AutoDelete bool          `koanf:"auto_delete"`
+	Takeover   bool          `koanf:"takeover"`
+	Negative   bool          `koanf:"negative"`
+	Lease      time.Duration `koanf:"lease"`
+	MaxLease   time.Duration `koanf:"max_lease"`
+	Zones      []string      `koanf:"zones"`
+	Types      []string      `koanf:"types"`
+	Scope      ClientScope   `koanf:"scope"`
+	NameMap    []NameMapping `koanf:"name_map"`
+	DryRun     bool          `koanf:"dry_run"`
+}
+
+type Leases struct {
+	StateFile string        `koanf:"state_file"`
+	Interval  time.Duration `koanf:"interval"`
 }
 
 type RFC2136Listener struct {
-	Addr    string   `koanf:"addr"`
-	Nets    []string `koanf:"nets"`
-	Clients []Client `koanf:"clients"`
+	Addr        string        `koanf:"addr"`
+	Nets        []string      `koanf:"nets"`
+	Clients     []Client      `koanf:"clients"`
+	PublicZones []string      `koanf:"public_zones"`
+	SOAMName    string        `koanf:"soa_mname"`
+	Leases      Leases        `koanf:"leases"`
+	NameMap     []NameMapping `koanf:"name_map"`
+	DryRun      bool          `koanf:"dry_run"`
 }
 
+// rfc2136Nets are the supported listener networks.
+var rfc2136Nets = []string{"udp", "udp4", "udp6", "tcp", "tcp4", "tcp6"}
+
 type Listener struct {
 	Kind    ListenerKind    `koanf:"kind"`
 	RFC2136 RFC2136Listener `koanf:"rfc2136"`
 }
 
+func (l *Listener) Validate() error {
+	if l.Kind != ListenerKindRFC2136 {
+		return fieldErrorf("kind", "unsupported listener kind: %q", l.Kind)
+	}
+
+	return nest("rfc2136", l.RFC2136.Validate())
+}
+
 func (l *RFC2136Listener) Validate() error {
+	var errs []error
 	if l.Addr == "" {
-		return errors.New("addr is empty")
+		errs = append(errs, fieldErrorf("addr", "is empty"))
+	} else if _, _, err := net.SplitHostPort(l.Addr); err != nil {
+		errs = append(errs, fieldError("addr", err))
+	}
+
+	if len(l.Nets) == 0 {
+		errs = append(errs, fieldErrorf("nets", "at least one net is required"))
+	}
+
+	for i, n := range l.Nets {
+		if !slices.Contains(rfc2136Nets, n) {
+			errs = append(errs, fieldErrorf(indexPath("nets", i), "unknown net %q, expected one of: %s", n, strings.Join(rfc2136Nets, ", ")))
+		}
 	}
 
-	names := make(map[string]struct{})
-	for _, cl := range l.Clients {
-		_, exists := names[cl.Name]
-		if exists {
-			return fmt.Errorf("duplicate client name: %s", cl.Name)
+	errs = append(errs, validateZones("public_zones", l.PublicZones))
+	if l.SOAMName != "" {
+		if err := validateZone(l.SOAMName); err != nil {
+			errs = append(errs, fieldError("soa_mname", err))
 		}
-		names[cl.Name] = struct{}{}
+	}
+	errs = append(errs, validateNameMap(l.NameMap))
+
+	if l.Leases.Interval < 0 {
+		errs = append(errs, fieldErrorf("leases.interval", "must be non-negative"))
+	}
+
+	if len(l.Clients) == 0 {
+		errs = append(errs, fieldErrorf("clients", "at least one client is required"))
+	}
 
-		if len(cl.Secret) < 32 {
-			return fmt.Errorf("invalid client %q secret: too short: 32 chars min", cl.Name)
+	names := make(map[string]int)
+	for i := range l.Clients {
+		cl := &l.Clients[i]
+		path := indexPath("clients", i)
+		if prev, exists := names[cl.Name]; exists {
+			errs = append(errs, fieldErrorf(path+".name", "duplicate client name %q, see %s", cl.Name, indexPath("clients", prev)))
 		}
+		names[cl.Name] = i
+
+		errs = append(errs, nest(path, cl.validate(l)))
 	}
-	return nil
+
+	return errors.Join(errs...)
+}
+
+func (c *Client) validate(l *RFC2136Listener) error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, fieldErrorf("name", "is empty"))
+	} else if !dns.IsFqdn(c.Name) {
+		// TSIG key names are always FQDN, so the client would never match
+		errs = append(errs, fieldErrorf("name", "client name %q is not FQDN, use %q", c.Name, dns.Fqdn(c.Name)))
+	}
+
+	secretValue := c.Secret.Reveal()
+	switch {
+	case secretValue == "":
+		errs = append(errs, fieldErrorf("secret", "is empty"))
+	case len(secretValue) < 32:
+		errs = append(errs, fieldErrorf("secret", "too short: 32 chars min"))
+	default:
+		if _, err := base64.StdEncoding.DecodeString(secretValue); err != nil {
+			errs = append(errs, fieldErrorf("secret", "not a valid base64: %w", err))
+		}
+	}
+
+	if len(c.Zones) == 0 {
+		errs = append(errs, fieldErrorf("zones", "at least one zone is required"))
+	}
+	errs = append(errs, validateZones("zones", c.Zones))
+
+	for i, typ := range c.Types {
+		if _, err := upstream.ParseType(typ); err != nil {
+			errs = append(errs, fieldError(indexPath("types", i), err))
+		}
+	}
+
+	if c.Lease < 0 {
+		errs = append(errs, fieldErrorf("lease", "must be non-negative"))
+	}
+
+	if c.MaxLease < 0 {
+		errs = append(errs, fieldErrorf("max_lease", "must be non-negative"))
+	}
+
+	if c.MaxLease > 0 && c.Lease > c.MaxLease {
+		errs = append(errs, fieldErrorf("lease", "%s exceeds max_lease %s", c.Lease, c.MaxLease))
+	}
+
+	if (c.Lease > 0 || c.MaxLease > 0) && l.Leases.StateFile == "" {
+		errs = append(errs, fieldErrorf("lease", "lease is configured, but listener.rfc2136.leases.state_file is empty"))
+	}
+
+	errs = append(errs, validateNameMap(c.NameMap))
+	return errors.Join(errs...)
+}
+
+func validateNameMap(mappings []NameMapping) error {
+	var errs []error
+	for i, m := range mappings {
+		if _, err := lrfc2136.NewNameMapper(nameMappings([]NameMapping{m})...); err != nil {
+			errs = append(errs, fieldError(indexPath("name_map", i), err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 func (r *Runtime) NewListener() (listener.Listener, error) {
@@ -89,7 +226,63 @@ func (r *Runtime) NewListener() (listener.Listener, error) {
 	}
 }
 
+// NewRFC2136Listener creates the RFC 2136 listener, e.g. to apply updates without serving them.
+func (r *Runtime) NewRFC2136Listener() (*lrfc2136.Listener, error) {
+	if r.cfg.Listener.Kind != ListenerKindRFC2136 {
+		return nil, fmt.Errorf("configured listener is not rfc2136: %s", r.cfg.Listener.Kind)
+	}
+
+	u, err := r.NewUpstream()
+	if err != nil {
+		return nil, fmt.Errorf("create upstream for listener: %w", err)
+	}
+
+	return r.newRFC2136Listener(u, r.cfg.Listener.RFC2136)
+}
+
 func (r *Runtime) newRFC2136Listener(u upstream.Upstream, cfg RFC2136Listener) (*lrfc2136.Listener, error) {
+	lCfg, err := r.rfc2136Config(u, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog, err := r.NewAuditLogger()
+	if err != nil {
+		return nil, fmt.Errorf("create audit log: %w", err)
+	}
+
+	if auditLog != nil {
+		lCfg.Audit(auditLog)
+	}
+
+	notifier, err := r.NewNotifier()
+	if err != nil {
+		return nil, fmt.Errorf("create notifier: %w", err)
+	}
+
+	if notifier != nil {
+		lCfg.Notifier(notifier)
+	}
+
+	if cfg.Leases.StateFile != "" {
+		store, err := r.NewLeaseStore()
+		if err != nil {
+			return nil, err
+		}
+
+		lCfg.Leases(store, cfg.Leases.Interval)
+	}
+
+	gw, err := lrfc2136.NewListener(lCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create rfc2136 listener: %w", err)
+	}
+
+	return gw, nil
+}
+
+// rfc2136Config builds the listener config without audit, notifier and leases, i.e. the part that may be reloaded.
+func (r *Runtime) rfc2136Config(u upstream.Upstream, cfg RFC2136Listener) (*lrfc2136.Config, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid rfc2136 config: %w", err)
 	}
@@ -101,26 +294,86 @@ func (r *Runtime) newRFC2136Listener(u upstream.Upstream, cfg RFC2136Listener) (
 		lCfg.Nets(cfg.Nets...)
 	}
 
+	if len(cfg.PublicZones) > 0 {
+		lCfg.PublicZones(cfg.PublicZones...)
+	}
+
+	if cfg.SOAMName != "" {
+		lCfg.SOAMName(cfg.SOAMName)
+	}
+
+	if cfg.DryRun {
+		lCfg.DryRun(true)
+	}
+
 	for _, cl := range cfg.Clients {
 		rrTypes, err := lrfc2136.ParseTypesSet(cl.Types)
 		if err != nil {
 			return nil, fmt.Errorf("invalid client %q types: %w", cl.Name, err)
 		}
 
+		// client mappings take precedence over the listener ones
+		nameMap, err := lrfc2136.NewNameMapper(nameMappings(cl.NameMap, cfg.NameMap)...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client %q name_map: %w", cl.Name, err)
+		}
+
 		lCfg.AppendClient(lrfc2136.Client{
 			Name:       cl.Name,
-			Secret:     cl.Secret,
+			Secret:     cl.Secret.Reveal(),
 			Zones:      cl.Zones,
 			XFRAllowed: cl.XFRAllowed,
 			AutoDelete: cl.AutoDelete,
+			Takeover:   cl.Takeover,
+			Negative:   cl.Negative,
+			Lease:      cl.Lease,
+			MaxLease:   cl.MaxLease,
 			Types:      rrTypes,
+			Scope:      upstream.NewScope(cl.Scope.Clients, cl.Scope.CTags),
+			NameMap:    nameMap,
+			DryRun:     cl.DryRun,
 		})
 	}
 
-	gw, err := lrfc2136.NewListener(lCfg)
+	return lCfg, nil
+}
+
+func nameMappings(lists ...[]NameMapping) []lrfc2136.NameMapping {
+	var out []lrfc2136.NameMapping
+	for _, list := range lists {
+		for _, m := range list {
+			out = append(out, lrfc2136.NameMapping{
+				From: m.From,
+				To:   m.To,
+			})
+		}
+	}
+
+	return out
+}
+
+// Client returns the configured rfc2136 client with its resolved secret, e.g. to sign requests on its behalf.
+func (r *Runtime) Client(name string) (Client, error) {
+	for _, cl := range r.cfg.Listener.RFC2136.Clients {
+		if dns.Fqdn(cl.Name) == dns.Fqdn(name) {
+			return cl, nil
+		}
+	}
+
+	return Client{}, fmt.Errorf("unknown client: %s", name)
+}
+
+// NewLeaseStore opens the state file with leases of the records created through the listener.
+func (r *Runtime) NewLeaseStore() (*lease.Store, error) {
+	path := r.cfg.Listener.RFC2136.Leases.StateFile
+	if path == "" {
+		return nil, errors.New("leases are not configured: listener.rfc2136.leases.state_file is empty")
+	}
+
+	store, err := lease.NewStore(path)
 	if err != nil {
-		return nil, fmt.Errorf("create rfc2136 listener: %w", err)
+		return nil, fmt.Errorf("open leases state: %w", err)
 	}
 
-	return gw, nil
+	return store, nil
 }
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+)
+
+type Metrics struct {
+	Addr string `koanf:"addr"`
+	Path string `koanf:"path"`
+}
+
+func (m *Metrics) Validate() error {
+	var errs []error
+	if m.Addr != "" {
+		if _, _, err := net.SplitHostPort(m.Addr); err != nil {
+			errs = append(errs, fieldError("addr", err))
+		}
+	}
+
+	if m.Path != "" && !strings.HasPrefix(m.Path, "/") {
+		errs = append(errs, fieldErrorf("path", "must start with /"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewMetricsServer creates Prometheus metrics server, if metrics.addr is configured.
+func (r *Runtime) NewMetricsServer() *metrics.Server {
+	if r.cfg.Metrics.Addr == "" {
+		return nil
+	}
+
+	return metrics.NewServer(r.cfg.Metrics.Addr, r.cfg.Metrics.Path)
+}
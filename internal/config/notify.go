@@ -0,0 +1,228 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/notify"
+	"github.com/buglloc/DNSGateway/internal/secret"
+)
+
+type NotifySinkKind string
+
+const (
+	NotifySinkKindNone    NotifySinkKind = ""
+	NotifySinkKindWebhook NotifySinkKind = "webhook"
+	NotifySinkKindFile    NotifySinkKind = "file"
+	NotifySinkKindExec    NotifySinkKind = "exec"
+)
+
+func (k *NotifySinkKind) UnmarshalText(data []byte) error {
+	switch strings.ToLower(string(data)) {
+	case "", "none":
+		*k = NotifySinkKindNone
+	case "webhook":
+		*k = NotifySinkKindWebhook
+	case "file":
+		*k = NotifySinkKindFile
+	case "exec":
+		*k = NotifySinkKindExec
+	default:
+		return fmt.Errorf("invalid notify sink kind: %s", string(data))
+	}
+	return nil
+}
+
+func (k NotifySinkKind) MarshalText() ([]byte, error) {
+	return []byte(k), nil
+}
+
+type NotifyWebhook struct {
+	URL        string            `koanf:"url"`
+	HMACSecret secret.Ref        `koanf:"hmac_secret"`
+	Headers    map[string]string `koanf:"headers"`
+}
+
+type NotifyFile struct {
+	Path string `koanf:"path"`
+}
+
+type NotifyExec struct {
+	Command []string `koanf:"command"`
+}
+
+type NotifyFilter struct {
+	Zones   []string `koanf:"zones"`
+	Clients []string `koanf:"clients"`
+	Types   []string `koanf:"types"`
+	Actions []string `koanf:"actions"`
+}
+
+type NotifySink struct {
+	Name         string         `koanf:"name"`
+	Kind         NotifySinkKind `koanf:"kind"`
+	Webhook      NotifyWebhook  `koanf:"webhook"`
+	File         NotifyFile     `koanf:"file"`
+	Exec         NotifyExec     `koanf:"exec"`
+	Filter       NotifyFilter   `koanf:"filter"`
+	QueueSize    int            `koanf:"queue_size"`
+	Retries      *int           `koanf:"retries"`
+	RetryWait    time.Duration  `koanf:"retry_wait"`
+	RetryMaxWait time.Duration  `koanf:"retry_max_wait"`
+	Timeout      time.Duration  `koanf:"timeout"`
+}
+
+type Notify struct {
+	Sinks []NotifySink `koanf:"sinks"`
+}
+
+func (n *Notify) Validate() error {
+	var errs []error
+	names := make(map[string]int, len(n.Sinks))
+	for i := range n.Sinks {
+		s := &n.Sinks[i]
+		path := indexPath("sinks", i)
+		name := s.name(i)
+		if prev, ok := names[name]; ok {
+			errs = append(errs, fieldErrorf(path+".name", "duplicate sink name %q, see %s", name, indexPath("sinks", prev)))
+		}
+		names[name] = i
+
+		errs = append(errs, nest(path, s.Validate()))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *NotifySink) Validate() error {
+	var errs []error
+	switch s.Kind {
+	case NotifySinkKindWebhook:
+		if err := validateURL(s.Webhook.URL); err != nil {
+			errs = append(errs, fieldError("webhook.url", err))
+		}
+	case NotifySinkKindFile:
+		if s.File.Path == "" {
+			errs = append(errs, fieldErrorf("file.path", "is empty"))
+		}
+	case NotifySinkKindExec:
+		if len(s.Exec.Command) == 0 {
+			errs = append(errs, fieldErrorf("exec.command", "is empty"))
+		}
+	default:
+		errs = append(errs, fieldErrorf("kind", "sink kind is required"))
+	}
+
+	if s.QueueSize < 0 {
+		errs = append(errs, fieldErrorf("queue_size", "must be non-negative"))
+	}
+
+	if s.Retries != nil && *s.Retries < 0 {
+		errs = append(errs, fieldErrorf("retries", "must be non-negative"))
+	}
+
+	for i, zone := range s.Filter.Zones {
+		if _, ok := dns.IsDomainName(zone); !ok || zone == "" {
+			errs = append(errs, fieldErrorf(indexPath("filter.zones", i), "invalid domain name %q", zone))
+		}
+	}
+
+	for i, typ := range s.Filter.Types {
+		if _, ok := dns.StringToType[strings.ToUpper(typ)]; !ok {
+			errs = append(errs, fieldErrorf(indexPath("filter.types", i), "invalid type: %s", typ))
+		}
+	}
+
+	for i, action := range s.Filter.Actions {
+		if !notify.ValidAction(action) {
+			errs = append(errs, fieldErrorf(indexPath("filter.actions", i), "invalid action: %s", action))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// name defaults to the sink kind with its index, e.g. "webhook#0".
+func (s *NotifySink) name(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+
+	return fmt.Sprintf("%s#%d", s.Kind, i)
+}
+
+// NewNotifier creates the notifier of committed changes, it's nil if no sinks are configured.
+func (r *Runtime) NewNotifier() (*notify.Notifier, error) {
+	cfg := r.cfg.Notify
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid notify config: %w", err)
+	}
+
+	if len(cfg.Sinks) == 0 {
+		return nil, nil
+	}
+
+	targets := make([]notify.Target, 0, len(cfg.Sinks))
+	for i, s := range cfg.Sinks {
+		sink, err := newNotifySink(s)
+		if err != nil {
+			for _, t := range targets {
+				_ = t.Sink.Close()
+			}
+
+			return nil, fmt.Errorf("create %q notify sink: %w", s.name(i), err)
+		}
+
+		retries := notify.DefaultRetries
+		if s.Retries != nil {
+			retries = *s.Retries
+		}
+
+		zones := make([]string, len(s.Filter.Zones))
+		for j, zone := range s.Filter.Zones {
+			zones[j] = dns.Fqdn(zone)
+		}
+
+		targets = append(targets, notify.Target{
+			Name: s.name(i),
+			Sink: sink,
+			Filter: notify.Filter{
+				Zones:   zones,
+				Clients: s.Filter.Clients,
+				Types:   s.Filter.Types,
+				Actions: s.Filter.Actions,
+			},
+			QueueSize:    s.QueueSize,
+			Retries:      retries,
+			RetryWait:    s.RetryWait,
+			RetryMaxWait: s.RetryMaxWait,
+			Timeout:      s.Timeout,
+		})
+	}
+
+	return notify.NewNotifier(string(r.cfg.Upstream.Kind), targets...), nil
+}
+
+func newNotifySink(cfg NotifySink) (notify.Sink, error) {
+	switch cfg.Kind {
+	case NotifySinkKindWebhook:
+		opts := []notify.WebhookOption{
+			notify.WithHMACSecret(cfg.Webhook.HMACSecret.Reveal()),
+		}
+		for name, value := range cfg.Webhook.Headers {
+			opts = append(opts, notify.WithHeader(name, value))
+		}
+
+		return notify.NewWebhookSink(cfg.Webhook.URL, opts...)
+	case NotifySinkKindFile:
+		return notify.NewFileSink(cfg.File.Path)
+	case NotifySinkKindExec:
+		return notify.NewExecSink(cfg.Exec.Command...)
+	default:
+		return nil, fmt.Errorf("unsupported notify sink kind: %s", cfg.Kind)
+	}
+}
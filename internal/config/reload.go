@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/knadh/koanf/providers/file"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/listener"
+	"github.com/buglloc/DNSGateway/internal/listener/lrfc2136"
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+const watchDebounce = 500 * time.Millisecond
+
+// Reload loads config files again and applies them to the running listener. The invalid config is rejected
+// and the current runtime is kept. The outcome is logged and reported to metrics.
+func Reload(current *Runtime, l listener.Listener, files ...string) (*Runtime, error) {
+	next, err := reload(current, l, files...)
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues("failed").Inc()
+		metrics.ConfigLastReloadSuccess.Set(0)
+		log.Error().Err(err).Msg("config reload failed, keep the previous one")
+		return current, err
+	}
+
+	metrics.ConfigReloads.WithLabelValues("ok").Inc()
+	metrics.ConfigLastReloadSuccess.Set(1)
+	if changed := next.RestartRequired(current); len(changed) > 0 {
+		log.Warn().Strs("settings", changed).Msg("config reloaded, but some changed settings are applied on restart only")
+	} else {
+		log.Info().Msg("config reloaded")
+	}
+
+	return next, nil
+}
+
+func reload(current *Runtime, l listener.Listener, files ...string) (*Runtime, error) {
+	cfg, err := LoadConfig(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := cfg.NewRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := next.ReloadListener(l, current); err != nil {
+		if closeErr := next.Close(); closeErr != nil {
+			log.Warn().Err(closeErr).Msg("unable to close upstream of the rejected config")
+		}
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// ReloadListener applies clients, public zones, the dry-run mode and the upstream to the running listener.
+// The upstream is recreated only if its settings differ from the prev runtime ones, the replaced one is closed
+// once requests started with it are done. Otherwise the running upstream is handed over to this runtime.
+func (r *Runtime) ReloadListener(l listener.Listener, prev *Runtime) error {
+	rl, ok := l.(*lrfc2136.Listener)
+	if !ok || r.cfg.Listener.Kind != ListenerKindRFC2136 {
+		return fmt.Errorf("reload is not supported by the %q listener", r.cfg.Listener.Kind)
+	}
+
+	var u upstream.Upstream
+	if !reflect.DeepEqual(prev.cfg.Upstream, r.cfg.Upstream) || prev.cfg.History != r.cfg.History {
+		var err error
+		u, err = r.NewUpstream()
+		if err != nil {
+			return fmt.Errorf("create upstream for listener: %w", err)
+		}
+	}
+
+	lCfg, err := r.rfc2136Config(u, r.cfg.Listener.RFC2136)
+	if err != nil {
+		return err
+	}
+
+	wait, err := rl.Reload(lCfg)
+	if err != nil {
+		return err
+	}
+
+	if u == nil {
+		r.closers, prev.closers = prev.closers, nil
+		return nil
+	}
+
+	// requests already in progress still use the replaced upstream, e.g. a long zone transfer
+	go func() {
+		wait()
+		if err := prev.Close(); err != nil {
+			log.Warn().Err(err).Msg("unable to close the replaced upstream")
+		}
+	}()
+
+	return nil
+}
+
+// RestartRequired returns the changed since prev settings, which are not reloadable.
+func (r *Runtime) RestartRequired(prev *Runtime) []string {
+	cur, old := r.cfg, prev.cfg
+	checks := []struct {
+		name    string
+		changed bool
+	}{
+		{"listener.kind", cur.Listener.Kind != old.Listener.Kind},
+		{"listener.rfc2136.addr", cur.Listener.RFC2136.Addr != old.Listener.RFC2136.Addr},
+		{"listener.rfc2136.nets", !reflect.DeepEqual(cur.Listener.RFC2136.Nets, old.Listener.RFC2136.Nets)},
+		{"listener.rfc2136.leases", cur.Listener.RFC2136.Leases != old.Listener.RFC2136.Leases},
+		{"metrics", cur.Metrics != old.Metrics},
+		{"tracing", cur.Tracing != old.Tracing},
+		{"audit", cur.Audit != old.Audit},
+		{"notify", !reflect.DeepEqual(cur.Notify, old.Notify)},
+	}
+
+	var out []string
+	for _, c := range checks {
+		if c.changed {
+			out = append(out, c.name)
+		}
+	}
+
+	return out
+}
+
+// WatchFiles calls onChange once config files settle after a change. The returned function stops watching.
+func WatchFiles(files []string, onChange func()) (func(), error) {
+	var mu sync.Mutex
+	var timer *time.Timer
+	providers := make([]*file.File, 0, len(files))
+	stop := func() {
+		for _, p := range providers {
+			_ = p.Unwatch()
+		}
+	}
+
+	for _, fpath := range files {
+		p := file.Provider(fpath)
+		err := p.Watch(func(_ any, err error) {
+			if err != nil {
+				log.Error().Err(err).Str("path", fpath).Msg("config watch failed")
+				return
+			}
+
+			// editors tend to write a file in several steps
+			mu.Lock()
+			defer mu.Unlock()
+			if timer == nil {
+				timer = time.AfterFunc(watchDebounce, onChange)
+				return
+			}
+			timer.Reset(watchDebounce)
+		})
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("watch %q config: %w", fpath, err)
+		}
+
+		providers = append(providers, p)
+	}
+
+	return stop, nil
+}
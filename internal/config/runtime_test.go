@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/config"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+func TestRuntimeClose(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "records.db")
+	cfg := loadConfig(t, strings.Replace(validConfig, "/tmp/records.db", dbPath, 1))
+
+	runtime, err := cfg.NewRuntime()
+	require.NoError(t, err)
+
+	upc, err := runtime.NewUpstream()
+	require.NoError(t, err)
+
+	q := upstream.Rule{
+		Type:  dns.TypeAXFR,
+		Scope: upstream.AnyScope(),
+	}
+	_, err = upc.Query(context.Background(), q)
+	require.NoError(t, err)
+
+	require.NoError(t, runtime.Close())
+	_, err = upc.Query(context.Background(), q)
+	require.ErrorContains(t, err, "closed")
+
+	// nothing left to close
+	require.NoError(t, runtime.Close())
+}
+
+func TestReloadListenerCloses(t *testing.T) {
+	dir := t.TempDir()
+	newRuntime := func(dbName string) *config.Runtime {
+		data := strings.Replace(validConfig, "/tmp/records.db", filepath.Join(dir, dbName), 1)
+		runtime, err := loadConfig(t, data).NewRuntime()
+		require.NoError(t, err)
+		return runtime
+	}
+
+	first := newRuntime("first.db")
+	l, err := first.NewRFC2136Listener()
+	require.NoError(t, err)
+
+	rr, err := dns.NewRR(`a.example.com. 60 IN TXT "hello"`)
+	require.NoError(t, err)
+	dryRun := func() error {
+		_, err := l.DryRun(context.Background(), "tst.", []dns.RR{rr})
+		return err
+	}
+
+	// the unchanged upstream is handed over, so closing the previous runtime keeps it alive
+	second := newRuntime("first.db")
+	require.NoError(t, second.ReloadListener(l, first))
+	require.NoError(t, first.Close())
+	require.NoError(t, dryRun())
+
+	// the replaced one is closed, while the listener keeps working with the new one
+	third := newRuntime("second.db")
+	require.NoError(t, third.ReloadListener(l, second))
+	require.NoError(t, dryRun())
+	require.NoError(t, third.Close())
+	require.ErrorContains(t, dryRun(), "closed")
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buglloc/DNSGateway/internal/secret"
+)
+
+type secretField struct {
+	path string
+	ref  *secret.Ref
+}
+
+// resolveSecrets reads every configured secret from its source, so the runtime uses plain values.
+func (c *Config) resolveSecrets(ctx context.Context) error {
+	var errs []error
+	for _, f := range c.secretFields() {
+		if f.ref.IsZero() {
+			continue
+		}
+
+		if err := f.ref.Resolve(ctx); err != nil {
+			errs = append(errs, fieldError(f.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) secretFields() []secretField {
+	var out []secretField
+	for i := range c.Listener.RFC2136.Clients {
+		cl := &c.Listener.RFC2136.Clients[i]
+		out = append(out, secretField{
+			path: indexPath("listener.rfc2136.clients", i) + ".secret",
+			ref:  &cl.Secret,
+		})
+	}
+
+	out = append(out, c.Upstream.secretFields("upstream")...)
+
+	for i := range c.Notify.Sinks {
+		s := &c.Notify.Sinks[i]
+		if s.Kind != NotifySinkKindWebhook {
+			continue
+		}
+
+		out = append(out, secretField{
+			path: indexPath("notify.sinks", i) + ".webhook.hmac_secret",
+			ref:  &s.Webhook.HMACSecret,
+		})
+	}
+
+	return out
+}
+
+// secretFields of the upstream are limited to its kind, so settings of unused kinds may stay in the config.
+func (u *Upstream) secretFields(prefix string) []secretField {
+	var out []secretField
+	switch u.Kind {
+	case UpstreamKindAdGuard:
+		out = append(out, secretField{path: prefix + ".adguard.password", ref: &u.Adguard.Password})
+	case UpstreamKindCloudflare:
+		out = append(out, secretField{path: prefix + ".cloudflare.token", ref: &u.Cloudflare.Token})
+	case UpstreamKindWebhook:
+		out = append(out, secretField{path: prefix + ".webhook.hmac_secret", ref: &u.Webhook.HMACSecret})
+	}
+
+	if u.AutoPTR.Enabled && u.AutoPTR.Upstream != nil {
+		out = append(out, u.AutoPTR.Upstream.secretFields(prefix+".auto_ptr.upstream")...)
+	}
+
+	return out
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/buglloc/DNSGateway/internal/tracing"
+)
+
+type Tracing struct {
+	Exporter    string  `koanf:"exporter"`
+	Endpoint    string  `koanf:"endpoint"`
+	ServiceName string  `koanf:"service_name"`
+	SampleRatio float64 `koanf:"sample_ratio"`
+}
+
+func (t *Tracing) Validate() error {
+	var errs []error
+	if _, err := tracing.ParseExporter(t.Exporter); err != nil {
+		errs = append(errs, fieldError("exporter", err))
+	}
+
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, fieldErrorf("sample_ratio", "must be within [0, 1]"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewTracingProvider creates and installs the global tracer provider, if tracing.exporter is configured.
+func (r *Runtime) NewTracingProvider(ctx context.Context) (*tracing.Provider, error) {
+	cfg := r.cfg.Tracing
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tracing config: %w", err)
+	}
+
+	exporter, _ := tracing.ParseExporter(cfg.Exporter)
+	if exporter == tracing.ExporterNone {
+		return nil, nil
+	}
+
+	return tracing.NewProvider(ctx,
+		tracing.WithExporter(exporter),
+		tracing.WithEndpoint(cfg.Endpoint),
+		tracing.WithServiceName(cfg.ServiceName),
+		tracing.WithSampleRatio(cfg.SampleRatio),
+	)
+}
@@ -1,13 +1,25 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/secret"
 	"github.com/buglloc/DNSGateway/internal/upstream"
 	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+	"github.com/buglloc/DNSGateway/internal/upstream/uautoptr"
 	"github.com/buglloc/DNSGateway/internal/upstream/ucloudflare"
+	"github.com/buglloc/DNSGateway/internal/upstream/uhistory"
+	"github.com/buglloc/DNSGateway/internal/upstream/umetrics"
+	"github.com/buglloc/DNSGateway/internal/upstream/usqlite"
+	"github.com/buglloc/DNSGateway/internal/upstream/utracing"
+	"github.com/buglloc/DNSGateway/internal/upstream/uwebhook"
 )
 
 type UpstreamKind string
@@ -16,6 +28,8 @@ const (
 	UpstreamKindNone       UpstreamKind = ""
 	UpstreamKindAdGuard    UpstreamKind = "adguard"
 	UpstreamKindCloudflare UpstreamKind = "cloudflare"
+	UpstreamKindWebhook    UpstreamKind = "webhook"
+	UpstreamKindSQLite     UpstreamKind = "sqlite"
 )
 
 func (k *UpstreamKind) UnmarshalText(data []byte) error {
@@ -26,6 +40,10 @@ func (k *UpstreamKind) UnmarshalText(data []byte) error {
 		*k = UpstreamKindAdGuard
 	case "cloudflare":
 		*k = UpstreamKindCloudflare
+	case "webhook":
+		*k = UpstreamKindWebhook
+	case "sqlite":
+		*k = UpstreamKindSQLite
 	default:
 		return fmt.Errorf("invalid upstream kind: %s", string(data))
 	}
@@ -37,63 +55,316 @@ func (k UpstreamKind) MarshalText() ([]byte, error) {
 }
 
 type AdguardUpstream struct {
-	APIServerURL string `koanf:"api_server_url"`
-	Login        string `koanf:"login"`
-	Password     string `koanf:"password"`
-	AutoPTR      bool   `koanf:"auto_ptr"`
+	APIServerURL  string     `koanf:"api_server_url"`
+	Login         string     `koanf:"login"`
+	Password      secret.Ref `koanf:"password"`
+	AutoPTR       bool       `koanf:"auto_ptr"`
+	Mode          string     `koanf:"mode"`
+	OwnershipFile string     `koanf:"ownership_file"`
 }
 
 type CloudflareUpstream struct {
-	ZoneID string `koanf:"zone_id"`
-	Token  string `koanf:"token"`
+	ZoneID string     `koanf:"zone_id"`
+	Token  secret.Ref `koanf:"token"`
+}
+
+type WebhookUpstream struct {
+	ListURL      string            `koanf:"list_url"`
+	CommitURL    string            `koanf:"commit_url"`
+	HMACSecret   secret.Ref        `koanf:"hmac_secret"`
+	TTL          uint32            `koanf:"ttl"`
+	Retries      *int              `koanf:"retries"`
+	RetryWait    time.Duration     `koanf:"retry_wait"`
+	RetryMaxWait time.Duration     `koanf:"retry_max_wait"`
+	Headers      map[string]string `koanf:"headers"`
+}
+
+type SQLiteUpstream struct {
+	Path string `koanf:"path"`
+}
+
+type AutoPTR struct {
+	Enabled  bool      `koanf:"enabled"`
+	Zones    []string  `koanf:"zones"`
+	Policy   string    `koanf:"policy"`
+	Upstream *Upstream `koanf:"upstream"`
 }
 
 type Upstream struct {
 	Kind       UpstreamKind       `koanf:"kind"`
 	Adguard    AdguardUpstream    `koanf:"adguard"`
 	Cloudflare CloudflareUpstream `koanf:"cloudflare"`
+	Webhook    WebhookUpstream    `koanf:"webhook"`
+	SQLite     SQLiteUpstream     `koanf:"sqlite"`
+	AutoPTR    AutoPTR            `koanf:"auto_ptr"`
+}
+
+func (u *Upstream) Validate() error {
+	var errs []error
+	switch u.Kind {
+	case UpstreamKindAdGuard:
+		errs = append(errs, nest("adguard", u.Adguard.Validate()))
+	case UpstreamKindCloudflare:
+		errs = append(errs, nest("cloudflare", u.Cloudflare.Validate()))
+	case UpstreamKindWebhook:
+		errs = append(errs, nest("webhook", u.Webhook.Validate()))
+	case UpstreamKindSQLite:
+		errs = append(errs, nest("sqlite", u.SQLite.Validate()))
+	default:
+		errs = append(errs, fieldErrorf("kind", "upstream kind is required"))
+	}
+
+	if u.AutoPTR.Enabled || u.Kind == UpstreamKindAdGuard && u.Adguard.AutoPTR {
+		errs = append(errs, nest("auto_ptr", u.AutoPTR.Validate()))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *AutoPTR) Validate() error {
+	var errs []error
+	if _, err := uautoptr.ParsePolicy(a.Policy); err != nil {
+		errs = append(errs, fieldError("policy", err))
+	}
+
+	for i, zone := range a.Zones {
+		if err := validateZone(zone); err != nil {
+			errs = append(errs, fieldError(indexPath("zones", i), err))
+			continue
+		}
+
+		if !dns.IsSubDomain("in-addr.arpa.", zone) && !dns.IsSubDomain("ip6.arpa.", zone) {
+			errs = append(errs, fieldErrorf(indexPath("zones", i), "zone %q is not a reverse one", zone))
+		}
+	}
+
+	if a.Upstream != nil {
+		if a.Upstream.AutoPTR.Enabled {
+			errs = append(errs, fieldErrorf("upstream.auto_ptr", "auto_ptr can't be nested into the reverse upstream"))
+		}
+
+		errs = append(errs, nest("upstream", a.Upstream.Validate()))
+	}
+
+	return errors.Join(errs...)
 }
 
 func (u *AdguardUpstream) Validate() error {
-	if u.APIServerURL == "" {
-		return errors.New("addr is empty")
+	var errs []error
+	if err := validateURL(u.APIServerURL); err != nil {
+		errs = append(errs, fieldError("api_server_url", err))
 	}
 
-	return nil
+	mode, err := uadguard.ParseMode(u.Mode)
+	if err != nil {
+		errs = append(errs, fieldError("mode", err))
+	}
+
+	if mode == uadguard.ModeRewrites && u.OwnershipFile == "" {
+		errs = append(errs, fieldErrorf("ownership_file", "is required for rewrites mode"))
+	}
+
+	return errors.Join(errs...)
 }
 
 func (u *CloudflareUpstream) Validate() error {
+	var errs []error
 	if u.ZoneID == "" {
-		return errors.New("zone_id is empty")
+		errs = append(errs, fieldErrorf("zone_id", "is empty"))
 	}
 
-	if u.Token == "" {
-		return errors.New("token is empty")
+	if u.Token.Reveal() == "" {
+		errs = append(errs, fieldErrorf("token", "is empty"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (u *WebhookUpstream) Validate() error {
+	var errs []error
+	if err := validateURL(u.ListURL); err != nil {
+		errs = append(errs, fieldError("list_url", err))
+	}
+
+	if err := validateURL(u.CommitURL); err != nil {
+		errs = append(errs, fieldError("commit_url", err))
+	}
+
+	if u.Retries != nil && *u.Retries < 0 {
+		errs = append(errs, fieldErrorf("retries", "must be non-negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (u *SQLiteUpstream) Validate() error {
+	if u.Path == "" {
+		return fieldErrorf("path", "is empty")
 	}
 
 	return nil
 }
 
 func (r *Runtime) NewUpstream() (upstream.Upstream, error) {
-	switch r.cfg.Upstream.Kind {
+	cfg := r.cfg.Upstream
+	upc, err := r.newUpstream(cfg, cfg.historyName())
+	if err != nil {
+		return nil, err
+	}
+
+	// adguard.auto_ptr is kept for backward compatibility
+	autoPTR := cfg.AutoPTR
+	if cfg.Kind == UpstreamKindAdGuard && cfg.Adguard.AutoPTR {
+		autoPTR.Enabled = true
+	}
+
+	if !autoPTR.Enabled {
+		return upc, nil
+	}
+
+	return r.newAutoPTRUpstream(upc, autoPTR)
+}
+
+// CheckUpstreams queries records of the configured upstreams without any wrappers, e.g. history,
+// to check their connectivity and credentials.
+func (r *Runtime) CheckUpstreams(ctx context.Context) error {
+	type check struct {
+		path string
+		cfg  Upstream
+	}
+
+	checks := []check{
+		{path: "upstream", cfg: r.cfg.Upstream},
+	}
+	if reverse := r.cfg.Upstream.AutoPTR.Upstream; r.cfg.Upstream.AutoPTR.Enabled && reverse != nil {
+		checks = append(checks, check{path: "upstream.auto_ptr.upstream", cfg: *reverse})
+	}
+
+	var errs []error
+	for _, c := range checks {
+		if err := r.checkUpstream(ctx, c.cfg); err != nil {
+			errs = append(errs, fieldError(c.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Runtime) checkUpstream(ctx context.Context, cfg Upstream) error {
+	upc, err := r.newBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := upc.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	_, err = upc.Query(ctx, upstream.Rule{
+		Type:  dns.TypeAXFR,
+		Scope: upstream.AnyScope(),
+	})
+	if err != nil {
+		return fmt.Errorf("query records: %w", err)
+	}
+
+	return nil
+}
+
+// NewAdguardUpstream creates bare AdGuard Home upstream for maintenance commands.
+func (r *Runtime) NewAdguardUpstream() (*uadguard.Upstream, error) {
+	if r.cfg.Upstream.Kind != UpstreamKindAdGuard {
+		return nil, fmt.Errorf("configured upstream is not adguard: %s", r.cfg.Upstream.Kind)
+	}
+
+	return r.newAdguardUpstream(r.cfg.Upstream.Adguard)
+}
+
+func (r *Runtime) newUpstream(cfg Upstream, name string) (upstream.Upstream, error) {
+	upc, err := r.newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if closer, ok := upc.(io.Closer); ok {
+		r.closers = append(r.closers, closer)
+	}
+
+	if r.cfg.History.Dir != "" {
+		store, err := r.newHistoryStore(name)
+		if err != nil {
+			return nil, err
+		}
+
+		upc = uhistory.NewUpstream(upc, store)
+	}
+
+	backend := string(cfg.Kind)
+	return umetrics.NewUpstream(utracing.NewUpstream(upc, backend), backend), nil
+}
+
+func (r *Runtime) newBackend(cfg Upstream) (upstream.Upstream, error) {
+	switch cfg.Kind {
 	case UpstreamKindAdGuard:
-		return r.newAdguardUpstream(r.cfg.Upstream.Adguard)
+		return r.newAdguardUpstream(cfg.Adguard)
 	case UpstreamKindCloudflare:
-		return r.newCloudflareUpstream(r.cfg.Upstream.Cloudflare)
+		return r.newCloudflareUpstream(cfg.Cloudflare)
+	case UpstreamKindWebhook:
+		return r.newWebhookUpstream(cfg.Webhook)
+	case UpstreamKindSQLite:
+		return r.newSQLiteUpstream(cfg.SQLite)
 	default:
-		return nil, fmt.Errorf("unsupported upstream kind: %s", r.cfg.Upstream.Kind)
+		return nil, fmt.Errorf("unsupported upstream kind: %s", cfg.Kind)
 	}
 }
 
+func (r *Runtime) newAutoPTRUpstream(forward upstream.Upstream, cfg AutoPTR) (*uautoptr.Upstream, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid auto_ptr config: %w", err)
+	}
+
+	policy, err := uautoptr.ParsePolicy(cfg.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auto_ptr policy: %w", err)
+	}
+
+	opts := []uautoptr.Option{
+		uautoptr.WithZones(cfg.Zones...),
+		uautoptr.WithPolicy(policy),
+	}
+
+	if cfg.Upstream != nil {
+		reverse, err := r.newUpstream(*cfg.Upstream, cfg.Upstream.reverseHistoryName())
+		if err != nil {
+			return nil, fmt.Errorf("create reverse upstream: %w", err)
+		}
+
+		opts = append(opts, uautoptr.WithReverseUpstream(reverse))
+	}
+
+	gw, err := uautoptr.NewUpstream(forward, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create auto_ptr upstream: %w", err)
+	}
+
+	return gw, nil
+}
+
 func (r *Runtime) newAdguardUpstream(cfg AdguardUpstream) (*uadguard.Upstream, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid adguard config: %w", err)
 	}
 
+	mode, err := uadguard.ParseMode(cfg.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid adguard mode: %w", err)
+	}
+
 	gw, err := uadguard.NewUpstream(
 		uadguard.WithUpstream(cfg.APIServerURL),
-		uadguard.WithBasicAuth(cfg.Login, cfg.Password),
-		uadguard.WithAutoPTR(cfg.AutoPTR),
+		uadguard.WithBasicAuth(cfg.Login, cfg.Password.Reveal()),
+		uadguard.WithMode(mode),
+		uadguard.WithOwnershipFile(cfg.OwnershipFile),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("create adguard upstream: %w", err)
@@ -107,7 +378,7 @@ func (r *Runtime) newCloudflareUpstream(cfg CloudflareUpstream) (*ucloudflare.Up
 		return nil, fmt.Errorf("invalid cloudflare config: %w", err)
 	}
 
-	gw, err := ucloudflare.NewUpstream(cfg.Token,
+	gw, err := ucloudflare.NewUpstream(cfg.Token.Reveal(),
 		ucloudflare.WithZoneID(cfg.ZoneID),
 	)
 	if err != nil {
@@ -116,3 +387,46 @@ func (r *Runtime) newCloudflareUpstream(cfg CloudflareUpstream) (*ucloudflare.Up
 
 	return gw, nil
 }
+
+func (r *Runtime) newWebhookUpstream(cfg WebhookUpstream) (*uwebhook.Upstream, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+
+	opts := []uwebhook.Option{
+		uwebhook.WithListURL(cfg.ListURL),
+		uwebhook.WithCommitURL(cfg.CommitURL),
+		uwebhook.WithHMACSecret(cfg.HMACSecret.Reveal()),
+		uwebhook.WithTTL(cfg.TTL),
+	}
+	retries := uwebhook.DefaultRetries
+	if cfg.Retries != nil {
+		retries = *cfg.Retries
+	}
+	opts = append(opts, uwebhook.WithRetries(retries, cfg.RetryWait, cfg.RetryMaxWait))
+	for name, value := range cfg.Headers {
+		opts = append(opts, uwebhook.WithHeader(name, value))
+	}
+
+	gw, err := uwebhook.NewUpstream(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create webhook upstream: %w", err)
+	}
+
+	return gw, nil
+}
+
+func (r *Runtime) newSQLiteUpstream(cfg SQLiteUpstream) (*usqlite.Upstream, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sqlite config: %w", err)
+	}
+
+	gw, err := usqlite.NewUpstream(
+		usqlite.WithPath(cfg.Path),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create sqlite upstream: %w", err)
+	}
+
+	return gw, nil
+}
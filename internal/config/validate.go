@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// FieldError is the problem of the config field, e.g. "listener.rfc2136.clients[2].zones[0]".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func fieldError(path string, err error) error {
+	return &FieldError{
+		Path: path,
+		Err:  err,
+	}
+}
+
+func fieldErrorf(path string, format string, args ...any) error {
+	return fieldError(path, fmt.Errorf(format, args...))
+}
+
+// Problems splits the validation error into separate problems.
+func Problems(err error) []error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case interface{ Unwrap() []error }:
+		var out []error
+		for _, inner := range e.Unwrap() {
+			out = append(out, Problems(inner)...)
+		}
+		return out
+	default:
+		return []error{err}
+	}
+}
+
+// nest prefixes paths of problems in err with the parent path.
+func nest(prefix string, err error) error {
+	problems := Problems(err)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	out := make([]error, len(problems))
+	for i, p := range problems {
+		fe, ok := p.(*FieldError)
+		if !ok {
+			out[i] = fieldError(prefix, p)
+			continue
+		}
+
+		out[i] = fieldError(joinPath(prefix, fe.Path), fe.Err)
+	}
+
+	return errors.Join(out...)
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	default:
+		return prefix + "." + path
+	}
+}
+
+func indexPath(name string, i int) string {
+	return fmt.Sprintf("%s[%d]", name, i)
+}
+
+// validateZone checks the zone is a valid FQDN, i.e. has the trailing dot.
+func validateZone(zone string) error {
+	if _, ok := dns.IsDomainName(zone); !ok || zone == "" {
+		return fmt.Errorf("invalid domain name %q", zone)
+	}
+
+	if !dns.IsFqdn(zone) {
+		return fmt.Errorf("zone %q is not FQDN, use %q", zone, dns.Fqdn(zone))
+	}
+
+	return nil
+}
+
+// validateZones checks every zone, as well as that zones don't overlap: the first matching zone wins,
+// so the nested one would never be used.
+func validateZones(path string, zones []string) error {
+	var errs []error
+	for i, zone := range zones {
+		if err := validateZone(zone); err != nil {
+			errs = append(errs, fieldError(indexPath(path, i), err))
+			continue
+		}
+
+		for j, prev := range zones[:i] {
+			if dns.IsSubDomain(dns.Fqdn(prev), zone) || dns.IsSubDomain(zone, dns.Fqdn(prev)) {
+				errs = append(errs, fieldErrorf(indexPath(path, i), "zone %q overlaps with %s %q", zone, indexPath(path, j), prev))
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateURL(rawURL string) error {
+	if rawURL == "" {
+		return errors.New("is empty")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q: http or https is expected", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return errors.New("host is empty")
+	}
+
+	return nil
+}
+
+// Validate reports every problem of the config with its path. Secrets must be resolved beforehand.
+func (c *Config) Validate() error {
+	return errors.Join(
+		nest("listener", c.Listener.Validate()),
+		nest("upstream", c.Upstream.Validate()),
+		nest("metrics", c.Metrics.Validate()),
+		nest("tracing", c.Tracing.Validate()),
+		nest("audit", c.Audit.Validate()),
+		nest("history", c.History.Validate()),
+		nest("notify", c.Notify.Validate()),
+	)
+}
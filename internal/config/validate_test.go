@@ -0,0 +1,104 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/config"
+)
+
+const validConfig = `
+listener:
+  rfc2136:
+    addr: 127.0.0.1:5353
+    public_zones: [example.com.]
+    clients:
+      - name: tst.
+        secret: NzBjOTU4OTVlOTZlOTg5OGQwYTUxYTdjNWYzNTI3NzA5YjIyZTIxNWVjOTc3NWMxNzIxZjdjN2ExNjliNDc1ZCAgLQo=
+        zones: [example.com.]
+        types: [txt]
+upstream:
+  kind: sqlite
+  sqlite:
+    path: /tmp/records.db
+`
+
+const invalidConfig = `
+listener:
+  rfc2136:
+    addr: 127.0.0.1:5353
+    nets: [udp, quic]
+    public_zones: [example.com]
+    soa_mname: ns1.example.com
+    clients:
+      - name: tst
+        secret: not-a-base64-secret-not-a-base64-secret
+        zones: [example.com., sub.example.com.]
+        types: [txt, bogus]
+      - name: tst
+        secret: NzBjOTU4OTVlOTZlOTg5OGQwYTUxYTdjNWYzNTI3NzA5YjIyZTIxNWVjOTc3NWMxNzIxZjdjN2ExNjliNDc1ZCAgLQo=
+        zones: [example.com.]
+upstream:
+  kind: webhook
+  webhook:
+    list_url: http://127.0.0.1/records
+notify:
+  sinks:
+    - kind: file
+      filter:
+        actions: [nope]
+`
+
+func loadConfig(t *testing.T, data string) *config.Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	_, err := loadConfig(t, validConfig).NewRuntime()
+	require.NoError(t, err)
+
+	_, err = loadConfig(t, invalidConfig).NewRuntime()
+	require.Error(t, err)
+
+	var paths []string
+	for _, p := range config.Problems(err) {
+		var fe *config.FieldError
+		require.True(t, errors.As(p, &fe), p.Error())
+		paths = append(paths, fe.Path)
+	}
+
+	require.Equal(t, []string{
+		"listener.rfc2136.nets[1]",
+		"listener.rfc2136.public_zones[0]",
+		"listener.rfc2136.soa_mname",
+		"listener.rfc2136.clients[0].name",
+		"listener.rfc2136.clients[0].secret",
+		"listener.rfc2136.clients[0].zones[1]",
+		"listener.rfc2136.clients[0].types[1]",
+		"listener.rfc2136.clients[1].name",
+		"listener.rfc2136.clients[1].name",
+		"upstream.webhook.commit_url",
+		"notify.sinks[0].file.path",
+		"notify.sinks[0].filter.actions[0]",
+	}, paths)
+}
+
+func TestResolveSecretsPath(t *testing.T) {
+	cfg := loadConfig(t, validConfig)
+	cfg.Listener.RFC2136.Clients[0].Secret.Value = ""
+	cfg.Listener.RFC2136.Clients[0].Secret.Env = "TST_UNSET_SECRET"
+
+	_, err := cfg.NewRuntime()
+	require.ErrorContains(t, err, "listener.rfc2136.clients[0].secret: ")
+}
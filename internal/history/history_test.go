@@ -0,0 +1,132 @@
+package history_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/history"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uhistory"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+)
+
+func TestStore(t *testing.T) {
+	store, err := history.NewStore(t.TempDir(), "memory", 2)
+	require.NoError(t, err)
+
+	snap := func(rr string) history.Snapshot {
+		return history.Snapshot{
+			Records: []history.Record{{RR: rr}},
+		}
+	}
+
+	first, saved, err := store.Save(snap("a.example.com.\t0\tIN\tA\t1.2.3.4"))
+	require.NoError(t, err)
+	require.True(t, saved)
+	require.Equal(t, 1, first.ID)
+	require.Equal(t, "memory", first.Upstream)
+
+	same, saved, err := store.Save(snap("a.example.com.\t0\tIN\tA\t1.2.3.4"))
+	require.NoError(t, err)
+	require.False(t, saved, "same records must not be saved twice")
+	require.Equal(t, 1, same.ID)
+
+	for _, ip := range []string{"1.2.3.5", "1.2.3.6"} {
+		_, saved, err = store.Save(snap("a.example.com.\t0\tIN\tA\t" + ip))
+		require.NoError(t, err)
+		require.True(t, saved)
+	}
+
+	snaps, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+	require.Equal(t, 2, snaps[0].ID)
+	require.Equal(t, 3, snaps[1].ID)
+
+	_, err = store.Get(1)
+	require.ErrorIs(t, err, history.ErrNotFound)
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	store, err := history.NewStore(t.TempDir(), "memory", 0)
+	require.NoError(t, err)
+	upc := uhistory.NewUpstream(umemory.NewUpstream(), store)
+
+	scoped := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4", "certbot.")
+	scoped.Scope = upstream.NewScope([]string{"192.168.1.0/24"}, nil)
+	nxdomain, err := upstream.NewNegativeRule("hidden.example.com.", dns.TypeNone, upstream.NegativeNXDomain)
+	require.NoError(t, err)
+
+	commit(t, history.WithActor(ctx, "certbot."), upc, nil, []upstream.Rule{scoped, nxdomain})
+	// snapshot #1: empty, taken before the first commit
+	commit(t, history.WithActor(ctx, "admin."), upc, []upstream.Rule{scoped}, []upstream.Rule{
+		mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4", "admin."),
+		mustRule(t, "b.example.com.", dns.TypeTXT, "hello", ""),
+	})
+
+	snaps, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+	require.Empty(t, snaps[0].Records)
+	require.Equal(t, "admin.", snaps[1].Actor)
+
+	before, err := history.NewSnapshot(ctx, upc)
+	require.NoError(t, err)
+	require.Len(t, before.Records, 3)
+
+	tx, changes, err := history.Restore(ctx, upc, snaps[1])
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"- a.example.com.\t0\tIN\tA\t1.2.3.4 ; owner=admin.",
+		"- b.example.com.\t0\tIN\tTXT\t\"hello\"",
+		"+ a.example.com.\t0\tIN\tA\t1.2.3.4 ; client=192.168.1.0/24 ; owner=certbot.",
+	}, changes)
+	require.NoError(t, tx.Commit(ctx))
+	tx.Close()
+
+	after, err := history.NewSnapshot(ctx, upc)
+	require.NoError(t, err)
+	require.True(t, after.SameRecords(snaps[1]))
+
+	// the rollback itself is undoable
+	snaps, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, snaps, 3)
+	require.True(t, snaps[2].SameRecords(before))
+
+	tx, changes, err = history.Restore(ctx, upc, after)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+	tx.Close()
+}
+
+func commit(t *testing.T, ctx context.Context, upc upstream.Upstream, deleted, added []upstream.Rule) {
+	t.Helper()
+
+	tx, err := upc.Tx(ctx)
+	require.NoError(t, err)
+	defer tx.Close()
+
+	for _, r := range deleted {
+		require.NoError(t, tx.Delete(r))
+	}
+
+	for _, r := range added {
+		require.NoError(t, tx.Append(r))
+	}
+
+	require.NoError(t, tx.Commit(ctx))
+}
+
+func mustRule(t *testing.T, name string, typ upstream.RType, value string, owner string) upstream.Rule {
+	t.Helper()
+
+	r, err := upstream.NewRule(name, typ, value)
+	require.NoError(t, err)
+	r.Owner = owner
+	return r
+}
@@ -0,0 +1,222 @@
+// Package history keeps snapshots of the managed record set taken before every upstream commit.
+package history
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// Record is the single upstream record, stored in the way it can be restored with the same scope and owner.
+type Record struct {
+	RR string `json:"rr"`
+	// Negative marks RR as the TXT marker of the negative rule
+	Negative bool     `json:"negative,omitempty"`
+	Clients  []string `json:"clients,omitempty"`
+	CTags    []string `json:"ctags,omitempty"`
+	Owner    string   `json:"owner,omitempty"`
+}
+
+type Snapshot struct {
+	ID       int       `json:"id"`
+	Time     time.Time `json:"time"`
+	Upstream string    `json:"upstream"`
+	// Actor is who triggered the commit, e.g. the client name.
+	Actor   string   `json:"actor,omitempty"`
+	Records []Record `json:"records"`
+}
+
+func NewRecord(r upstream.Rule) (Record, error) {
+	rr, err := r.RR()
+	if err != nil {
+		return Record{}, fmt.Errorf("generate rr: %w", err)
+	}
+
+	return Record{
+		RR:       rr.String(),
+		Negative: r.IsNegative(),
+		Clients:  r.Scope.Clients,
+		CTags:    r.Scope.CTags,
+		Owner:    r.Owner,
+	}, nil
+}
+
+// Rule returns the rule matching exactly the stored record.
+func (r *Record) Rule() (upstream.Rule, error) {
+	rr, err := dns.NewRR(r.RR)
+	if err != nil {
+		return upstream.Rule{}, fmt.Errorf("parse rr: %w", err)
+	}
+
+	rule, err := upstream.RuleFromStoredRR(rr, r.Negative)
+	if err != nil {
+		return upstream.Rule{}, err
+	}
+
+	rule.Scope = upstream.NewScope(r.Clients, r.CTags)
+	rule.Owner = r.Owner
+	return rule, nil
+}
+
+// String formats the record as a zone file line followed by the negative flag, the non-global scope and the owner.
+func (r *Record) String() string {
+	out := r.RR
+	if r.Negative {
+		out += " ; negative"
+	}
+
+	if scope := upstream.NewScope(r.Clients, r.CTags); !scope.IsZero() {
+		out += " ; " + scope.String()
+	}
+
+	if r.Owner != "" {
+		out += " ; owner=" + r.Owner
+	}
+
+	return out
+}
+
+// NewSnapshot captures the current upstream record set.
+func NewSnapshot(ctx context.Context, upc upstream.Upstream) (Snapshot, error) {
+	rules, err := upc.Query(ctx, upstream.Rule{
+		Type:  dns.TypeAXFR,
+		Scope: upstream.AnyScope(),
+	})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("query records: %w", err)
+	}
+
+	out := Snapshot{
+		Time:    time.Now().UTC(),
+		Actor:   ActorFrom(ctx),
+		Records: make([]Record, 0, len(rules)),
+	}
+
+	for _, rule := range rules {
+		rec, err := NewRecord(rule)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("record %q: %w", rule.Name, err)
+		}
+
+		out.Records = append(out.Records, rec)
+	}
+
+	slices.SortFunc(out.Records, func(a, b Record) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return out, nil
+}
+
+// SameRecords reports whether both snapshots hold the same record set.
+func (s *Snapshot) SameRecords(other Snapshot) bool {
+	return slices.Equal(s.lines(), other.lines())
+}
+
+func (s *Snapshot) lines() []string {
+	out := make([]string, len(s.Records))
+	for i, r := range s.Records {
+		out[i] = r.String()
+	}
+
+	slices.Sort(out)
+	return out
+}
+
+// Diff returns records to delete from and to append to the "from" set to get the "to" one.
+func Diff(from, to []Record) (deleted, added []Record) {
+	toKeys := make(map[string]struct{}, len(to))
+	for _, r := range to {
+		toKeys[r.String()] = struct{}{}
+	}
+
+	fromKeys := make(map[string]struct{}, len(from))
+	for _, r := range from {
+		fromKeys[r.String()] = struct{}{}
+		if _, ok := toKeys[r.String()]; !ok {
+			deleted = append(deleted, r)
+		}
+	}
+
+	for _, r := range to {
+		if _, ok := fromKeys[r.String()]; !ok {
+			added = append(added, r)
+		}
+	}
+
+	return deleted, added
+}
+
+// DiffLines formats Diff results as "- record" and "+ record" lines.
+func DiffLines(deleted, added []Record) []string {
+	out := make([]string, 0, len(deleted)+len(added))
+	for _, r := range deleted {
+		out = append(out, "- "+r.String())
+	}
+
+	for _, r := range added {
+		out = append(out, "+ "+r.String())
+	}
+
+	return out
+}
+
+type actorKey struct{}
+
+// WithActor annotates commits made with the context, e.g. with the client name.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Restore stages changes bringing the upstream back to the snapshot state and returns them as Diff lines.
+// The caller either commits the returned transaction or just closes it.
+func Restore(ctx context.Context, upc upstream.Upstream, snap Snapshot) (upstream.Tx, []string, error) {
+	current, err := NewSnapshot(ctx, upc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("snapshot current records: %w", err)
+	}
+
+	deleted, added := Diff(current.Records, snap.Records)
+	tx, err := upc.Tx(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create upstream tx: %w", err)
+	}
+
+	for _, r := range deleted {
+		rule, err := r.Rule()
+		if err != nil {
+			tx.Close()
+			return nil, nil, fmt.Errorf("current record %q: %w", r.RR, err)
+		}
+
+		if err := tx.Delete(rule); err != nil {
+			tx.Close()
+			return nil, nil, fmt.Errorf("delete %q: %w", r.RR, err)
+		}
+	}
+
+	for _, r := range added {
+		rule, err := r.Rule()
+		if err != nil {
+			tx.Close()
+			return nil, nil, fmt.Errorf("snapshot record %q: %w", r.RR, err)
+		}
+
+		if err := tx.Append(rule); err != nil {
+			tx.Close()
+			return nil, nil, fmt.Errorf("append %q: %w", r.RR, err)
+		}
+	}
+
+	return tx, DiffLines(deleted, added), nil
+}
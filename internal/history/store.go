@@ -0,0 +1,184 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultKeep = 100
+
+var ErrNotFound = errors.New("snapshot not found")
+
+// Store keeps snapshots of the single upstream as numbered JSON files within the directory.
+type Store struct {
+	dir      string
+	upstream string
+	keep     int
+	mu       sync.Mutex
+}
+
+// NewStore opens the snapshots directory of the upstream, only the last keep snapshots are retained.
+func NewStore(dir, upstream string, keep int) (*Store, error) {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+
+	dir = filepath.Join(dir, upstream)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create history dir: %w", err)
+	}
+
+	return &Store{
+		dir:      dir,
+		upstream: upstream,
+		keep:     keep,
+	}, nil
+}
+
+func (s *Store) Upstream() string {
+	return s.upstream
+}
+
+// Save stores the snapshot with the next ID, unless it holds the same records as the last one.
+func (s *Store) Save(snap Snapshot) (Snapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+
+	snap.ID = 1
+	snap.Upstream = s.upstream
+	if len(ids) > 0 {
+		last, err := s.read(ids[len(ids)-1])
+		if err != nil {
+			return Snapshot{}, false, err
+		}
+
+		if last.SameRecords(snap) {
+			return last, false, nil
+		}
+
+		snap.ID = last.ID + 1
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return Snapshot{}, false, fmt.Errorf("write snapshot: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return Snapshot{}, false, fmt.Errorf("close snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(snap.ID)); err != nil {
+		return Snapshot{}, false, fmt.Errorf("save snapshot: %w", err)
+	}
+
+	ids = append(ids, snap.ID)
+	for len(ids) > s.keep {
+		if err := os.Remove(s.path(ids[0])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return snap, true, fmt.Errorf("remove old snapshot: %w", err)
+		}
+		ids = ids[1:]
+	}
+
+	return snap, true, nil
+}
+
+// List returns stored snapshots, the oldest first.
+func (s *Store) List() ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Snapshot, 0, len(ids))
+	for _, id := range ids {
+		snap, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, snap)
+	}
+
+	return out, nil
+}
+
+func (s *Store) Get(id int) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(id)
+}
+
+func (s *Store) read(id int) (Snapshot, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Snapshot{}, fmt.Errorf("%w: #%d", ErrNotFound, id)
+		}
+
+		return Snapshot{}, fmt.Errorf("read snapshot #%d: %w", id, err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("parse snapshot #%d: %w", id, err)
+	}
+
+	return snap, nil
+}
+
+func (s *Store) ids() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read history dir: %w", err)
+	}
+
+	var out []int
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+
+		out = append(out, id)
+	}
+
+	slices.Sort(out)
+	return out, nil
+}
+
+func (s *Store) path(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%08d.json", id))
+}
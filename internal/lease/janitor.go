@@ -0,0 +1,277 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/buglloc/DNSGateway/internal/audit"
+	"github.com/buglloc/DNSGateway/internal/history"
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/notify"
+	"github.com/buglloc/DNSGateway/internal/tracing"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+const (
+	DefaultInterval = time.Minute
+	sweepTimeout    = time.Minute
+	// AuditClient is the client name of the janitor changes in the audit log, history and notifications.
+	AuditClient = "lease-janitor"
+)
+
+// Janitor periodically deletes records with expired leases.
+type Janitor struct {
+	upc      upstream.Upstream
+	store    *Store
+	interval time.Duration
+	locker   sync.Locker
+	audit    *audit.Logger
+	notifier *notify.Notifier
+	now      func() time.Time
+	log      zerolog.Logger
+}
+
+type JanitorOption func(*Janitor)
+
+// WithInterval sets how often expired leases are checked.
+func WithInterval(interval time.Duration) JanitorOption {
+	return func(j *Janitor) {
+		if interval > 0 {
+			j.interval = interval
+		}
+	}
+}
+
+// WithLocker serializes sweeps with the other upstream writers, e.g. the listener.
+func WithLocker(locker sync.Locker) JanitorOption {
+	return func(j *Janitor) {
+		j.locker = locker
+	}
+}
+
+// WithAudit records removals of the expired records into the audit log.
+func WithAudit(logger *audit.Logger) JanitorOption {
+	return func(j *Janitor) {
+		j.audit = logger
+	}
+}
+
+// WithNotifier sends removals of the expired records to the notification sinks.
+func WithNotifier(notifier *notify.Notifier) JanitorOption {
+	return func(j *Janitor) {
+		j.notifier = notifier
+	}
+}
+
+func NewJanitor(upc upstream.Upstream, store *Store, opts ...JanitorOption) *Janitor {
+	j := &Janitor{
+		upc:      upc,
+		store:    store,
+		interval: DefaultInterval,
+		locker:   &sync.Mutex{},
+		now:      time.Now,
+		log: log.With().
+			Str("source", "lease-janitor").
+			Logger(),
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	return j
+}
+
+// SetUpstream replaces the upstream on config reload, the caller must hold the locker.
+func (j *Janitor) SetUpstream(upc upstream.Upstream) {
+	j.upc = upc
+}
+
+// Run sweeps expired leases until the context is done.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Sweep(ctx); err != nil {
+			j.log.Error().Err(err).Msg("sweep failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes records with expired leases and returns them.
+func (j *Janitor) Sweep(ctx context.Context) ([]upstream.Rule, error) {
+	expired := j.store.Expired(j.now())
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sweepTimeout)
+	defer cancel()
+	ctx = history.WithActor(ctx, AuditClient)
+
+	ctx, span := tracing.Start(ctx, "lease.sweep", attribute.Int("lease.expired", len(expired)))
+	removed, err := j.sweep(ctx, expired)
+	span.SetAttributes(attribute.Int("lease.removed", len(removed)))
+	tracing.End(span, err)
+	return removed, err
+}
+
+func (j *Janitor) sweep(ctx context.Context, expired []Lease) ([]upstream.Rule, error) {
+	j.locker.Lock()
+	defer j.locker.Unlock()
+
+	tx, err := j.upc.Tx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create upstream tx: %w", err)
+	}
+	defer tx.Close()
+
+	var removed []upstream.Rule
+	for _, l := range expired {
+		rule, err := l.Rule()
+		if err != nil {
+			j.log.Warn().Err(err).Str("rr", l.RR).Msg("drop invalid lease")
+			continue
+		}
+
+		current, err := j.upc.Query(ctx, rule)
+		if err != nil {
+			return nil, fmt.Errorf("query leased record %q: %w", l.RR, err)
+		}
+
+		if !ownedBy(current, rule.Owner) {
+			// somebody else has recreated the record, it's not ours anymore
+			j.log.Info().Str("rr", l.RR).Msg("leased record is gone or taken over, forget it")
+			continue
+		}
+
+		if err := tx.Delete(rule); err != nil {
+			return nil, fmt.Errorf("delete leased record %q: %w", l.RR, err)
+		}
+
+		removed = append(removed, rule)
+	}
+
+	if len(removed) > 0 {
+		rec, rrsets := j.auditRecord(ctx, removed)
+		if err := tx.Commit(ctx); err != nil {
+			j.auditCommit(ctx, rec, rrsets, err)
+			return nil, fmt.Errorf("upstream tx commit: %w", err)
+		}
+		j.auditCommit(ctx, rec, rrsets, nil)
+		j.notify(rec.ID, removed)
+	}
+
+	if err := j.store.Remove(expired...); err != nil {
+		return removed, fmt.Errorf("save leases: %w", err)
+	}
+
+	for _, r := range removed {
+		metrics.LeaseExpiredRemoved.WithLabelValues(r.Owner).Inc()
+		j.log.Info().
+			Str("name", r.Name).
+			Str("type", upstream.TypeString(r.Type)).
+			Str("value", r.ValueStr).
+			Str("owner", r.Owner).
+			Msg("expired record removed")
+	}
+
+	return removed, nil
+}
+
+func ownedBy(rules []upstream.Rule, owner string) bool {
+	if len(rules) == 0 {
+		return false
+	}
+
+	for _, r := range rules {
+		if r.Owner != owner {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (j *Janitor) auditRecord(ctx context.Context, removed []upstream.Rule) (audit.Record, []upstream.Rule) {
+	if !j.audit.Enabled() {
+		return audit.Record{}, nil
+	}
+
+	rec := audit.Record{
+		ID:     audit.NewID(),
+		Client: AuditClient,
+	}
+
+	rrsets := make([]upstream.Rule, 0, len(removed))
+	for _, r := range removed {
+		rec.Names = append(rec.Names, r.Name)
+		rec.Updates = append(rec.Updates, r.String())
+		rrsets = append(rrsets, upstream.Rule{
+			Name:  r.Name,
+			Type:  r.Type,
+			Scope: r.Scope,
+		})
+	}
+
+	before, err := audit.Snapshot(ctx, j.upc, rrsets)
+	if err != nil {
+		j.log.Warn().Err(err).Msg("unable to snapshot records for audit")
+	}
+	rec.Before = before
+
+	return rec, rrsets
+}
+
+func (j *Janitor) auditCommit(ctx context.Context, rec audit.Record, rrsets []upstream.Rule, commitErr error) {
+	if !j.audit.Enabled() {
+		return
+	}
+
+	if commitErr != nil {
+		rec.Result = audit.ResultError
+		rec.Error = commitErr.Error()
+		j.audit.Log(rec)
+		return
+	}
+
+	after, err := audit.Snapshot(ctx, j.upc, rrsets)
+	if err != nil {
+		j.log.Warn().Err(err).Msg("unable to snapshot records for audit")
+	}
+
+	rec.Result = audit.ResultOK
+	rec.After = after
+	j.audit.Log(rec)
+}
+
+func (j *Janitor) notify(auditID string, removed []upstream.Rule) {
+	if !j.notifier.Enabled() {
+		return
+	}
+
+	ev := notify.Event{
+		AuditID: auditID,
+		Client:  AuditClient,
+		Changes: make([]notify.Change, len(removed)),
+	}
+
+	for i, r := range removed {
+		ev.Changes[i] = notify.NewChange(notify.ActionDeleteRR, r)
+	}
+
+	j.notifier.Notify(ev)
+}
@@ -0,0 +1,141 @@
+package lease_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/audit"
+	"github.com/buglloc/DNSGateway/internal/lease"
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/notify"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases.json")
+	store, err := lease.NewStore(path)
+	require.NoError(t, err)
+	require.Empty(t, store.Leases())
+
+	now := time.Now()
+	a := mustLease(t, mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4", "acme."), now.Add(-time.Minute))
+	b := mustLease(t, mustRule(t, "b.example.com.", dns.TypeTXT, "challenge", "acme."), now.Add(time.Hour))
+	require.NoError(t, store.Update([]lease.Lease{a, b}, nil))
+
+	// renew
+	a.Expires = now.Add(-time.Second).UTC()
+	require.NoError(t, store.Update([]lease.Lease{a}, nil))
+	require.Equal(t, []lease.Lease{a}, store.Expired(now))
+
+	store, err = lease.NewStore(path)
+	require.NoError(t, err)
+	require.Len(t, store.Leases(), 2)
+
+	rule, err := store.Leases()[1].Rule()
+	require.NoError(t, err)
+	require.Equal(t, "b.example.com.", rule.Name)
+	require.Equal(t, "acme.", rule.Owner)
+
+	require.NoError(t, store.Update(nil, []upstream.Rule{{Name: "b.example.com.", Type: dns.TypeTXT}}))
+	require.Len(t, store.Leases(), 1)
+
+	require.NoError(t, store.Remove(a))
+	require.Empty(t, store.Leases())
+}
+
+func TestJanitor(t *testing.T) {
+	ctx := context.Background()
+	upc := umemory.NewUpstream()
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.json"))
+	require.NoError(t, err)
+
+	expired := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4", "acme.")
+	alive := mustRule(t, "b.example.com.", dns.TypeA, "1.2.3.5", "acme.")
+	takenOver := mustRule(t, "c.example.com.", dns.TypeA, "1.2.3.6", "admin.")
+	human := mustRule(t, "d.example.com.", dns.TypeA, "1.2.3.7", "")
+
+	tx, err := upc.Tx(ctx)
+	require.NoError(t, err)
+	for _, r := range []upstream.Rule{expired, alive, takenOver, human} {
+		require.NoError(t, tx.Append(r))
+	}
+	require.NoError(t, tx.Commit(ctx))
+
+	past := time.Now().Add(-time.Minute)
+	takenOver.Owner = "acme."
+	require.NoError(t, store.Update([]lease.Lease{
+		mustLease(t, expired, past),
+		mustLease(t, alive, time.Now().Add(time.Hour)),
+		mustLease(t, takenOver, past),
+	}, nil))
+
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	auditSink, err := audit.NewFileSink(auditPath)
+	require.NoError(t, err)
+	auditLog := audit.NewLogger("memory", auditSink)
+	defer func() { _ = auditLog.Close() }()
+
+	notifyPath := filepath.Join(t.TempDir(), "notify.jsonl")
+	notifySink, err := notify.NewFileSink(notifyPath)
+	require.NoError(t, err)
+	notifier := notify.NewNotifier("memory", notify.Target{Name: "file", Sink: notifySink})
+
+	removedBefore := testutil.ToFloat64(metrics.LeaseExpiredRemoved.WithLabelValues("acme."))
+	j := lease.NewJanitor(upc, store, lease.WithAudit(auditLog), lease.WithNotifier(notifier))
+	removed, err := j.Sweep(ctx)
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	require.Equal(t, "a.example.com.", removed[0].Name)
+	require.Equal(t, removedBefore+1, testutil.ToFloat64(metrics.LeaseExpiredRemoved.WithLabelValues("acme.")))
+
+	rules, err := upc.Query(ctx, upstream.Rule{Type: dns.TypeAXFR})
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+
+	require.Len(t, store.Leases(), 1)
+	require.Empty(t, store.Expired(time.Now()))
+
+	recs, err := audit.Search(auditPath, audit.Filter{Client: lease.AuditClient})
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	require.Equal(t, []string{"a.example.com."}, recs[0].Names)
+	require.Equal(t, []string{"- a.example.com.\t0\tIN\tA\t1.2.3.4"}, audit.Diff(recs[0]))
+
+	require.NoError(t, notifier.Close(ctx))
+	data, err := os.ReadFile(notifyPath)
+	require.NoError(t, err)
+
+	var ev notify.Event
+	require.NoError(t, json.Unmarshal(data, &ev))
+	require.Equal(t, lease.AuditClient, ev.Client)
+	require.Equal(t, recs[0].ID, ev.AuditID)
+	require.Equal(t, []notify.Change{
+		{Action: notify.ActionDeleteRR, Name: "a.example.com.", Type: "A", Value: "1.2.3.4", Owner: "acme."},
+	}, ev.Changes)
+}
+
+func mustRule(t *testing.T, name string, typ upstream.RType, value string, owner string) upstream.Rule {
+	t.Helper()
+
+	r, err := upstream.NewRule(name, typ, value)
+	require.NoError(t, err)
+	r.Owner = owner
+	return r
+}
+
+func mustLease(t *testing.T, r upstream.Rule, expires time.Time) lease.Lease {
+	t.Helper()
+
+	l, err := lease.NewLease(r, expires)
+	require.NoError(t, err)
+	return l
+}
@@ -0,0 +1,187 @@
+// Package lease keeps expiration times of the records created through the gateway and removes expired ones.
+package lease
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// Lease is the record which must be deleted after the expiration time.
+type Lease struct {
+	RR string `json:"rr"`
+	// Negative marks RR as the TXT marker of the negative rule
+	Negative bool      `json:"negative,omitempty"`
+	Clients  []string  `json:"clients,omitempty"`
+	CTags    []string  `json:"ctags,omitempty"`
+	Owner    string    `json:"owner,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Store keeps leases in the local state file, because upstreams have nowhere to store them.
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	leases []Lease
+}
+
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+
+		return nil, fmt.Errorf("read leases file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.leases); err != nil {
+		return nil, fmt.Errorf("parse leases file: %w", err)
+	}
+
+	return s, nil
+}
+
+// NewLease creates the lease of the rule.
+func NewLease(r upstream.Rule, expires time.Time) (Lease, error) {
+	rr, err := r.RR()
+	if err != nil {
+		return Lease{}, fmt.Errorf("generate rr: %w", err)
+	}
+
+	return Lease{
+		RR:       rr.String(),
+		Negative: r.IsNegative(),
+		Clients:  r.Scope.Clients,
+		CTags:    r.Scope.CTags,
+		Owner:    r.Owner,
+		Expires:  expires.UTC(),
+	}, nil
+}
+
+// Rule returns the rule matching exactly the leased record.
+func (l *Lease) Rule() (upstream.Rule, error) {
+	rr, err := dns.NewRR(l.RR)
+	if err != nil {
+		return upstream.Rule{}, fmt.Errorf("parse rr: %w", err)
+	}
+
+	r, err := upstream.RuleFromStoredRR(rr, l.Negative)
+	if err != nil {
+		return upstream.Rule{}, err
+	}
+
+	r.Scope = upstream.NewScope(l.Clients, l.CTags)
+	r.Owner = l.Owner
+	return r, nil
+}
+
+func (l *Lease) IsExpired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+func (s *Store) Leases() []Lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.leases)
+}
+
+// Expired returns leases expired at the given time.
+func (s *Store) Expired(now time.Time) []Lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Lease
+	for _, l := range s.leases {
+		if l.IsExpired(now) {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
+
+// Update forgets leases of the deleted records, puts (or renews) added ones and persists the result.
+func (s *Store) Update(added []Lease, deleted []upstream.Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, q := range deleted {
+		s.leases = slices.DeleteFunc(s.leases, func(l Lease) bool {
+			r, err := l.Rule()
+			// broken leases are useless anyway
+			return err != nil || r.Same(&q)
+		})
+	}
+
+	for _, add := range added {
+		s.leases = slices.DeleteFunc(s.leases, func(l Lease) bool {
+			return sameRecord(l, add)
+		})
+		s.leases = append(s.leases, add)
+	}
+
+	return s.save()
+}
+
+// Remove forgets the given leases and persists the result.
+func (s *Store) Remove(leases ...Lease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rm := range leases {
+		s.leases = slices.DeleteFunc(s.leases, func(l Lease) bool {
+			return sameRecord(l, rm)
+		})
+	}
+
+	return s.save()
+}
+
+func (s *Store) save() error {
+	sort.SliceStable(s.leases, func(i, j int) bool {
+		return s.leases[i].Expires.Before(s.leases[j].Expires)
+	})
+
+	data, err := json.MarshalIndent(s.leases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal leases: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".leases-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write leases: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close leases: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func sameRecord(a, b Lease) bool {
+	return a.RR == b.RR && a.Negative == b.Negative &&
+		upstream.NewScope(a.Clients, a.CTags).Equal(upstream.NewScope(b.Clients, b.CTags))
+}
@@ -0,0 +1,149 @@
+package lrfc2136
+
+import (
+	"context"
+	"net"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/audit"
+	"github.com/buglloc/DNSGateway/internal/secret"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+type requestInfoKey struct{}
+
+// requestInfo is the request origin, recorded into the audit log.
+type requestInfo struct {
+	source string
+	key    string
+}
+
+func withRequestInfo(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) context.Context {
+	info := requestInfo{}
+	if addr := w.RemoteAddr(); addr != nil {
+		info.source = addr.String()
+		if host, _, err := net.SplitHostPort(info.source); err == nil {
+			info.source = host
+		}
+	}
+
+	if tsig := r.IsTsig(); tsig != nil {
+		info.key = tsig.Hdr.Name
+	}
+
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func requestInfoFrom(ctx context.Context) requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(requestInfo)
+	return info
+}
+
+// changeSet is the set of upstream RRsets touched by the update.
+type changeSet struct {
+	updates []string
+	names   []string
+	rrsets  []upstream.Rule
+	seen    map[string]struct{}
+}
+
+func newChangeSet(rrs []dns.RR) *changeSet {
+	out := &changeSet{
+		updates: make([]string, len(rrs)),
+		seen:    make(map[string]struct{}),
+	}
+
+	for i, rr := range rrs {
+		out.updates[i] = redactedRR{rr}.String()
+	}
+
+	return out
+}
+
+func (c *changeSet) touch(rrset upstream.Rule) {
+	q := upstream.Rule{
+		Name:  rrset.Name,
+		Type:  rrset.Type,
+		Scope: rrset.Scope,
+	}
+
+	key := rrsetKey(q)
+	if _, ok := c.seen[key]; ok {
+		return
+	}
+	c.seen[key] = struct{}{}
+
+	c.rrsets = append(c.rrsets, q)
+	if _, ok := c.seen[q.Name]; !ok {
+		c.seen[q.Name] = struct{}{}
+		c.names = append(c.names, q.Name)
+	}
+}
+
+func rrsetKey(rrset upstream.Rule) string {
+	return rrset.Name + "/" + upstream.TypeString(rrset.Type) + "/" + rrset.Scope.String()
+}
+
+// auditRecord prepares the audit record with the state of touched RRsets before the commit.
+func (a *Listener) auditRecord(ctx context.Context, client *Client, changes *changeSet) audit.Record {
+	if !a.audit.Enabled() {
+		return audit.Record{}
+	}
+
+	info := requestInfoFrom(ctx)
+	rec := audit.Record{
+		ID:      audit.NewID(),
+		Client:  client.Name,
+		Source:  info.source,
+		Key:     info.key,
+		Names:   changes.names,
+		Updates: changes.updates,
+	}
+
+	before, err := audit.Snapshot(ctx, a.current(ctx).upsc, changes.rrsets)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to snapshot records for audit")
+	}
+	rec.Before = before
+
+	return rec
+}
+
+func (a *Listener) auditCommit(ctx context.Context, rec audit.Record, changes *changeSet, commitErr error) {
+	if !a.audit.Enabled() {
+		return
+	}
+
+	if commitErr != nil {
+		rec.Result = audit.ResultError
+		rec.Error = commitErr.Error()
+		a.audit.Log(rec)
+		return
+	}
+
+	after, err := audit.Snapshot(ctx, a.current(ctx).upsc, changes.rrsets)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to snapshot records for audit")
+	}
+
+	rec.Result = audit.ResultOK
+	rec.After = after
+	a.audit.Log(rec)
+	log.Ctx(ctx).Info().Str("audit_id", rec.ID).Msg("committed")
+}
+
+// redactedRR formats the RR for logs and audit, the key material of TSIG and TKEY records is never shown.
+type redactedRR struct {
+	rr dns.RR
+}
+
+func (r redactedRR) String() string {
+	switch r.rr.(type) {
+	case *dns.TSIG, *dns.TKEY:
+		return r.rr.Header().String() + secret.Redacted
+	default:
+		return r.rr.String()
+	}
+}
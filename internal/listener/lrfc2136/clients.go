@@ -3,14 +3,12 @@ package lrfc2136
 import (
 	"errors"
 	"fmt"
-	"math"
-	"strings"
 	"time"
 
 	"github.com/miekg/dns"
 
-	"github.com/buglloc/DNSGateway/internal/fqdn"
 	"github.com/buglloc/DNSGateway/internal/listener/lrfc2136/dnserr"
+	"github.com/buglloc/DNSGateway/internal/upstream"
 )
 
 type Client struct {
@@ -18,8 +16,15 @@ type Client struct {
 	Secret     string
 	XFRAllowed bool
 	AutoDelete bool
+	Takeover   bool
+	Negative   bool
+	Lease      time.Duration
+	MaxLease   time.Duration
 	Zones      []string
 	Types      TypesSet
+	Scope      upstream.Scope
+	NameMap    *NameMapper
+	DryRun     bool
 }
 
 type TypesSet map[uint16]struct{}
@@ -42,23 +47,77 @@ func (a *Clients) Client(r *dns.Msg) (*Client, error) {
 	return cl, nil
 }
 
+func (a *Clients) ByName(name string) (*Client, error) {
+	cl, ok := a.clients[dns.Fqdn(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown client: %s", name)
+	}
+
+	return cl, nil
+}
+
 func (c *Client) IsXFRAllowed() bool {
 	return c.XFRAllowed
 }
 
+// IsDryRun reports whether updates of the client must be logged instead of committing.
+func (c *Client) IsDryRun() bool {
+	return c.DryRun
+}
+
 func (c *Client) ShouldAutoDelete() bool {
 	return c.AutoDelete
 }
 
-func (c *Client) Zone(name string) string {
-	name = dns.Fqdn(name)
-	for _, zone := range c.Zones {
-		if dns.IsSubDomain(dns.Fqdn(zone), name) {
-			return zone
-		}
+// CanTakeover reports whether the client may modify records owned by others or created outside of DNSGateway.
+func (c *Client) CanTakeover() bool {
+	return c.Takeover
+}
+
+// RuleFromRR parses the client record. TXT markers are treated as negative rules only for clients with the negative
+// permission, so plain TXT clients can neither hide names nor lose TXT values looking like markers.
+func (c *Client) RuleFromRR(rr dns.RR) (upstream.Rule, error) {
+	if !c.Negative || !upstream.IsNegativeMarker(rr) {
+		return upstream.RuleFromRR(rr)
+	}
+
+	rule, err := upstream.NegativeRuleFromRR(rr)
+	if err != nil {
+		return upstream.Rule{}, err
 	}
 
-	return ""
+	if !c.IsTypeAllowed(rule.Type) {
+		return upstream.Rule{}, fmt.Errorf(
+			"%q record type is not allowed for client %q",
+			upstream.TypeString(rule.Type), c.Name,
+		)
+	}
+
+	return rule, nil
+}
+
+// LeaseFor returns the lifetime of the created records: the requested one (or the default one) limited by the max one.
+// Zero means records live forever.
+func (c *Client) LeaseFor(requested time.Duration) time.Duration {
+	lease := requested
+	if lease <= 0 {
+		lease = c.Lease
+	}
+
+	if c.MaxLease > 0 && (lease <= 0 || lease > c.MaxLease) {
+		lease = c.MaxLease
+	}
+
+	return lease
+}
+
+// UpstreamName maps the client-facing name into the upstream one.
+func (c *Client) UpstreamName(name string) string {
+	return c.NameMap.ToUpstream(name)
+}
+
+func (c *Client) Zone(name string) string {
+	return MatchZone(c.Zones, name)
 }
 
 func (c *Client) IsNameAllowed(name string) bool {
@@ -78,7 +137,7 @@ func (c *Client) IsTypeAllowed(rrType uint16) bool {
 	return ok
 }
 
-func (c *Client) SOA(name string) (*dns.SOA, error) {
+func (c *Client) SOA(name string, mname string) (*dns.SOA, error) {
 	zone := c.Zone(name)
 	if zone == "" {
 		return nil, dnserr.NewDNSError(
@@ -87,21 +146,7 @@ func (c *Client) SOA(name string) (*dns.SOA, error) {
 		)
 	}
 
-	const ttl = 60
-	return &dns.SOA{
-		Hdr: dns.RR_Header{
-			Name:   fqdn.FQDN(zone),
-			Rrtype: dns.TypeSOA,
-			Class:  dns.ClassINET,
-			Ttl:    ttl,
-		},
-		Ns:      "dns.cloudflare.com.",
-		Mbox:    fqdn.FQDN("admin." + zone),
-		Serial:  uint32(time.Now().Unix() % math.MaxUint32),
-		Refresh: ttl,
-		Retry:   ttl,
-		Expire:  ttl,
-	}, nil
+	return ZoneSOA(zone, mname), nil
 }
 
 func TsigSecrets(clients ...Client) (map[string]string, error) {
@@ -135,9 +180,9 @@ func TsigClients(clients ...Client) (*Clients, error) {
 func ParseTypesSet(in []string) (map[uint16]struct{}, error) {
 	out := make(map[uint16]struct{}, len(in))
 	for _, name := range in {
-		rrType, ok := dns.StringToType[strings.ToUpper(name)]
-		if !ok {
-			return nil, fmt.Errorf("unknown record type: %s", name)
+		rrType, err := upstream.ParseType(name)
+		if err != nil {
+			return nil, err
 		}
 
 		out[rrType] = struct{}{}
@@ -0,0 +1,50 @@
+package lrfc2136
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+func TestClientRuleFromRR(t *testing.T) {
+	types, err := ParseTypesSet([]string{"txt"})
+	require.NoError(t, err)
+
+	plain := testClient("plain.")
+	plain.Types = types
+
+	negative := testClient("negative.")
+	negative.Types = types
+	negative.Negative = true
+
+	marker := func(value string) dns.RR {
+		rr, err := dns.NewRR(`a.example.com. 60 IN TXT "` + value + `"`)
+		require.NoError(t, err)
+		return rr
+	}
+
+	// without the permission markers are plain TXT records
+	rule, err := plain.RuleFromRR(marker("dnsgateway:nxdomain"))
+	require.NoError(t, err)
+	require.False(t, rule.IsNegative())
+	require.Equal(t, dns.TypeTXT, rule.Type)
+	require.Equal(t, "dnsgateway:nxdomain", rule.ValueStr)
+
+	rule, err = negative.RuleFromRR(marker("dnsgateway:nxdomain"))
+	require.NoError(t, err)
+	require.Equal(t, upstream.NegativeNXDomain, rule.Negative)
+
+	rule, err = negative.RuleFromRR(marker("dnsgateway:nodata:TXT"))
+	require.NoError(t, err)
+	require.Equal(t, upstream.NegativeNoData, rule.Negative)
+
+	// NODATA of the single type is limited by the client types as well
+	_, err = negative.RuleFromRR(marker("dnsgateway:nodata:AAAA"))
+	require.ErrorContains(t, err, "not allowed")
+
+	_, err = negative.RuleFromRR(marker("dnsgateway:bogus"))
+	require.Error(t, err)
+}
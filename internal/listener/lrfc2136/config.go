@@ -2,7 +2,12 @@ package lrfc2136
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/buglloc/DNSGateway/internal/audit"
+	"github.com/buglloc/DNSGateway/internal/lease"
+	"github.com/buglloc/DNSGateway/internal/notify"
 	"github.com/buglloc/DNSGateway/internal/upstream"
 )
 
@@ -11,6 +16,13 @@ type Config struct {
 	nets     []string
 	upstream upstream.Upstream
 	clients  []Client
+	public   []string
+	soaMName string
+	leases   *lease.Store
+	interval time.Duration
+	audit    *audit.Logger
+	notifier *notify.Notifier
+	dryRun   bool
 }
 
 func NewConfig() *Config {
@@ -48,6 +60,43 @@ func (c *Config) AppendClient(client Client) *Config {
 	return c
 }
 
+// PublicZones sets zones for which plain (unsigned) queries are answered from the upstream.
+func (c *Config) PublicZones(zones ...string) *Config {
+	c.public = zones
+	return c
+}
+
+// SOAMName sets the primary name server of zone SOA records, the zone apex is used by default.
+func (c *Config) SOAMName(name string) *Config {
+	c.soaMName = name
+	return c
+}
+
+// Leases enables expiration of the records created with a lease, expired records are checked with the given interval.
+func (c *Config) Leases(store *lease.Store, interval time.Duration) *Config {
+	c.leases = store
+	c.interval = interval
+	return c
+}
+
+// Audit enables the audit log of committed changes, including ones made by the leases janitor.
+func (c *Config) Audit(logger *audit.Logger) *Config {
+	c.audit = logger
+	return c
+}
+
+// Notifier enables notifications of committed changes, including ones made by the leases janitor.
+func (c *Config) Notifier(notifier *notify.Notifier) *Config {
+	c.notifier = notifier
+	return c
+}
+
+// DryRun makes the listener to log the upstream diff instead of committing updates of every client.
+func (c *Config) DryRun(dryRun bool) *Config {
+	c.dryRun = dryRun
+	return c
+}
+
 func (c *Config) Validate() error {
 	var errs []error
 	if c.addr == "" {
@@ -66,5 +115,13 @@ func (c *Config) Validate() error {
 		errs = append(errs, errors.New(".Clients is required"))
 	}
 
+	if c.leases == nil {
+		for _, cl := range c.clients {
+			if cl.Lease > 0 || cl.MaxLease > 0 {
+				errs = append(errs, fmt.Errorf("client %q has lease configured, but .Leases is not", cl.Name))
+			}
+		}
+	}
+
 	return errors.Join(errs...)
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
@@ -12,15 +14,26 @@ import (
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/buglloc/DNSGateway/internal/audit"
+	"github.com/buglloc/DNSGateway/internal/history"
+	"github.com/buglloc/DNSGateway/internal/lease"
 	"github.com/buglloc/DNSGateway/internal/listener/lrfc2136/dnserr"
 	"github.com/buglloc/DNSGateway/internal/listener/lrfc2136/middlewares"
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/notify"
+	"github.com/buglloc/DNSGateway/internal/tracing"
 	"github.com/buglloc/DNSGateway/internal/upstream"
 )
 
 type Listener struct {
 	listeners []*dns.Server
-	upsc      upstream.Upstream
-	clients   *Clients
+	state     atomic.Pointer[state]
+	tsig      tsigProvider
+	leases    *lease.Store
+	janitor   *lease.Janitor
+	audit     *audit.Logger
+	notifier  *notify.Notifier
+	cancel    context.CancelFunc
 	mu        sync.Mutex
 	log       zerolog.Logger
 }
@@ -32,14 +45,9 @@ func NewListener(cfg *Config) (*Listener, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	tsigSecrets, err := TsigSecrets(cfg.clients...)
+	st, err := newState(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("parse TSIG secrets: %w", err)
-	}
-
-	tsigClients, err := TsigClients(cfg.clients...)
-	if err != nil {
-		return nil, fmt.Errorf("parse TSIG ACLs: %w", err)
+		return nil, fmt.Errorf("parse clients: %w", err)
 	}
 
 	logger := log.With().
@@ -48,17 +56,29 @@ func NewListener(cfg *Config) (*Listener, error) {
 
 	app := &Listener{
 		listeners: make([]*dns.Server, len(cfg.nets)),
-		upsc:      cfg.upstream,
-		clients:   tsigClients,
+		leases:    cfg.leases,
+		audit:     cfg.audit,
+		notifier:  cfg.notifier,
 		log:       logger,
 	}
+	app.state.Store(st)
+	app.tsig = newTsigProvider(&app.state)
+
+	if cfg.leases != nil {
+		app.janitor = lease.NewJanitor(cfg.upstream, cfg.leases,
+			lease.WithInterval(cfg.interval),
+			lease.WithLocker(app.locker(lockHolderJanitor)),
+			lease.WithAudit(cfg.audit),
+			lease.WithNotifier(cfg.notifier),
+		)
+	}
 
 	for i, net := range cfg.nets {
 		net := net
 		app.listeners[i] = &dns.Server{
-			Addr:       cfg.addr,
-			Net:        net,
-			TsigSecret: tsigSecrets,
+			Addr:         cfg.addr,
+			Net:          net,
+			TsigProvider: app.tsig,
 			NotifyStartedFunc: func() {
 				logger.Info().
 					Str("net", net).
@@ -75,6 +95,15 @@ func NewListener(cfg *Config) (*Listener, error) {
 
 func (a *Listener) ListenAndServe() error {
 	var g errgroup.Group
+	if a.janitor != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		a.cancel = cancel
+		g.Go(func() error {
+			a.janitor.Run(ctx)
+			return nil
+		})
+	}
+
 	for _, l := range a.listeners {
 		l := l
 		g.Go(func() error {
@@ -90,21 +119,71 @@ func (a *Listener) ListenAndServe() error {
 }
 
 func (a *Listener) Shutdown(ctx context.Context) error {
+	if a.cancel != nil {
+		a.cancel()
+	}
+
 	var errs []error
 	for _, l := range a.listeners {
 		if err := l.ShutdownContext(ctx); err != nil {
 			errs = append(errs, err)
 		}
 	}
+
+	if err := a.audit.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close audit log: %w", err))
+	}
+
+	if err := a.notifier.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("close notifier: %w", err))
+	}
 	return errors.Join(errs...)
 }
 
+// Reload swaps clients with their keys, public zones, the dry-run mode and the upstream while servers keep running.
+// The nil upstream keeps the current one. Other settings (addr, nets, leases, audit and notifier) require a restart
+// and are ignored. Requests already in progress are finished with the previous settings, the returned function
+// waits for them, e.g. to close the replaced upstream.
+func (a *Listener) Reload(cfg *Config) (func(), error) {
+	if cfg.upstream == nil {
+		cfg.upstream = a.state.Load().upsc
+	}
+	cfg.leases = a.leases
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	st, err := newState(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parse clients: %w", err)
+	}
+
+	// wait for the running commit or sweep, so the janitor never sees a half-swapped state
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.janitor != nil {
+		a.janitor.SetUpstream(st.upsc)
+	}
+	prev := a.state.Swap(st)
+
+	a.log.Info().Int("clients", len(cfg.clients)).Msg("reloaded")
+	return prev.wait, nil
+}
+
 func (a *Listener) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	l := log.Logger.With().
 		Stringer("client", w.RemoteAddr()).
 		Logger()
 
 	ctx := l.WithContext(context.Background())
+	ctx = withRequestInfo(ctx, w, r)
+	st := a.acquireState()
+	defer st.release()
+	ctx = withState(ctx, st)
+	// the request was verified before the state was acquired, so it's checked and answered with the acquired one
+	w = a.tsig.bind(w, r, st)
 
 	if err := a.serveDNS(ctx, w, r); err != nil {
 		l.Error().Err(err).Msg("request failed")
@@ -116,6 +195,19 @@ func (a *Listener) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 }
 
 func (a *Listener) serveDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) error {
+	if a.isPublicQuery(ctx, r) {
+		middlewares.Tracing(
+			middlewares.Logger(
+				middlewares.Metrics(
+					middlewares.Recoverer(
+						middlewares.MsgResponder(a.handlePublicQuery),
+					),
+				),
+			),
+		)(ctx, w, r)
+		return nil
+	}
+
 	var handler middlewares.NextFn
 
 	switch r.Opcode {
@@ -136,10 +228,14 @@ func (a *Listener) serveDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 		})
 	}
 
-	middlewares.Logger(
-		middlewares.Recoverer(
-			middlewares.TSIGChecker(
-				handler,
+	middlewares.Tracing(
+		middlewares.Logger(
+			middlewares.Metrics(
+				middlewares.Recoverer(
+					middlewares.TSIGChecker(
+						handler,
+					),
+				),
 			),
 		),
 	)(ctx, w, r)
@@ -149,7 +245,7 @@ func (a *Listener) serveDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 
 func (a *Listener) handleXFRTransfer(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) error {
 	log.Ctx(ctx).Info().Msg("handle XFR transfer")
-	client, err := a.clients.Client(r)
+	client, err := a.current(ctx).clients.Client(r)
 	if err != nil {
 		return err
 	}
@@ -184,10 +280,7 @@ func (a *Listener) handleXFRTransfer(ctx context.Context, w dns.ResponseWriter,
 func (a *Listener) handleXFRQuestion(ctx context.Context, client *Client, q dns.Question, out chan *dns.Envelope) {
 	log.Ctx(ctx).Info().Str("name", q.Name).Msg("handle XFR request")
 
-	rules, err := a.upsc.Query(ctx, upstream.Rule{
-		Name: dns.Fqdn(q.Name),
-		Type: q.Qtype,
-	})
+	rules, err := a.xfrRules(ctx, client, q)
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("unable to get rules from upstream")
 		out <- &dns.Envelope{
@@ -196,7 +289,7 @@ func (a *Listener) handleXFRQuestion(ctx context.Context, client *Client, q dns.
 		return
 	}
 
-	soa, err := client.SOA(q.Name)
+	soa, err := client.SOA(q.Name, a.current(ctx).mname)
 	if err != nil {
 		log.Ctx(ctx).Error().Str("name", q.Name).Err(err).Msg("unable to get SOA")
 		out <- &dns.Envelope{
@@ -205,6 +298,8 @@ func (a *Listener) handleXFRQuestion(ctx context.Context, client *Client, q dns.
 		return
 	}
 
+	metrics.XFRRecords.WithLabelValues(client.Name).Observe(float64(len(rules)))
+
 	xfrMarker := &dns.Envelope{
 		RR: []dns.RR{soa},
 	}
@@ -220,26 +315,59 @@ func (a *Listener) handleXFRQuestion(ctx context.Context, client *Client, q dns.
 			end = len(rules)
 		}
 
-		rrs := make([]dns.RR, 0, chunkSize)
-		for _, rule := range rules[i:end] {
+		out <- &dns.Envelope{
+			RR: rules[i:end],
+		}
+	}
+}
+
+// xfrRules returns records of the client-facing zone, including ones the upstream keeps under mapped names.
+func (a *Listener) xfrRules(ctx context.Context, client *Client, q dns.Question) ([]dns.RR, error) {
+	zone := dns.Fqdn(q.Name)
+	roots := append([]string{zone}, client.NameMap.UpstreamRoots(zone)...)
+
+	var out []dns.RR
+	seen := make(map[string]struct{})
+	for _, root := range roots {
+		rules, err := a.current(ctx).upsc.Query(ctx, upstream.Rule{
+			Name:  root,
+			Type:  q.Qtype,
+			Scope: client.Scope,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
 			rr, err := rule.RR()
 			if err != nil {
 				log.Ctx(ctx).Error().Err(err).Str("name", rule.Name).Msg("unable to generate rr")
 				continue
 			}
 
-			rrs = append(rrs, rr)
-		}
+			name, mapped := client.NameMap.FromUpstream(rule.Name)
+			if root != zone && (!mapped || !dns.IsSubDomain(zone, name)) {
+				// mapped roots may hold records of other zones
+				continue
+			}
+			rr.Header().Name = name
 
-		out <- &dns.Envelope{
-			RR: rrs,
+			key := rr.String()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+
+			seen[key] = struct{}{}
+			out = append(out, rr)
 		}
 	}
+
+	return out, nil
 }
 
 func (a *Listener) handleQuery(ctx context.Context, m *dns.Msg, r *dns.Msg) error {
 	log.Ctx(ctx).Info().Msg("handle query")
-	client, err := a.clients.Client(r)
+	client, err := a.current(ctx).clients.Client(r)
 	if err != nil {
 		return err
 	}
@@ -252,7 +380,7 @@ func (a *Listener) handleQuery(ctx context.Context, m *dns.Msg, r *dns.Msg) erro
 			log.Ctx(ctx).Warn().Msg("ignored unexpected XFR request")
 			continue
 		case isSOAQuestion(q):
-			soa, err := client.SOA(q.Name)
+			soa, err := client.SOA(q.Name, a.current(ctx).mname)
 			if err != nil {
 				return err
 			}
@@ -268,9 +396,10 @@ func (a *Listener) handleQuery(ctx context.Context, m *dns.Msg, r *dns.Msg) erro
 			continue
 		}
 
-		rules, err := a.upsc.Query(ctx, upstream.Rule{
-			Name: name,
-			Type: q.Qtype,
+		rules, err := a.current(ctx).upsc.Query(ctx, upstream.Rule{
+			Name:  client.UpstreamName(name),
+			Type:  q.Qtype,
+			Scope: client.Scope,
 		})
 		if err != nil {
 			return dnserr.NewDNSError(
@@ -286,6 +415,12 @@ func (a *Listener) handleQuery(ctx context.Context, m *dns.Msg, r *dns.Msg) erro
 			)
 		}
 
+		if negative, ok := negativeRule(rules); ok {
+			log.Ctx(ctx).Info().Stringer("negative", negative).Msg("negative answer")
+			m.Rcode = negative.Rcode()
+			continue
+		}
+
 		for _, rule := range rules {
 			rr, err := rule.RR()
 			if err != nil {
@@ -293,15 +428,129 @@ func (a *Listener) handleQuery(ctx context.Context, m *dns.Msg, r *dns.Msg) erro
 				continue
 			}
 
+			// answer with the asked name, even if the upstream keeps it under the mapped one
+			rr.Header().Name = name
 			m.Answer = append(m.Answer, rr)
 		}
 	}
 	return nil
 }
 
-func (a *Listener) handleUpdates(ctx context.Context, _ *dns.Msg, r *dns.Msg) error {
+func (a *Listener) handlePublicQuery(ctx context.Context, m *dns.Msg, r *dns.Msg) error {
+	log.Ctx(ctx).Info().Msg("handle public query")
+
+	q := r.Question[0]
+	name := dns.Fqdn(q.Name)
+	st := a.current(ctx)
+	zone := MatchZone(st.public, name)
+	if zone == "" {
+		return dnserr.NewDNSError(
+			dns.RcodeRefused,
+			fmt.Errorf("%q is not in public zones", q.Name),
+		)
+	}
+
+	soa := ZoneSOA(zone, st.mname)
+	if isSOAQuestion(q) && soa.Hdr.Name == name {
+		m.Answer = append(m.Answer, soa)
+		return nil
+	}
+
+	qType := q.Qtype
+	if qType == dns.TypeANY {
+		qType = dns.TypeNone
+	}
+
+	// the public answer doesn't depend on the asking client, so rules of every scope are included
+	rules, err := st.upsc.Query(ctx, upstream.Rule{
+		Name:  name,
+		Type:  qType,
+		Scope: upstream.AnyScope(),
+	})
+	if err != nil {
+		return dnserr.NewDNSError(
+			dns.RcodeServerFailure,
+			fmt.Errorf("unable to get rules from upstream for %q: %w", q.Name, err),
+		)
+	}
+
+	if len(rules) == 0 && qType != dns.TypeCNAME && qType != dns.TypeNone {
+		rules, err = st.upsc.Query(ctx, upstream.Rule{
+			Name:  name,
+			Type:  dns.TypeCNAME,
+			Scope: upstream.AnyScope(),
+		})
+		if err != nil {
+			return dnserr.NewDNSError(
+				dns.RcodeServerFailure,
+				fmt.Errorf("unable to get CNAME rules from upstream for %q: %w", q.Name, err),
+			)
+		}
+	}
+
+	if negative, ok := negativeRule(rules); ok {
+		m.Rcode = negative.Rcode()
+		if negative != upstream.NegativeRefused {
+			m.Ns = append(m.Ns, soa)
+		}
+		return nil
+	}
+
+	if len(rules) == 0 {
+		m.Ns = append(m.Ns, soa)
+
+		if name == soa.Hdr.Name {
+			return nil
+		}
+
+		// records below the name make it an empty non-terminal, which exists without records (RFC 8020)
+		subtree, err := st.upsc.Query(ctx, upstream.Rule{
+			Name:  name,
+			Type:  dns.TypeAXFR,
+			Scope: upstream.AnyScope(),
+		})
+		if err != nil {
+			return dnserr.NewDNSError(
+				dns.RcodeServerFailure,
+				fmt.Errorf("unable to get rules from upstream for %q: %w", q.Name, err),
+			)
+		}
+
+		if !nameExists(name, subtree) {
+			m.Rcode = dns.RcodeNameError
+		}
+		return nil
+	}
+
+	for _, rule := range rules {
+		rr, err := rule.RR()
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("name", rule.Name).Msg("unable to generate rr")
+			continue
+		}
+
+		rr.Header().Ttl = soa.Minttl
+		m.Answer = append(m.Answer, rr)
+	}
+	return nil
+}
+
+func (a *Listener) isPublicQuery(ctx context.Context, r *dns.Msg) bool {
+	public := a.current(ctx).public
+	if len(public) == 0 || r.Opcode != dns.OpcodeQuery || r.IsTsig() != nil {
+		return false
+	}
+
+	if len(r.Question) != 1 || isXRFQuestion(r.Question[0]) {
+		return false
+	}
+
+	return MatchZone(public, r.Question[0].Name) != ""
+}
+
+func (a *Listener) handleUpdates(ctx context.Context, m *dns.Msg, r *dns.Msg) error {
 	log.Ctx(ctx).Info().Msg("handle updates")
-	client, err := a.clients.Client(r)
+	client, err := a.current(ctx).clients.Client(r)
 	if err != nil {
 		return err
 	}
@@ -313,20 +562,98 @@ func (a *Listener) handleUpdates(ctx context.Context, _ *dns.Msg, r *dns.Msg) er
 		)
 	}
 
+	res, err := a.applyUpdates(ctx, client, r.Ns, a.leaseFor(client, r), false)
+	if err != nil {
+		return err
+	}
+
+	if res.lease > 0 {
+		// "Dynamic DNS Update Leases": the server answers with the granted lease
+		setLeaseOption(m, res.lease)
+	}
+
+	return nil
+}
+
+// setLeaseOption puts the granted lease into the response OPT, which is created unless the message has one already.
+func setLeaseOption(m *dns.Msg, leaseTime time.Duration) {
+	ul := &dns.EDNS0_UL{
+		Code:  dns.EDNS0UL,
+		Lease: uint32(leaseTime / time.Second),
+	}
+
+	if opt := m.IsEdns0(); opt != nil {
+		opt.Option = slices.DeleteFunc(opt.Option, func(o dns.EDNS0) bool {
+			return o.Option() == dns.EDNS0UL
+		})
+		opt.Option = append(opt.Option, ul)
+		return
+	}
+
+	opt := &dns.OPT{
+		Hdr: dns.RR_Header{
+			Name:   ".",
+			Rrtype: dns.TypeOPT,
+		},
+		Option: []dns.EDNS0{ul},
+	}
+	opt.SetUDPSize(dns.MinMsgSize)
+	// TSIG must stay the last one
+	m.Extra = append([]dns.RR{opt}, m.Extra...)
+}
+
+// DryRun applies updates on behalf of the client without committing them and returns the upstream diff.
+func (a *Listener) DryRun(ctx context.Context, clientName string, rrs []dns.RR) ([]string, error) {
+	st := a.acquireState()
+	defer st.release()
+	ctx = withState(ctx, st)
+	client, err := a.current(ctx).clients.ByName(clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := a.applyUpdates(ctx, client, rrs, 0, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.diff, nil
+}
+
+type updateResult struct {
+	// lease is the granted lease of the added records
+	lease time.Duration
+	// diff is the upstream diff of the not committed transaction
+	diff []string
+}
+
+// applyUpdates applies RFC 2136 update RRs within a single upstream transaction.
+// In the dry-run mode the transaction is never committed, while the upstream diff is logged and returned.
+func (a *Listener) applyUpdates(ctx context.Context, client *Client, rrs []dns.RR, leaseTime time.Duration, dryRun bool) (updateResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, commitTimeout)
 	defer cancel()
+	ctx = history.WithActor(ctx, client.Name)
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	_, lockSpan := tracing.Start(ctx, "upstream.lock")
+	lock := a.locker(lockHolderUpdate)
+	lock.Lock()
+	lockSpan.End()
+	defer lock.Unlock()
 
-	tx, err := a.upsc.Tx(ctx)
+	tx, err := a.current(ctx).upsc.Tx(ctx)
 	if err != nil {
-		return dnserr.NewDNSError(
+		return updateResult{}, dnserr.NewDNSError(
 			dns.RcodeServerFailure,
 			fmt.Errorf("create upstream tx: %w", err),
 		)
 	}
+	defer tx.Close()
 
+	var leased []lease.Lease
+	var deleted []upstream.Rule
+	var ops []updateOp
+	autoDeleted := make(map[string]struct{})
+	changes := newChangeSet(rrs)
 	handleUpdate := func(rr dns.RR) error {
 		header := rr.Header()
 		name := dns.Fqdn(header.Name)
@@ -352,67 +679,329 @@ func (a *Listener) handleUpdates(ctx context.Context, _ *dns.Msg, r *dns.Msg) er
 			)
 		}
 
+		upstreamName := client.UpstreamName(name)
+		if upstreamName != name {
+			l = l.With().Str("upstream_name", upstreamName).Logger()
+		}
+
 		switch {
 		case header.Class == dns.ClassANY && header.Rdlength == 0:
 			// "2.5.2 - Delete An RRset" or "Delete All RRsets From A Name"
-			err := tx.Delete(upstream.Rule{
-				Name: name,
-				Type: deleteRRType(header.Rrtype),
-			})
-			if err != nil {
+			rule := upstream.Rule{
+				Name:  upstreamName,
+				Type:  deleteRRType(header.Rrtype),
+				Scope: client.Scope,
+			}
+			if err := a.checkOwnership(ctx, client, rule); err != nil {
+				return err
+			}
+
+			if err := tx.Delete(rule); err != nil {
 				return fmt.Errorf("delete: %w", err)
 			}
+			deleted = append(deleted, rule)
+			changes.touch(rule)
+			ops = append(ops, updateOp{rule: rule, action: deleteRRsetAction(rule.Type)})
 			l.Info().Msg("deleted as RRset")
 			return nil
 		case header.Class == dns.ClassNONE:
 			// "2.5.4 - Delete An RR From An RRset"
-			rule, err := upstream.RuleFromRR(rr)
+			rule, err := client.RuleFromRR(rr)
 			if err != nil {
 				return fmt.Errorf("parse RR: %w", err)
 			}
+			rule.Name = upstreamName
+			rule.Scope = client.Scope
+			if err := a.checkOwnership(ctx, client, rule); err != nil {
+				return err
+			}
 
 			if err := tx.Delete(rule); err != nil {
 				return fmt.Errorf("delete: %w", err)
 			}
+			deleted = append(deleted, rule)
+			changes.touch(rule)
+			ops = append(ops, updateOp{rule: rule, action: updateActionDeleteRR})
 			l.Info().Msg("deleted An RR from an RRset")
 			return nil
 		}
 
-		rule, err := upstream.RuleFromRR(rr)
+		rule, err := client.RuleFromRR(rr)
 		if err != nil {
 			return fmt.Errorf("parse RR: %w", err)
 		}
+		rule.Name = upstreamName
+		rule.Scope = client.Scope
+		rule.Owner = client.Name
+
+		// the whole RRset must be owned by the client, so clients never mix their records
+		rrset := upstream.Rule{
+			Name:  rule.Name,
+			Type:  rule.Type,
+			Scope: rule.Scope,
+		}
+		if err := a.checkOwnership(ctx, client, rrset); err != nil {
+			return err
+		}
+
 		if client.ShouldAutoDelete() {
-			_ = tx.Delete(upstream.Rule{
-				Name: rule.Name,
-				Type: rule.Type,
-			})
+			_ = tx.Delete(rrset)
+			deleted = append(deleted, rrset)
+			// the replaced RRset is reported once, even if the update adds several records into it
+			key := rrsetKey(rrset)
+			if _, ok := autoDeleted[key]; !ok {
+				autoDeleted[key] = struct{}{}
+				ops = append(ops, updateOp{rule: rrset, action: updateActionDeleteRRset})
+			}
 		}
 
 		if err := tx.Append(rule); err != nil {
 			return fmt.Errorf("update: %w", err)
 		}
+		changes.touch(rrset)
+		ops = append(ops, updateOp{rule: rule, action: updateActionAdd})
 
-		l.Info().Any("rr", rr).Msg("updated")
+		if leaseTime > 0 {
+			rl, err := lease.NewLease(rule, time.Now().Add(leaseTime))
+			if err != nil {
+				return fmt.Errorf("create lease: %w", err)
+			}
+
+			leased = append(leased, rl)
+			l = l.With().Stringer("lease", leaseTime).Logger()
+		}
+
+		l.Info().Stringer("rr", redactedRR{rr}).Msg("updated")
 		return nil
 	}
 
-	for _, rr := range r.Ns {
+	for _, rr := range rrs {
 		if err := handleUpdate(rr); err != nil {
-			return dnserr.NewDNSError(dns.RcodeRefused, err)
+			var dnsErr *dnserr.DNSError
+			if errors.As(err, &dnsErr) {
+				return updateResult{}, err
+			}
+
+			return updateResult{}, dnserr.NewDNSError(dns.RcodeRefused, err)
 		}
 	}
 
+	if dryRun || a.isDryRun(ctx, client) {
+		return updateResult{
+			diff: a.logDiff(ctx, tx),
+		}, nil
+	}
+
+	rec := a.auditRecord(ctx, client, changes)
 	if err := tx.Commit(ctx); err != nil {
-		return dnserr.NewDNSError(
+		a.auditCommit(ctx, rec, changes, err)
+		return updateResult{}, dnserr.NewDNSError(
 			dns.RcodeServerFailure,
 			fmt.Errorf("upstream tx commit: %w", err),
 		)
 	}
+	a.auditCommit(ctx, rec, changes, nil)
+	a.notify(ctx, client, rec.ID, ops)
+
+	for _, op := range ops {
+		metrics.UpdateOperations.WithLabelValues(client.Name, upstream.TypeString(op.rule.Type), op.action).Inc()
+	}
+
+	if a.leases != nil {
+		if err := a.leases.Update(leased, deleted); err != nil {
+			// records are already committed, the janitor just won't remove them
+			log.Ctx(ctx).Error().Err(err).Msg("unable to save leases")
+		}
+	}
+
+	if len(leased) == 0 {
+		return updateResult{}, nil
+	}
+
+	return updateResult{
+		lease: leaseTime,
+	}, nil
+}
+
+const (
+	updateActionAdd          = notify.ActionAdd
+	updateActionDeleteRR     = notify.ActionDeleteRR
+	updateActionDeleteRRset  = notify.ActionDeleteRRset
+	updateActionDeleteByName = notify.ActionDeleteByName
+)
+
+// updateOp is the applied update operation, reported to metrics and notifications once committed.
+type updateOp struct {
+	rule   upstream.Rule
+	action string
+}
+
+func (a *Listener) notify(ctx context.Context, client *Client, auditID string, ops []updateOp) {
+	if !a.notifier.Enabled() {
+		return
+	}
+
+	ev := notify.Event{
+		AuditID: auditID,
+		Client:  client.Name,
+		Source:  requestInfoFrom(ctx).source,
+		Changes: make([]notify.Change, len(ops)),
+	}
+
+	for i, op := range ops {
+		ev.Changes[i] = notify.NewChange(op.action, op.rule)
+	}
+
+	a.notifier.Notify(ev)
+}
+
+func deleteRRsetAction(rrType uint16) string {
+	if rrType == dns.TypeNone {
+		return updateActionDeleteByName
+	}
+
+	return updateActionDeleteRRset
+}
+
+const (
+	lockHolderUpdate  = "update"
+	lockHolderJanitor = "janitor"
+)
+
+// timedLocker reports the time spent waiting for the upstream transaction lock.
+type timedLocker struct {
+	mu     *sync.Mutex
+	holder string
+}
+
+func (l timedLocker) Lock() {
+	start := time.Now()
+	l.mu.Lock()
+	metrics.LockWait.WithLabelValues(l.holder).Observe(time.Since(start).Seconds())
+}
+
+func (l timedLocker) Unlock() {
+	l.mu.Unlock()
+}
+
+func (a *Listener) locker(holder string) sync.Locker {
+	return timedLocker{
+		mu:     &a.mu,
+		holder: holder,
+	}
+}
+
+func (a *Listener) isDryRun(ctx context.Context, client *Client) bool {
+	return a.current(ctx).dryRun || client.IsDryRun()
+}
+
+func (a *Listener) logDiff(ctx context.Context, tx upstream.Tx) []string {
+	l := log.Ctx(ctx).With().Bool("dry_run", true).Logger()
+	diff, err := upstream.TxDiff(ctx, tx)
+	if err != nil {
+		l.Warn().Err(err).Msg("changes are not committed, but the diff is unavailable")
+		return nil
+	}
+
+	for _, line := range diff {
+		l.Info().Str("change", line).Msg("not committed")
+	}
+
+	return diff
+}
+
+// leaseFor returns the lease granted to records of the update, none without the leases store to enforce it.
+func (a *Listener) leaseFor(client *Client, r *dns.Msg) time.Duration {
+	if a.leases == nil {
+		return 0
+	}
+
+	return client.LeaseFor(requestedLease(r))
+}
+
+// requestedLease returns the lease requested by the client with the EDNS0 UPDATE-LEASE option.
+func requestedLease(r *dns.Msg) time.Duration {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return 0
+	}
+
+	for _, o := range opt.Option {
+		if ul, ok := o.(*dns.EDNS0_UL); ok {
+			return time.Duration(ul.Lease) * time.Second
+		}
+	}
+
+	return 0
+}
+
+// checkOwnership refuses changes of the records owned by other clients or created outside of DNSGateway,
+// unless the client is allowed to take them over.
+func (a *Listener) checkOwnership(ctx context.Context, client *Client, q upstream.Rule) error {
+	if client.CanTakeover() {
+		return nil
+	}
+
+	rules, err := a.current(ctx).upsc.Query(ctx, q)
+	if err != nil {
+		return dnserr.NewDNSError(
+			dns.RcodeServerFailure,
+			fmt.Errorf("query current records: %w", err),
+		)
+	}
+
+	for _, rule := range rules {
+		if rule.IsOwnedBy(client.Name) {
+			continue
+		}
+
+		owner := rule.Owner
+		if owner == "" {
+			owner = "nobody"
+		}
+
+		return fmt.Errorf(
+			"%s record %q is owned by %s, not by client %q",
+			upstream.TypeString(rule.Type), rule.Name, owner, client.Name,
+		)
+	}
 
 	return nil
 }
 
+// negativeRule returns the strongest negative rule kind: REFUSED wins over NXDOMAIN and NXDOMAIN over NODATA.
+func negativeRule(rules []upstream.Rule) (upstream.Negative, bool) {
+	var out upstream.Negative
+	for _, rule := range rules {
+		switch {
+		case rule.Negative == upstream.NegativeRefused:
+			return rule.Negative, true
+		case rule.Negative == upstream.NegativeNXDomain:
+			out = rule.Negative
+		case rule.Negative == upstream.NegativeNoData && out == upstream.NegativeNone:
+			out = rule.Negative
+		}
+	}
+
+	return out, out != upstream.NegativeNone
+}
+
+// nameExists reports whether the name has records or is an empty non-terminal with records below it.
+// Negative rules below the name don't count, as they only hide names.
+func nameExists(name string, subtree []upstream.Rule) bool {
+	for _, rule := range subtree {
+		if rule.Name == name {
+			return true
+		}
+
+		if !rule.IsNegative() && dns.IsSubDomain(name, rule.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func deleteRRType(rrType uint16) uint16 {
 	if rrType == dns.TypeANY {
 		return dns.TypeNone
@@ -0,0 +1,162 @@
+package lrfc2136
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/lease"
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/notify"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+)
+
+func TestSetLeaseOption(t *testing.T) {
+	countOPT := func(m *dns.Msg) int {
+		var out int
+		for _, rr := range m.Extra {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				out++
+			}
+		}
+		return out
+	}
+
+	leaseOf := func(m *dns.Msg) uint32 {
+		var out uint32
+		for _, o := range m.IsEdns0().Option {
+			if ul, ok := o.(*dns.EDNS0_UL); ok {
+				out = ul.Lease
+			}
+		}
+		return out
+	}
+
+	m := new(dns.Msg)
+	setLeaseOption(m, time.Hour)
+	require.Equal(t, 1, countOPT(m))
+	require.EqualValues(t, 3600, leaseOf(m))
+
+	// the existing OPT is reused, so the response never has two of them
+	m = new(dns.Msg)
+	m.SetEdns0(4096, true)
+	setLeaseOption(m, time.Minute)
+	setLeaseOption(m, 2*time.Minute)
+	require.Equal(t, 1, countOPT(m))
+	require.Len(t, m.IsEdns0().Option, 1)
+	require.EqualValues(t, 120, leaseOf(m))
+	require.True(t, m.IsEdns0().Do())
+}
+
+func TestHandlePublicQuery(t *testing.T) {
+	upc := umemory.NewUpstream()
+	rr, err := dns.NewRR(`_acme-challenge.foo.example.com. 60 IN TXT "token"`)
+	require.NoError(t, err)
+	rule, err := upstream.RuleFromRR(rr)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	tx, err := upc.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Append(rule))
+	require.NoError(t, tx.Commit(ctx))
+
+	l, err := NewListener(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(upc).
+		Clients(testClient("tst.")).
+		PublicZones("example.com.").
+		SOAMName("ns1.example.net."),
+	)
+	require.NoError(t, err)
+
+	query := func(name string, qType uint16) *dns.Msg {
+		r := new(dns.Msg)
+		r.SetQuestion(name, qType)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		require.NoError(t, l.handlePublicQuery(ctx, m, r))
+		return m
+	}
+
+	m := query("_acme-challenge.foo.example.com.", dns.TypeTXT)
+	require.Equal(t, dns.RcodeSuccess, m.Rcode)
+	require.Len(t, m.Answer, 1)
+
+	// the empty non-terminal exists, so it's NODATA rather than NXDOMAIN
+	m = query("foo.example.com.", dns.TypeA)
+	require.Equal(t, dns.RcodeSuccess, m.Rcode)
+	require.Empty(t, m.Answer)
+	require.Len(t, m.Ns, 1)
+	require.Equal(t, "ns1.example.net.", m.Ns[0].(*dns.SOA).Ns)
+
+	m = query("bar.example.com.", dns.TypeA)
+	require.Equal(t, dns.RcodeNameError, m.Rcode)
+	require.Len(t, m.Ns, 1)
+}
+
+func TestLeaseFor(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetUpdate("example.com.")
+	setLeaseOption(r, time.Hour)
+
+	client := testClient("tst.")
+	l, err := NewListener(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(umemory.NewUpstream()).
+		Clients(client),
+	)
+	require.NoError(t, err)
+
+	// the lease is never granted without the store to enforce it
+	require.Zero(t, l.leaseFor(&client, r))
+
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.json"))
+	require.NoError(t, err)
+	l, err = NewListener(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(umemory.NewUpstream()).
+		Clients(client).
+		Leases(store, time.Minute),
+	)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, l.leaseFor(&client, r))
+}
+
+func TestAutoDeleteOps(t *testing.T) {
+	client := testClient("autodelete.")
+	client.AutoDelete = true
+
+	l, err := NewListener(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(umemory.NewUpstream()).
+		Clients(client),
+	)
+	require.NoError(t, err)
+
+	var rrs []dns.RR
+	for _, s := range []string{
+		`a.example.com. 60 IN TXT "one"`,
+		`a.example.com. 60 IN TXT "two"`,
+	} {
+		rr, err := dns.NewRR(s)
+		require.NoError(t, err)
+		rrs = append(rrs, rr)
+	}
+
+	deletes := metrics.UpdateOperations.WithLabelValues(client.Name, "TXT", notify.ActionDeleteRRset)
+	adds := metrics.UpdateOperations.WithLabelValues(client.Name, "TXT", notify.ActionAdd)
+	deletesBefore, addsBefore := testutil.ToFloat64(deletes), testutil.ToFloat64(adds)
+
+	// the replaced RRset is reported along with the added records, once per RRset
+	_, err = l.applyUpdates(context.Background(), &client, rrs, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, deletesBefore+1, testutil.ToFloat64(deletes))
+	require.Equal(t, addsBefore+2, testutil.ToFloat64(adds))
+}
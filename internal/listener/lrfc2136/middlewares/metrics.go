@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+)
+
+// rcodeWriter remembers the rcode of the first written message, e.g. the first envelope of the zone transfer.
+type rcodeWriter struct {
+	dns.ResponseWriter
+	rcode   int
+	written bool
+}
+
+func (w *rcodeWriter) WriteMsg(m *dns.Msg) error {
+	if !w.written {
+		w.rcode = m.Rcode
+		w.written = true
+	}
+
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func Metrics(next NextFn) NextFn {
+	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) {
+		now := time.Now()
+		rw := &rcodeWriter{
+			ResponseWriter: w,
+			rcode:          dns.RcodeServerFailure,
+		}
+
+		next(ctx, rw, r)
+
+		opcode := dns.OpcodeToString[r.Opcode]
+		client := tsigClient(w, r)
+		metrics.RequestDuration.WithLabelValues(opcode, client).Observe(time.Since(now).Seconds())
+		metrics.Requests.WithLabelValues(opcode, client, dns.RcodeToString[rw.rcode]).Inc()
+	}
+}
+
+// tsigClient returns the TSIG key name of the verified request only, so random names never become label values.
+func tsigClient(w dns.ResponseWriter, r *dns.Msg) string {
+	tsig := r.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		return ""
+	}
+
+	return tsig.Hdr.Name
+}
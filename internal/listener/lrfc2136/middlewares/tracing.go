@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/buglloc/DNSGateway/internal/tracing"
+)
+
+func Tracing(next NextFn) NextFn {
+	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) {
+		opcode := dns.OpcodeToString[r.Opcode]
+		attrs := []attribute.KeyValue{
+			attribute.String("dns.opcode", opcode),
+			attribute.String("network.peer.address", w.RemoteAddr().String()),
+		}
+		if len(r.Question) > 0 {
+			attrs = append(attrs,
+				attribute.String("dns.question.name", r.Question[0].Name),
+				attribute.String("dns.question.type", dns.Type(r.Question[0].Qtype).String()),
+			)
+		}
+		if client := tsigClient(w, r); client != "" {
+			attrs = append(attrs, attribute.String("dns.client", client))
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, "dns "+opcode,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			l := log.Ctx(ctx).With().Str("trace_id", sc.TraceID().String()).Logger()
+			ctx = l.WithContext(ctx)
+		}
+
+		rw := &rcodeWriter{
+			ResponseWriter: w,
+			rcode:          dns.RcodeServerFailure,
+		}
+
+		next(ctx, rw, r)
+
+		rcode := dns.RcodeToString[rw.rcode]
+		span.SetAttributes(attribute.String("dns.rcode", rcode))
+		if rw.rcode != dns.RcodeSuccess {
+			span.SetStatus(codes.Error, rcode)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 
 	"github.com/miekg/dns"
 	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
 )
 
 func TSIGChecker(next NextFn) NextFn {
@@ -20,11 +23,14 @@ func TSIGChecker(next NextFn) NextFn {
 
 		tsig := r.IsTsig()
 		if tsig == nil {
+			metrics.TSIGFailures.WithLabelValues("", "missing").Inc()
 			writeRefuse()
 			return
 		}
 
 		if err := w.TsigStatus(); err != nil {
+			client, reason := tsigFailure(tsig.Hdr.Name, err)
+			metrics.TSIGFailures.WithLabelValues(client, reason).Inc()
 			writeRefuse()
 			return
 		}
@@ -32,3 +38,17 @@ func TSIGChecker(next NextFn) NextFn {
 		next(ctx, w, r)
 	}
 }
+
+func tsigFailure(name string, err error) (string, string) {
+	switch {
+	case errors.Is(err, dns.ErrSecret):
+		// the key name is chosen by the sender, so keep it out of labels
+		return "", "unknown_key"
+	case errors.Is(err, dns.ErrSig):
+		return name, "bad_signature"
+	case errors.Is(err, dns.ErrTime):
+		return name, "bad_time"
+	default:
+		return name, "invalid"
+	}
+}
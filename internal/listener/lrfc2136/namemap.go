@@ -0,0 +1,192 @@
+package lrfc2136
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// NameMapping rewrites the client-facing name into the upstream one, e.g. for ACME CNAME delegation:
+//
+//	From: "_acme-challenge.*.corp.example."
+//	To:   "*.acme-validation.example.com."
+//
+// The "*" label matches one or more labels and must appear in both names or in none of them.
+type NameMapping struct {
+	From string
+	To   string
+}
+
+type namePattern struct {
+	prefix   string
+	suffix   string
+	wildcard bool
+}
+
+type nameRule struct {
+	from namePattern
+	to   namePattern
+}
+
+// NameMapper maps names between the client-facing zones and the upstream ones, the first matched mapping wins.
+type NameMapper struct {
+	rules []nameRule
+}
+
+func NewNameMapper(mappings ...NameMapping) (*NameMapper, error) {
+	out := &NameMapper{
+		rules: make([]nameRule, 0, len(mappings)),
+	}
+
+	for _, m := range mappings {
+		from, err := parseNamePattern(m.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping from %q: %w", m.From, err)
+		}
+
+		to, err := parseNamePattern(m.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping to %q: %w", m.To, err)
+		}
+
+		if from.wildcard != to.wildcard {
+			return nil, fmt.Errorf("mapping %q -> %q: wildcard must be used on both sides", m.From, m.To)
+		}
+
+		out.rules = append(out.rules, nameRule{
+			from: from,
+			to:   to,
+		})
+	}
+
+	return out, nil
+}
+
+// ToUpstream maps the client-facing name into the upstream one, names without mapping are returned as is.
+func (m *NameMapper) ToUpstream(name string) string {
+	if m == nil {
+		return name
+	}
+
+	for _, r := range m.rules {
+		if captured, ok := r.from.match(name); ok {
+			return r.to.expand(captured)
+		}
+	}
+
+	return name
+}
+
+// FromUpstream maps the upstream name back into the client-facing one.
+func (m *NameMapper) FromUpstream(name string) (string, bool) {
+	if m == nil {
+		return name, false
+	}
+
+	for _, r := range m.rules {
+		if captured, ok := r.to.match(name); ok {
+			return r.from.expand(captured), true
+		}
+	}
+
+	return name, false
+}
+
+// UpstreamRoots returns upstream names which may hold records of the client-facing zone.
+func (m *NameMapper) UpstreamRoots(zone string) []string {
+	if m == nil {
+		return nil
+	}
+
+	var out []string
+	for _, r := range m.rules {
+		from := r.from.base()
+		if !dns.IsSubDomain(zone, from) && !dns.IsSubDomain(from, zone) {
+			continue
+		}
+
+		out = append(out, r.to.base())
+	}
+
+	return out
+}
+
+func parseNamePattern(in string) (namePattern, error) {
+	in = strings.ToLower(dns.Fqdn(in))
+	if _, ok := dns.IsDomainName(strings.ReplaceAll(in, "*", "x")); !ok {
+		return namePattern{}, errors.New("invalid domain name")
+	}
+
+	labels := dns.SplitDomainName(in)
+	idx := -1
+	for i, l := range labels {
+		if strings.Contains(l, "*") {
+			if l != "*" {
+				return namePattern{}, errors.New("wildcard must be the whole label")
+			}
+
+			if idx != -1 {
+				return namePattern{}, errors.New("only one wildcard is allowed")
+			}
+
+			idx = i
+		}
+	}
+
+	if idx != -1 && idx == len(labels)-1 {
+		return namePattern{}, errors.New("wildcard can't be the top-level label")
+	}
+
+	if idx == -1 {
+		return namePattern{
+			prefix: in,
+		}, nil
+	}
+
+	prefix := strings.Join(labels[:idx], ".")
+	if prefix != "" {
+		prefix += "."
+	}
+
+	return namePattern{
+		prefix:   prefix,
+		suffix:   "." + dns.Fqdn(strings.Join(labels[idx+1:], ".")),
+		wildcard: true,
+	}, nil
+}
+
+func (p namePattern) match(name string) (string, bool) {
+	name = strings.ToLower(dns.Fqdn(name))
+	if !p.wildcard {
+		return "", name == p.prefix
+	}
+
+	if !strings.HasPrefix(name, p.prefix) || !strings.HasSuffix(name, p.suffix) {
+		return "", false
+	}
+
+	if len(name) <= len(p.prefix)+len(p.suffix) {
+		return "", false
+	}
+
+	return name[len(p.prefix) : len(name)-len(p.suffix)], true
+}
+
+func (p namePattern) expand(captured string) string {
+	if !p.wildcard {
+		return p.prefix
+	}
+
+	return p.prefix + captured + p.suffix
+}
+
+// base returns the longest name without the wildcard part.
+func (p namePattern) base() string {
+	if !p.wildcard {
+		return p.prefix
+	}
+
+	return strings.TrimPrefix(p.suffix, ".")
+}
@@ -0,0 +1,89 @@
+package lrfc2136
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameMapper(t *testing.T) {
+	m, err := NewNameMapper(
+		NameMapping{From: "_acme-challenge.*.corp.example.", To: "*.acme-validation.example.com."},
+		NameMapping{From: "*.lab.corp.example", To: "*.lab.example.com"},
+		NameMapping{From: "legacy.corp.example.", To: "new.example.com."},
+	)
+	require.NoError(t, err)
+
+	cases := []struct {
+		client   string
+		upstream string
+		mapped   bool
+	}{
+		{"_acme-challenge.app.corp.example.", "app.acme-validation.example.com.", true},
+		{"_acme-challenge.a.b.corp.example.", "a.b.acme-validation.example.com.", true},
+		{"_ACME-Challenge.App.corp.example.", "app.acme-validation.example.com.", true},
+		{"host.lab.corp.example.", "host.lab.example.com.", true},
+		{"legacy.corp.example.", "new.example.com.", true},
+		{"_acme-challenge.corp.example.", "_acme-challenge.corp.example.", false},
+		{"app.corp.example.", "app.corp.example.", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.client, func(t *testing.T) {
+			require.Equal(t, tc.upstream, m.ToUpstream(tc.client))
+
+			if !tc.mapped {
+				return
+			}
+
+			back, ok := m.FromUpstream(tc.upstream)
+			require.True(t, ok)
+			require.Equal(t, dnsLower(tc.client), back)
+		})
+	}
+
+	_, ok := m.FromUpstream("app.example.com.")
+	require.False(t, ok)
+
+	require.ElementsMatch(t,
+		[]string{"acme-validation.example.com.", "lab.example.com.", "new.example.com."},
+		m.UpstreamRoots("corp.example."),
+	)
+	// _acme-challenge.host.lab.corp.example. is mapped by the first mapping
+	require.Equal(t,
+		[]string{"acme-validation.example.com.", "lab.example.com."},
+		m.UpstreamRoots("lab.corp.example."),
+	)
+	require.Empty(t, m.UpstreamRoots("other.example."))
+
+	var nilMapper *NameMapper
+	require.Equal(t, "a.example.", nilMapper.ToUpstream("a.example."))
+}
+
+func TestNameMapperInvalid(t *testing.T) {
+	cases := []NameMapping{
+		{From: "*.corp.example.", To: "static.example.com."},
+		{From: "a*.corp.example.", To: "*.example.com."},
+		{From: "*.*.corp.example.", To: "*.example.com."},
+		{From: "*.", To: "*.example.com."},
+		{From: "bad..name.", To: "ok.example.com."},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.From, func(t *testing.T) {
+			_, err := NewNameMapper(tc)
+			require.Error(t, err)
+		})
+	}
+}
+
+func dnsLower(s string) string {
+	out := []byte(s)
+	for i, c := range out {
+		if c >= 'A' && c <= 'Z' {
+			out[i] = c + ('a' - 'A')
+		}
+	}
+
+	return string(out)
+}
@@ -0,0 +1,213 @@
+package lrfc2136
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// state is the reloadable part of the listener, it's swapped as a whole so requests never mix old and new settings.
+type state struct {
+	upsc    upstream.Upstream
+	clients *Clients
+	secrets map[string]string
+	public  []string
+	mname   string
+	dryRun  bool
+	// requests is read-locked by requests started with the state, so the replaced state knows when they are done
+	requests sync.RWMutex
+}
+
+func newState(cfg *Config) (*state, error) {
+	secrets, err := TsigSecrets(cfg.clients...)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := TsigClients(cfg.clients...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state{
+		upsc:    cfg.upstream,
+		clients: clients,
+		secrets: secrets,
+		public:  cfg.public,
+		mname:   cfg.soaMName,
+		dryRun:  cfg.dryRun,
+	}, nil
+}
+
+// acquireState returns the current state, which is busy until release.
+func (a *Listener) acquireState() *state {
+	for {
+		st := a.state.Load()
+		st.requests.RLock()
+		if a.state.Load() == st {
+			return st
+		}
+
+		// swapped in between, so the replaced state may be already waited for and closed
+		st.requests.RUnlock()
+	}
+}
+
+func (s *state) release() {
+	s.requests.RUnlock()
+}
+
+// wait blocks until requests started with the state are done.
+func (s *state) wait() {
+	s.requests.Lock()
+	defer s.requests.Unlock()
+}
+
+type stateKey struct{}
+
+func withState(ctx context.Context, st *state) context.Context {
+	return context.WithValue(ctx, stateKey{}, st)
+}
+
+// current returns the state the request was started with, so a reload in the middle doesn't affect it.
+func (a *Listener) current(ctx context.Context) *state {
+	if st, ok := ctx.Value(stateKey{}).(*state); ok {
+		return st
+	}
+
+	return a.state.Load()
+}
+
+var (
+	_ dns.TsigProvider = (*tsigProvider)(nil)
+	_ dns.TsigProvider = tsigSecrets(nil)
+)
+
+// tsigProvider verifies requests with secrets of the current state, so keys are swapped atomically with clients
+// on reload while servers keep running. The request may be served with the next state though, so the verified
+// secret is kept by the request MAC and checked by bind against the state the request is served with.
+type tsigProvider struct {
+	state    *atomic.Pointer[state]
+	verified *sync.Map
+}
+
+func newTsigProvider(st *atomic.Pointer[state]) tsigProvider {
+	return tsigProvider{
+		state:    st,
+		verified: new(sync.Map),
+	}
+}
+
+func (p tsigProvider) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	return tsigSecrets(p.state.Load().secrets).Generate(msg, t)
+}
+
+func (p tsigProvider) Verify(msg []byte, t *dns.TSIG) error {
+	secrets := p.state.Load().secrets
+	if err := tsigSecrets(secrets).Verify(msg, t); err != nil {
+		return err
+	}
+
+	p.verified.Store(t.MAC, secrets[t.Hdr.Name])
+	return nil
+}
+
+// bind makes the response writer to check the TSIG status and to sign responses with secrets of the given state.
+func (p tsigProvider) bind(w dns.ResponseWriter, r *dns.Msg, st *state) dns.ResponseWriter {
+	t := r.IsTsig()
+	if t == nil {
+		return w
+	}
+
+	out := &stateTsigWriter{
+		ResponseWriter: w,
+		secrets:        st.secrets,
+		requestMAC:     t.MAC,
+	}
+
+	verified, ok := p.verified.LoadAndDelete(t.MAC)
+	secret, exists := st.secrets[t.Hdr.Name]
+	switch {
+	case w.TsigStatus() != nil:
+	case !exists:
+		out.status = dns.ErrSecret
+	case !ok || verified.(string) != secret:
+		// the key was changed by the reload in between
+		out.status = dns.ErrSig
+	}
+
+	return out
+}
+
+// stateTsigWriter is the response writer bound to the state secrets.
+type stateTsigWriter struct {
+	dns.ResponseWriter
+	secrets    tsigSecrets
+	status     error
+	requestMAC string
+	timersOnly bool
+}
+
+func (w *stateTsigWriter) TsigStatus() error {
+	if w.status != nil {
+		return w.status
+	}
+
+	return w.ResponseWriter.TsigStatus()
+}
+
+func (w *stateTsigWriter) TsigTimersOnly(timersOnly bool) {
+	w.timersOnly = timersOnly
+}
+
+func (w *stateTsigWriter) WriteMsg(m *dns.Msg) error {
+	if m.IsTsig() == nil {
+		return w.ResponseWriter.WriteMsg(m)
+	}
+
+	data, mac, err := dns.TsigGenerateWithProvider(m, w.secrets, w.requestMAC, w.timersOnly)
+	if err != nil {
+		return err
+	}
+
+	w.requestMAC = mac
+	_, err = w.Write(data)
+	return err
+}
+
+// tsigSecrets is the Server.TsigSecret map lookup.
+type tsigSecrets map[string]string
+
+func (s tsigSecrets) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	secret, ok := s[t.Hdr.Name]
+	if !ok {
+		return nil, dns.ErrSecret
+	}
+
+	return tsigkey.MAC(t.Algorithm, secret, msg)
+}
+
+func (s tsigSecrets) Verify(msg []byte, t *dns.TSIG) error {
+	b, err := s.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(b, mac) {
+		return dns.ErrSig
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package lrfc2136
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+)
+
+func testClient(name string) Client {
+	return Client{
+		Name:   name,
+		Secret: base64.StdEncoding.EncodeToString([]byte(strings.Repeat(name, 8))),
+		Zones:  []string{"example.com."},
+	}
+}
+
+func signedMsg(t *testing.T, client Client) []byte {
+	t.Helper()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeSOA)
+	m.SetTsig(client.Name, dns.HmacSHA256, 300, time.Now().Unix())
+	buf, _, err := dns.TsigGenerate(m, client.Secret, "", false)
+	require.NoError(t, err)
+	return buf
+}
+
+func TestReload(t *testing.T) {
+	first := testClient("first.")
+	second := testClient("second.")
+	upc := umemory.NewUpstream()
+
+	l, err := NewListener(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(upc).
+		Clients(first),
+	)
+	require.NoError(t, err)
+
+	provider := l.tsig
+	require.NoError(t, dns.TsigVerifyWithProvider(signedMsg(t, first), provider, "", false))
+	require.ErrorIs(t, dns.TsigVerifyWithProvider(signedMsg(t, second), provider, "", false), dns.ErrSecret)
+
+	// the request keeps the state it was started with
+	inflight := l.acquireState()
+	ctx := withState(context.Background(), inflight)
+
+	// nil upstream keeps the current one
+	wait, err := l.Reload(NewConfig().
+		Addr("127.0.0.1:0").
+		Clients(second).
+		PublicZones("example.com.").
+		DryRun(true),
+	)
+	require.NoError(t, err)
+	require.ErrorIs(t, dns.TsigVerifyWithProvider(signedMsg(t, first), provider, "", false), dns.ErrSecret)
+	require.NoError(t, dns.TsigVerifyWithProvider(signedMsg(t, second), provider, "", false))
+
+	st := l.state.Load()
+	require.Same(t, upc, st.upsc)
+	require.Equal(t, []string{"example.com."}, st.public)
+	require.True(t, st.dryRun)
+	_, err = st.clients.ByName("second.")
+	require.NoError(t, err)
+
+	_, err = l.current(ctx).clients.ByName("first.")
+	require.NoError(t, err)
+	require.False(t, l.current(ctx).dryRun)
+
+	// the replaced state is released only once the request is done
+	waited := make(chan struct{})
+	go func() {
+		wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("replaced state is released while the request is in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	inflight.release()
+	<-waited
+
+	// invalid config is rejected, the current one is kept
+	other := umemory.NewUpstream()
+	_, err = l.Reload(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(other).
+		Clients(first, first),
+	)
+	require.Error(t, err)
+
+	withLease := testClient("lease.")
+	withLease.Lease = time.Hour
+	_, err = l.Reload(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(other).
+		Clients(withLease),
+	)
+	require.Error(t, err)
+	require.Same(t, st, l.state.Load())
+
+	_, err = l.Reload(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(other).
+		Clients(first, second),
+	)
+	require.NoError(t, err)
+	require.Same(t, other, l.state.Load().upsc)
+	require.NoError(t, dns.TsigVerifyWithProvider(signedMsg(t, first), provider, "", false))
+}
+
+// TestTsigProviderMatchesMiekg keeps the provider in sync with the miekg/dns Server.TsigSecret one for every algorithm.
+func TestTsigProviderMatchesMiekg(t *testing.T) {
+	for _, algorithm := range tsigkey.Algorithms() {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := tsigkey.NewKey("tst.", algorithm)
+			require.NoError(t, err)
+
+			l, err := NewListener(NewConfig().
+				Addr("127.0.0.1:0").
+				Upstream(umemory.NewUpstream()).
+				Clients(Client{Name: key.Name, Secret: key.Secret, Zones: []string{"example.com."}}),
+			)
+			require.NoError(t, err)
+			provider := l.tsig
+
+			req := new(dns.Msg)
+			req.SetQuestion("example.com.", dns.TypeSOA)
+			req.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+			buf, reqMAC, err := dns.TsigGenerate(req, key.Secret, "", false)
+			require.NoError(t, err)
+			require.NoError(t, dns.TsigVerifyWithProvider(buf, provider, "", false))
+
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+			resp.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+			buf, _, err = dns.TsigGenerateWithProvider(resp, provider, reqMAC, false)
+			require.NoError(t, err)
+			require.NoError(t, dns.TsigVerify(buf, key.Secret, reqMAC, false))
+		})
+	}
+}
+
+func TestTsigBind(t *testing.T) {
+	client := testClient("tst.")
+	l, err := NewListener(NewConfig().
+		Addr("127.0.0.1:0").
+		Upstream(umemory.NewUpstream()).
+		Clients(client),
+	)
+	require.NoError(t, err)
+
+	verify := func(client Client) (*dns.Msg, error) {
+		buf := signedMsg(t, client)
+		r := new(dns.Msg)
+		require.NoError(t, r.Unpack(buf))
+		return r, dns.TsigVerifyWithProvider(buf, l.tsig, "", false)
+	}
+
+	// the key is the same in the state the request is served with
+	r, err := verify(client)
+	require.NoError(t, err)
+	w := &testWriter{}
+	bound := l.tsig.bind(w, r, l.acquireState())
+	require.NoError(t, bound.TsigStatus())
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.SetTsig(client.Name, dns.HmacSHA256, 300, time.Now().Unix())
+	require.NoError(t, bound.WriteMsg(m))
+	require.NoError(t, dns.TsigVerify(w.data, client.Secret, r.IsTsig().MAC, false))
+
+	// the key is rotated between the verification and the state acquisition
+	r, err = verify(client)
+	require.NoError(t, err)
+	rotated := client
+	rotated.Secret = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("rotated.", 8)))
+	_, err = l.Reload(NewConfig().
+		Addr("127.0.0.1:0").
+		Clients(rotated),
+	)
+	require.NoError(t, err)
+	require.ErrorIs(t, l.tsig.bind(&testWriter{}, r, l.acquireState()).TsigStatus(), dns.ErrSig)
+
+	// the key is removed
+	r, err = verify(rotated)
+	require.NoError(t, err)
+	_, err = l.Reload(NewConfig().
+		Addr("127.0.0.1:0").
+		Clients(testClient("other.")),
+	)
+	require.NoError(t, err)
+	require.ErrorIs(t, l.tsig.bind(&testWriter{}, r, l.acquireState()).TsigStatus(), dns.ErrSecret)
+}
+
+type testWriter struct {
+	dns.ResponseWriter
+	data []byte
+}
+
+func (w *testWriter) TsigStatus() error {
+	return nil
+}
+
+func (w *testWriter) Write(data []byte) (int, error) {
+	w.data = data
+	return len(data), nil
+}
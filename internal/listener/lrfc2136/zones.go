@@ -0,0 +1,45 @@
+package lrfc2136
+
+import (
+	"math"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/fqdn"
+)
+
+func MatchZone(zones []string, name string) string {
+	name = dns.Fqdn(name)
+	for _, zone := range zones {
+		if dns.IsSubDomain(dns.Fqdn(zone), name) {
+			return zone
+		}
+	}
+
+	return ""
+}
+
+// ZoneSOA returns the zone SOA with the given primary name server, the zone apex itself is used unless it's set.
+func ZoneSOA(zone string, mname string) *dns.SOA {
+	const ttl = 60
+	if mname == "" {
+		mname = zone
+	}
+
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   fqdn.FQDN(zone),
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Ns:      fqdn.FQDN(mname),
+		Mbox:    fqdn.FQDN("admin." + zone),
+		Serial:  uint32(time.Now().Unix() % math.MaxUint32),
+		Refresh: ttl,
+		Retry:   ttl,
+		Expire:  ttl,
+		Minttl:  ttl,
+	}
+}
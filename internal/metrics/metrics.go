@@ -0,0 +1,174 @@
+// Package metrics keeps DNSGateway Prometheus collectors.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "dnsgateway"
+
+// Registry holds every DNSGateway collector together with the Go runtime and process ones.
+var Registry = prometheus.NewRegistry()
+
+var (
+	Requests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Handled DNS requests by opcode, TSIG client and response code.",
+		},
+		[]string{"opcode", "client", "rcode"},
+	)
+
+	RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "DNS request handling latency by opcode and TSIG client.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"opcode", "client"},
+	)
+
+	UpdateOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "update_operations_total",
+			Help:      "Committed RFC 2136 update operations by client, record type and action.",
+		},
+		[]string{"client", "type", "action"},
+	)
+
+	UpstreamDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_duration_seconds",
+			Help:      "Upstream operation latency by backend and operation (query, fetch, commit).",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"backend", "op"},
+	)
+
+	UpstreamErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Failed upstream operations by backend and operation (query, fetch, commit).",
+		},
+		[]string{"backend", "op"},
+	)
+
+	XFRRecords = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "xfr_records",
+			Help:      "Records sent within a zone transfer by client.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		},
+		[]string{"client"},
+	)
+
+	TSIGFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tsig_failures_total",
+			Help:      "Refused requests by TSIG client and failure reason, the client is empty for missing or unknown keys.",
+		},
+		[]string{"client", "reason"},
+	)
+
+	LockWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "lock_wait_seconds",
+			Help:      "Time spent waiting for the upstream transaction lock by holder (update, janitor).",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		},
+		[]string{"holder"},
+	)
+
+	Notifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notifications_total",
+			Help:      "Change notifications by sink and result (ok, failed, dropped).",
+		},
+		[]string{"sink", "result"},
+	)
+
+	LeaseExpiredRemoved = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "lease_expired_removed_total",
+			Help:      "Records removed by the lease janitor after their lease expired, by the owner client.",
+		},
+		[]string{"client"},
+	)
+
+	AdguardReplays = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "adguard_replays_total",
+			Help:      "AdGuard Home transactions replayed on top of concurrently changed user rules.",
+		},
+	)
+
+	AdguardConflicts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "adguard_conflicts_total",
+			Help:      "AdGuard Home transactions failed due to conflicting concurrent changes of user rules.",
+		},
+	)
+
+	AdguardQuarantined = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "adguard_quarantined_rules",
+			Help:      "Unparseable AdGuard Home rule lines inside the DNSGateway block seen last time.",
+		},
+	)
+
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Config reloads by result (ok, failed).",
+		},
+		[]string{"result"},
+	)
+
+	ConfigLastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last config reload succeeded (1) or the previous config is still in use (0).",
+		},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Requests,
+		RequestDuration,
+		UpdateOperations,
+		UpstreamDuration,
+		UpstreamErrors,
+		XFRRecords,
+		TSIGFailures,
+		LockWait,
+		Notifications,
+		LeaseExpiredRemoved,
+		AdguardReplays,
+		AdguardConflicts,
+		AdguardQuarantined,
+		ConfigReloads,
+		ConfigLastReloadSuccess,
+	)
+
+	// nothing is reloaded yet, so the running config is the good one
+	ConfigLastReloadSuccess.Set(1)
+}
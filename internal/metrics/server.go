@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultPath       = "/metrics"
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Server exposes the Registry over HTTP.
+type Server struct {
+	srv *http.Server
+}
+
+func NewServer(addr, path string) *Server {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		Registry: Registry,
+	}))
+
+	return &Server{
+		srv: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+func (s *Server) ListenAndServe() error {
+	log.Info().
+		Str("source", "metrics").
+		Str("addr", s.srv.Addr).
+		Msg("started")
+
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,126 @@
+// Package notify delivers committed record changes to the outbound sinks, e.g. webhooks.
+package notify
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// Actions are the same as the RFC 2136 update operations in metrics.
+const (
+	ActionAdd          = "add"
+	ActionDeleteRR     = "delete_rr"
+	ActionDeleteRRset  = "delete_rrset"
+	ActionDeleteByName = "delete_name"
+	// ActionDelete matches every delete action in filters.
+	ActionDelete = "delete"
+)
+
+// Change is a single committed record change.
+type Change struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	// Type is empty for ActionDeleteByName
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	Owner string `json:"owner,omitempty"`
+}
+
+func NewChange(action string, r upstream.Rule) Change {
+	out := Change{
+		Action: action,
+		Name:   r.Name,
+		Value:  r.ValueStr,
+		Owner:  r.Owner,
+	}
+
+	if r.Type != dns.TypeNone {
+		out.Type = upstream.TypeString(r.Type)
+	}
+
+	if !r.Scope.IsZero() {
+		out.Scope = r.Scope.String()
+	}
+
+	return out
+}
+
+// Event is the set of changes committed within a single upstream transaction.
+type Event struct {
+	Time     time.Time `json:"time"`
+	AuditID  string    `json:"audit_id,omitempty"`
+	Client   string    `json:"client"`
+	Source   string    `json:"source,omitempty"`
+	Upstream string    `json:"upstream"`
+	Changes  []Change  `json:"changes"`
+}
+
+// Filter selects changes delivered to the sink, empty fields match everything.
+type Filter struct {
+	// Zones match names within them, subdomains included
+	Zones   []string
+	Clients []string
+	Types   []string
+	// Actions are either actions or ActionDelete for all the delete ones
+	Actions []string
+}
+
+// Apply returns the event with the matching changes only, false means nothing is left to deliver.
+func (f *Filter) Apply(ev Event) (Event, bool) {
+	if len(f.Clients) > 0 && !slices.Contains(f.Clients, ev.Client) {
+		return Event{}, false
+	}
+
+	changes := make([]Change, 0, len(ev.Changes))
+	for _, ch := range ev.Changes {
+		if f.matchChange(ch) {
+			changes = append(changes, ch)
+		}
+	}
+
+	if len(changes) == 0 {
+		return Event{}, false
+	}
+
+	ev.Changes = changes
+	return ev, true
+}
+
+func (f *Filter) matchChange(ch Change) bool {
+	if len(f.Zones) > 0 && !slices.ContainsFunc(f.Zones, func(zone string) bool {
+		return dns.IsSubDomain(dns.Fqdn(zone), ch.Name)
+	}) {
+		return false
+	}
+
+	// deletion of the whole name touches every type
+	if len(f.Types) > 0 && ch.Type != "" && !slices.ContainsFunc(f.Types, func(typ string) bool {
+		return strings.EqualFold(typ, ch.Type)
+	}) {
+		return false
+	}
+
+	if len(f.Actions) > 0 && !slices.ContainsFunc(f.Actions, func(action string) bool {
+		return action == ch.Action || action == ActionDelete && strings.HasPrefix(ch.Action, ActionDelete+"_")
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// ValidAction reports whether the action may be used in filters.
+func ValidAction(action string) bool {
+	switch action {
+	case ActionAdd, ActionDeleteRR, ActionDeleteRRset, ActionDeleteByName, ActionDelete:
+		return true
+	default:
+		return false
+	}
+}
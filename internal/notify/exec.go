@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var _ Sink = (*ExecSink)(nil)
+
+// ExecSink runs the command for every event, the event JSON is passed on stdin.
+type ExecSink struct {
+	command []string
+}
+
+func NewExecSink(command ...string) (*ExecSink, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("exec command is empty")
+	}
+
+	return &ExecSink{
+		command: command,
+	}, nil
+}
+
+func (s *ExecSink) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *ExecSink) Close() error {
+	return nil
+}
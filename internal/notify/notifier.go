@@ -0,0 +1,242 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+)
+
+const (
+	DefaultQueueSize    = 100
+	DefaultRetries      = 5
+	DefaultRetryWait    = time.Second
+	DefaultRetryMaxWait = time.Minute
+	DefaultTimeout      = 10 * time.Second
+)
+
+const (
+	resultOK      = "ok"
+	resultFailed  = "failed"
+	resultDropped = "dropped"
+)
+
+// Sink delivers events, e.g. to the webhook.
+type Sink interface {
+	Send(ctx context.Context, ev Event) error
+	Close() error
+}
+
+// Target is the sink together with its filter and delivery settings, zero settings mean defaults.
+type Target struct {
+	Name   string
+	Sink   Sink
+	Filter Filter
+	// QueueSize is the number of events waiting for delivery, the newer ones are dropped once it's full
+	QueueSize    int
+	Retries      int
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+	// Timeout limits a single delivery attempt
+	Timeout time.Duration
+}
+
+// Notifier delivers events to every target in background, so a slow sink never blocks DNS updates.
+// Nil Notifier drops events.
+type Notifier struct {
+	upstream string
+	targets  []*worker
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+	log      zerolog.Logger
+}
+
+type worker struct {
+	Target
+	queue chan Event
+	log   zerolog.Logger
+}
+
+// NewNotifier starts delivery workers, upstream is the upstream name stamped into every event.
+func NewNotifier(upstream string, targets ...Target) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		upstream: upstream,
+		ctx:      ctx,
+		cancel:   cancel,
+		log: log.With().
+			Str("source", "notify").
+			Logger(),
+	}
+
+	for _, t := range targets {
+		if t.QueueSize <= 0 {
+			t.QueueSize = DefaultQueueSize
+		}
+
+		if t.Retries < 0 {
+			t.Retries = 0
+		}
+
+		if t.RetryWait <= 0 {
+			t.RetryWait = DefaultRetryWait
+		}
+
+		if t.RetryMaxWait < t.RetryWait {
+			t.RetryMaxWait = max(DefaultRetryMaxWait, t.RetryWait)
+		}
+
+		if t.Timeout <= 0 {
+			t.Timeout = DefaultTimeout
+		}
+
+		w := &worker{
+			Target: t,
+			queue:  make(chan Event, t.QueueSize),
+			log: n.log.With().
+				Str("sink", t.Name).
+				Logger(),
+		}
+		n.targets = append(n.targets, w)
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			w.run(ctx)
+		}()
+	}
+
+	return n
+}
+
+// Enabled reports whether events are delivered somewhere, so callers may skip collecting them.
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.targets) > 0
+}
+
+// Notify queues the event for every target it passes the filter of. It never blocks: events are dropped
+// once the target queue is full.
+func (n *Notifier) Notify(ev Event) {
+	if !n.Enabled() || len(ev.Changes) == 0 {
+		return
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	ev.Upstream = n.upstream
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	for _, w := range n.targets {
+		filtered, ok := w.Filter.Apply(ev)
+		if !ok {
+			continue
+		}
+
+		select {
+		case w.queue <- filtered:
+		default:
+			metrics.Notifications.WithLabelValues(w.Name, resultDropped).Inc()
+			w.log.Warn().Str("client", ev.Client).Msg("queue is full, event dropped")
+		}
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered until the context is done.
+func (n *Notifier) Close(ctx context.Context) error {
+	if !n.Enabled() {
+		return nil
+	}
+
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	for _, w := range n.targets {
+		close(w.queue)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	var errs []error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// abort pending deliveries and retries
+		n.cancel()
+		<-done
+		errs = append(errs, fmt.Errorf("undelivered events left: %w", ctx.Err()))
+	}
+	n.cancel()
+
+	for _, w := range n.targets {
+		if err := w.Sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %q sink: %w", w.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (w *worker) run(ctx context.Context) {
+	for ev := range w.queue {
+		result := resultOK
+		if err := w.deliver(ctx, ev); err != nil {
+			result = resultFailed
+			w.log.Error().Err(err).Str("client", ev.Client).Msg("event delivery failed")
+		}
+
+		metrics.Notifications.WithLabelValues(w.Name, result).Inc()
+	}
+}
+
+func (w *worker) deliver(ctx context.Context, ev Event) error {
+	wait := w.RetryWait
+	for attempt := 0; ; attempt++ {
+		err := w.send(ctx, ev)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= w.Retries {
+			return fmt.Errorf("give up after %d attempts: %w", attempt+1, err)
+		}
+
+		w.log.Warn().Err(err).Int("attempt", attempt+1).Stringer("retry_in", wait).Msg("event delivery failed, retrying")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("aborted: %w", err)
+		case <-time.After(wait):
+		}
+
+		wait = min(wait*2, w.RetryMaxWait)
+	}
+}
+
+func (w *worker) send(ctx context.Context, ev Event) error {
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	return w.Sink.Send(ctx, ev)
+}
@@ -0,0 +1,278 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/notify"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+func testEvent(t *testing.T) notify.Event {
+	acme, err := upstream.NewRule("_acme-challenge.example.com.", dns.TypeTXT, "token")
+	require.NoError(t, err)
+
+	prod, err := upstream.NewRule("app.prod.example.com.", dns.TypeA, "1.2.3.4")
+	require.NoError(t, err)
+	prod.Owner = "certbot."
+
+	return notify.Event{
+		Client: "certbot.",
+		Changes: []notify.Change{
+			notify.NewChange(notify.ActionAdd, acme),
+			notify.NewChange(notify.ActionDeleteRR, prod),
+			notify.NewChange(notify.ActionDeleteByName, upstream.Rule{Name: "old.prod.example.com.", Type: dns.TypeNone}),
+		},
+	}
+}
+
+func changeNames(ev notify.Event) []string {
+	out := make([]string, len(ev.Changes))
+	for i, ch := range ev.Changes {
+		out[i] = ch.Name
+	}
+	return out
+}
+
+func TestFilter(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter notify.Filter
+		names  []string
+	}{
+		{
+			name:  "empty",
+			names: []string{"_acme-challenge.example.com.", "app.prod.example.com.", "old.prod.example.com."},
+		},
+		{
+			name:   "zones",
+			filter: notify.Filter{Zones: []string{"prod.example.com."}},
+			names:  []string{"app.prod.example.com.", "old.prod.example.com."},
+		},
+		{
+			name:   "types",
+			filter: notify.Filter{Types: []string{"a", "AAAA"}},
+			names:  []string{"app.prod.example.com.", "old.prod.example.com."},
+		},
+		{
+			name:   "actions",
+			filter: notify.Filter{Actions: []string{notify.ActionDelete}},
+			names:  []string{"app.prod.example.com.", "old.prod.example.com."},
+		},
+		{
+			name:   "exact action",
+			filter: notify.Filter{Actions: []string{notify.ActionAdd, notify.ActionDeleteRR}},
+			names:  []string{"_acme-challenge.example.com.", "app.prod.example.com."},
+		},
+		{
+			name:   "clients",
+			filter: notify.Filter{Clients: []string{"external-dns."}},
+		},
+		{
+			name:   "nothing left",
+			filter: notify.Filter{Zones: []string{"example.org."}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ev, ok := tc.filter.Apply(testEvent(t))
+			require.Equal(t, len(tc.names) > 0, ok)
+			if ok {
+				require.Equal(t, tc.names, changeNames(ev))
+			}
+		})
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	const secret = "s3cr3t"
+	var calls atomic.Int32
+	got := make(chan notify.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts := r.Header.Get(xhttp.HeaderTimestamp)
+		if r.Header.Get(xhttp.HeaderSignature) != xhttp.Sign([]byte(secret), ts, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		var ev notify.Event
+		_ = json.Unmarshal(body, &ev)
+		got <- ev
+	}))
+	defer srv.Close()
+
+	sink, err := notify.NewWebhookSink(srv.URL, notify.WithHMACSecret(secret), notify.WithHTTPClient(srv.Client()))
+	require.NoError(t, err)
+
+	n := notify.NewNotifier("test", notify.Target{
+		Name:      "oncall",
+		Sink:      sink,
+		Filter:    notify.Filter{Zones: []string{"prod.example.com."}},
+		Retries:   2,
+		RetryWait: time.Millisecond,
+	})
+	n.Notify(testEvent(t))
+
+	select {
+	case ev := <-got:
+		require.Equal(t, "certbot.", ev.Client)
+		require.Equal(t, "test", ev.Upstream)
+		require.Equal(t, []string{"app.prod.example.com.", "old.prod.example.com."}, changeNames(ev))
+		require.Equal(t, notify.Change{Action: notify.ActionDeleteRR, Name: "app.prod.example.com.", Type: "A", Value: "1.2.3.4", Owner: "certbot."}, ev.Changes[0])
+		require.Equal(t, notify.Change{Action: notify.ActionDeleteByName, Name: "old.prod.example.com."}, ev.Changes[1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	require.NoError(t, n.Close(context.Background()))
+	require.EqualValues(t, 2, calls.Load())
+}
+
+type blockingSink struct {
+	entered chan struct{}
+	release chan struct{}
+	sent    atomic.Int32
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{
+		entered: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (s *blockingSink) Send(ctx context.Context, _ notify.Event) error {
+	s.entered <- struct{}{}
+	select {
+	case <-s.release:
+		s.sent.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestNotifyNeverBlocks(t *testing.T) {
+	sink := newBlockingSink()
+	n := notify.NewNotifier("test", notify.Target{
+		Name:      "slow",
+		Sink:      sink,
+		QueueSize: 2,
+		Timeout:   time.Minute,
+	})
+	n.Notify(testEvent(t))
+	<-sink.entered
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 10 {
+			n.Notify(testEvent(t))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify blocked on the slow sink")
+	}
+
+	close(sink.release)
+	require.NoError(t, n.Close(context.Background()))
+	// one is in flight, two are queued, the rest are dropped
+	require.EqualValues(t, 3, sink.sent.Load())
+}
+
+func TestCloseTimeout(t *testing.T) {
+	sink := newBlockingSink()
+	n := notify.NewNotifier("test", notify.Target{
+		Name:    "stuck",
+		Sink:    sink,
+		Timeout: time.Minute,
+	})
+	n.Notify(testEvent(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := n.Close(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualValues(t, 0, sink.sent.Load())
+
+	// events after close are silently dropped
+	n.Notify(testEvent(t))
+}
+
+func TestFileAndExecSinks(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "events.jsonl")
+	fileSink, err := notify.NewFileSink(filePath)
+	require.NoError(t, err)
+
+	execPath := filepath.Join(dir, "exec.json")
+	execSink, err := notify.NewExecSink("sh", "-c", `cat > "$0"`, execPath)
+	require.NoError(t, err)
+
+	failSink, err := notify.NewExecSink("sh", "-c", "echo boom >&2; exit 1")
+	require.NoError(t, err)
+	err = failSink.Send(context.Background(), testEvent(t))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "boom")
+
+	n := notify.NewNotifier("test",
+		notify.Target{Name: "file", Sink: fileSink},
+		notify.Target{Name: "exec", Sink: execSink, Filter: notify.Filter{Actions: []string{notify.ActionAdd}}},
+	)
+	n.Notify(testEvent(t))
+	n.Notify(notify.Event{Client: "empty"})
+	require.NoError(t, n.Close(context.Background()))
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+
+	var ev notify.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &ev))
+	require.Len(t, ev.Changes, 3)
+	require.False(t, ev.Time.IsZero())
+
+	data, err = os.ReadFile(execPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &ev))
+	require.Equal(t, []string{"_acme-challenge.example.com."}, changeNames(ev))
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *notify.Notifier
+	require.False(t, n.Enabled())
+	n.Notify(testEvent(t))
+	require.NoError(t, n.Close(context.Background()))
+
+	_, err := notify.NewWebhookSink("")
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+var _ Sink = (*WebhookSink)(nil)
+
+// WebhookSink POSTs events as JSON, signed the same way as the webhook upstream requests.
+type WebhookSink struct {
+	httpc      *http.Client
+	url        string
+	hmacSecret []byte
+	headers    http.Header
+}
+
+type WebhookOption func(*WebhookSink)
+
+func WithHMACSecret(secret string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.hmacSecret = []byte(secret)
+	}
+}
+
+func WithHeader(name, value string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.headers.Set(name, value)
+	}
+}
+
+func WithHTTPClient(httpc *http.Client) WebhookOption {
+	return func(s *WebhookSink) {
+		s.httpc = httpc
+	}
+}
+
+func NewWebhookSink(url string, opts ...WebhookOption) (*WebhookSink, error) {
+	if url == "" {
+		return nil, errors.New("webhook url is empty")
+	}
+
+	s := &WebhookSink{
+		httpc:   xhttp.NewHTTPClient(),
+		url:     url,
+		headers: make(http.Header),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+func (s *WebhookSink) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header = s.headers.Clone()
+	req.Header.Set("User-Agent", "DNSGateway")
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.hmacSecret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(xhttp.HeaderTimestamp, ts)
+		req.Header.Set(xhttp.HeaderSignature, xhttp.Sign(s.hmacSecret, ts, body))
+	}
+
+	rsp, err := s.httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, rsp.Body)
+		_ = rsp.Body.Close()
+	}()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("non-2xx status code: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	return nil
+}
@@ -0,0 +1,244 @@
+package nsupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+	tsigFudge      = 300
+)
+
+// Client sends updates and queries to the RFC 2136 server, signing them with the TSIG key if any.
+type Client struct {
+	server  string
+	net     string
+	timeout time.Duration
+	key     *tsigkey.Key
+}
+
+type ClientOption func(*Client)
+
+// WithKey signs requests with the TSIG key.
+func WithKey(key tsigkey.Key) ClientOption {
+	return func(c *Client) {
+		c.key = &key
+	}
+}
+
+// WithTCP sends requests over TCP instead of UDP, zone transfers always use TCP.
+func WithTCP(tcp bool) ClientOption {
+	return func(c *Client) {
+		if tcp {
+			c.net = "tcp"
+		}
+	}
+}
+
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+func NewClient(server string, opts ...ClientOption) *Client {
+	c := &Client{
+		server:  server,
+		net:     "udp",
+		timeout: DefaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Update sends the update message of the zone.
+func (c *Client) Update(ctx context.Context, zone string, updates []dns.RR) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone))
+	m.Ns = append(m.Ns, updates...)
+	return c.exchange(ctx, m)
+}
+
+// Query sends the query, the response with error rcode is returned together with the ResponseError.
+func (c *Client) Query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	return c.exchange(ctx, m)
+}
+
+// FindZone returns the zone of the name: the closest ancestor the server answers SOA for.
+func (c *Client) FindZone(ctx context.Context, name string) (string, error) {
+	name = dns.Fqdn(name)
+	var lastErr error
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		zone := name[off:]
+		r, err := c.Query(ctx, zone, dns.TypeSOA)
+		if err != nil {
+			var respErr *ResponseError
+			if errors.As(err, &respErr) && respErr.TsigError == 0 {
+				// not a zone apex, try the parent one
+				lastErr = err
+				continue
+			}
+
+			return "", err
+		}
+
+		for _, rr := range r.Answer {
+			if soa, ok := rr.(*dns.SOA); ok && soa.Hdr.Name == zone {
+				return zone, nil
+			}
+		}
+	}
+
+	if lastErr != nil {
+		return "", fmt.Errorf("no zone found for %q, last response: %w", name, lastErr)
+	}
+
+	return "", fmt.Errorf("no zone found for %q", name)
+}
+
+// Transfer requests the zone transfer and returns all records of the zone.
+func (c *Client) Transfer(ctx context.Context, zone string) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zone))
+
+	t := &dns.Transfer{
+		DialTimeout:  c.timeout,
+		ReadTimeout:  c.timeout,
+		WriteTimeout: c.timeout,
+	}
+	if c.key != nil {
+		m.SetTsig(c.key.Name, c.key.Algorithm, tsigFudge, time.Now().Unix())
+		t.TsigSecret = map[string]string{c.key.Name: c.key.Secret}
+	}
+
+	ch, err := t.In(m, c.server)
+	if err != nil {
+		return nil, describeError(err)
+	}
+
+	var out []dns.RR
+	for {
+		select {
+		case <-ctx.Done():
+			return out, ctx.Err()
+		case env, ok := <-ch:
+			if !ok {
+				return out, nil
+			}
+
+			if env.Error != nil {
+				return out, describeError(env.Error)
+			}
+
+			out = append(out, env.RR...)
+		}
+	}
+}
+
+func (c *Client) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{
+		Net:     c.net,
+		Timeout: c.timeout,
+	}
+	if c.key != nil {
+		m.SetTsig(c.key.Name, c.key.Algorithm, tsigFudge, time.Now().Unix())
+		client.TsigSecret = map[string]string{c.key.Name: c.key.Secret}
+	}
+
+	r, _, err := client.ExchangeContext(ctx, m, c.server)
+	if err != nil {
+		return r, describeError(err)
+	}
+
+	if err := responseError(r); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// ResponseError is the failed response, with the TSIG error if the server rejected the signature.
+type ResponseError struct {
+	Rcode     int
+	TsigError uint16
+}
+
+func (e *ResponseError) Error() string {
+	msg := rcodeString(e.Rcode)
+	if hint, ok := rcodeHints[e.Rcode]; ok && e.TsigError == 0 {
+		msg += ": " + hint
+	}
+
+	if e.TsigError != 0 {
+		msg += ", TSIG error " + rcodeString(int(e.TsigError))
+		if hint, ok := rcodeHints[int(e.TsigError)]; ok {
+			msg += ": " + hint
+		}
+	}
+
+	return msg
+}
+
+var rcodeHints = map[int]string{
+	dns.RcodeRefused:       "the key is unknown, its secret doesn't match, or it isn't allowed to update or query this name or type",
+	dns.RcodeNotAuth:       "the request isn't signed or the key is unknown to the server",
+	dns.RcodeNameError:     "the name doesn't exist or is not within zones of the key",
+	dns.RcodeNotZone:       "the name is not within the zone",
+	dns.RcodeBadSig:        "the signature doesn't match, check the key secret and algorithm",
+	dns.RcodeBadKey:        "the key name is unknown to the server",
+	dns.RcodeBadTime:       "the request time is out of the allowed window, check clocks",
+	dns.RcodeServerFailure: "the server failed to process the request, see its logs",
+}
+
+func responseError(r *dns.Msg) error {
+	if r.Rcode == dns.RcodeSuccess {
+		return nil
+	}
+
+	out := &ResponseError{
+		Rcode: r.Rcode,
+	}
+	if t := r.IsTsig(); t != nil {
+		out.TsigError = t.Error
+	}
+
+	return out
+}
+
+func describeError(err error) error {
+	switch {
+	case errors.Is(err, dns.ErrSig):
+		return fmt.Errorf("%w: the response signature is invalid, check the key secret", err)
+	case errors.Is(err, dns.ErrTime):
+		return fmt.Errorf("%w: the response time is out of the allowed window, check clocks", err)
+	case errors.Is(err, dns.ErrNoSig):
+		return fmt.Errorf("%w: the server rejected the request unsigned, check the key name, secret and algorithm", err)
+	case errors.Is(err, dns.ErrSecret):
+		return fmt.Errorf("%w: the key is unknown", err)
+	default:
+		return err
+	}
+}
+
+func rcodeString(rcode int) string {
+	if s, ok := dns.RcodeToString[rcode]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("RCODE%d", rcode)
+}
@@ -0,0 +1,87 @@
+package nsupdate_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/nsupdate"
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+)
+
+func newTestServer(t *testing.T, key tsigkey.Key) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &dns.Server{
+		PacketConn: pc,
+		TsigSecret: map[string]string{key.Name: key.Secret},
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept
+		},
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			switch {
+			case req.IsTsig() == nil || w.TsigStatus() != nil:
+				m.Rcode = dns.RcodeRefused
+			case req.Opcode == dns.OpcodeUpdate:
+			case req.Question[0].Name == "example.com." && req.Question[0].Qtype == dns.TypeSOA:
+				m.Answer = append(m.Answer, &dns.SOA{
+					Hdr:  dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+					Ns:   "ns.example.com.",
+					Mbox: "hostmaster.example.com.",
+				})
+			default:
+				m.Rcode = dns.RcodeNameError
+			}
+
+			if req.IsTsig() != nil && w.TsigStatus() == nil {
+				m.SetTsig(key.Name, key.Algorithm, 300, int64(req.IsTsig().TimeSigned))
+			}
+			_ = w.WriteMsg(m)
+		}),
+	}
+
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	<-started
+	return pc.LocalAddr().String()
+}
+
+func TestClient(t *testing.T) {
+	key, err := tsigkey.NewKey("tst.", "")
+	require.NoError(t, err)
+
+	addr := newTestServer(t, key)
+	ctx := context.Background()
+
+	client := nsupdate.NewClient(addr, nsupdate.WithKey(key))
+	zone, err := client.FindZone(ctx, "a.b.example.com")
+	require.NoError(t, err)
+	require.Equal(t, "example.com.", zone)
+
+	rrs, err := nsupdate.ParseOperation("add", "a.example.com. 60 A 192.0.2.1", nil)
+	require.NoError(t, err)
+	_, err = client.Update(ctx, zone, rrs)
+	require.NoError(t, err)
+
+	badKey, err := tsigkey.NewKey("tst.", "")
+	require.NoError(t, err)
+
+	_, err = nsupdate.NewClient(addr, nsupdate.WithKey(badKey)).Update(ctx, zone, rrs)
+	var respErr *nsupdate.ResponseError
+	require.True(t, errors.As(err, &respErr), err)
+	require.Equal(t, dns.RcodeRefused, respErr.Rcode)
+	require.Contains(t, err.Error(), "secret doesn't match")
+
+	_, err = nsupdate.NewClient(addr).Update(ctx, zone, rrs)
+	require.Error(t, err)
+}
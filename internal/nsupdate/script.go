@@ -0,0 +1,233 @@
+// Package nsupdate parses nsupdate(1) scripts into RFC 2136 update messages and sends them.
+package nsupdate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// Message is the single update message, which is sent by the "send" command or by the blank line.
+type Message struct {
+	Zone    string
+	Updates []dns.RR
+}
+
+// ParseScript parses nsupdate commands, supported ones are:
+//
+//	zone <name>
+//	ttl <seconds>
+//	[update] add <name> [ttl] [class] <type> <rdata>
+//	[update] del[ete] <name> [ttl] [class] [<type> [<rdata>]]
+//	send
+//
+// Connection related commands (server, key, etc.) are ignored, prerequisites are not supported.
+func ParseScript(r io.Reader) ([]Message, error) {
+	var out []Message
+	var cur Message
+	var ttl *uint32
+
+	send := func() {
+		if len(cur.Updates) > 0 {
+			out = append(out, cur)
+		}
+
+		cur = Message{
+			Zone: cur.Zone,
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			send()
+			continue
+		}
+
+		if strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		cmd, args := cutWord(line)
+		if strings.EqualFold(cmd, "update") {
+			cmd, args = cutWord(args)
+		}
+
+		var err error
+		switch strings.ToLower(cmd) {
+		case "send":
+			send()
+		case "quit":
+			send()
+			return out, nil
+		case "zone":
+			cur.Zone = dns.Fqdn(args)
+		case "ttl":
+			var v uint64
+			v, err = strconv.ParseUint(args, 10, 32)
+			if err == nil {
+				ttl32 := uint32(v)
+				ttl = &ttl32
+			}
+		case "add", "del", "delete":
+			var rrs []dns.RR
+			rrs, err = ParseOperation(cmd, args, ttl)
+			if err == nil {
+				cur.Updates = append(cur.Updates, rrs...)
+			}
+		case "prereq":
+			err = errors.New("prerequisites are not supported")
+		case "server", "local", "key", "class", "realm", "gsstsig", "oldgsstsig", "debug", "show", "answer":
+			// connection and debug commands don't change the update
+		default:
+			err = fmt.Errorf("unknown command %q", cmd)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read script: %w", err)
+	}
+
+	send()
+	return out, nil
+}
+
+// ParseOperation parses the single update operation: add and delete ones have the nsupdate syntax,
+// replace has the add one and deletes the RRset of the added record first.
+func ParseOperation(op, args string, ttl *uint32) ([]dns.RR, error) {
+	switch strings.ToLower(op) {
+	case "add":
+		rr, err := parseAdd(args, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return []dns.RR{rr}, nil
+	case "del", "delete":
+		rr, err := parseDelete(args)
+		if err != nil {
+			return nil, err
+		}
+		return []dns.RR{rr}, nil
+	case "replace":
+		rr, err := parseAdd(args, ttl)
+		if err != nil {
+			return nil, err
+		}
+
+		rrset := &dns.ANY{
+			Hdr: dns.RR_Header{
+				Name:   rr.Header().Name,
+				Rrtype: rr.Header().Rrtype,
+				Class:  dns.ClassANY,
+			},
+		}
+		return []dns.RR{rrset, rr}, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q: add, delete or replace is expected", op)
+	}
+}
+
+func parseAdd(args string, defaultTTL *uint32) (dns.RR, error) {
+	name, rest := cutWord(args)
+	if name == "" || rest == "" {
+		return nil, errors.New("add requires name, type and rdata")
+	}
+
+	ttl, _ := cutWord(rest)
+	if _, err := strconv.ParseUint(ttl, 10, 32); err != nil {
+		if defaultTTL == nil {
+			return nil, errors.New("ttl is not specified")
+		}
+
+		rest = strconv.FormatUint(uint64(*defaultTTL), 10) + " " + rest
+	}
+
+	rr, err := dns.NewRR(dns.Fqdn(name) + " " + rest)
+	if err != nil {
+		return nil, fmt.Errorf("parse rr: %w", err)
+	}
+
+	if rr == nil {
+		return nil, errors.New("empty rr")
+	}
+
+	return rr, nil
+}
+
+func parseDelete(args string) (dns.RR, error) {
+	name, rest := cutWord(args)
+	if name == "" {
+		return nil, errors.New("delete requires name")
+	}
+	name = dns.Fqdn(name)
+
+	word, tail := cutWord(rest)
+	if _, err := strconv.ParseUint(word, 10, 32); err == nil {
+		// ttl is ignored on delete
+		word, tail = cutWord(tail)
+	}
+
+	if _, ok := dns.StringToClass[strings.ToUpper(word)]; ok {
+		word, tail = cutWord(tail)
+	}
+
+	if word == "" {
+		// "Delete All RRsets From A Name"
+		return &dns.ANY{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: dns.TypeANY,
+				Class:  dns.ClassANY,
+			},
+		}, nil
+	}
+
+	rrType, err := upstream.ParseType(word)
+	if err != nil {
+		return nil, err
+	}
+
+	if tail == "" {
+		// "Delete An RRset"
+		return &dns.ANY{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: rrType,
+				Class:  dns.ClassANY,
+			},
+		}, nil
+	}
+
+	// "Delete An RR From An RRset"
+	rr, err := dns.NewRR(fmt.Sprintf("%s 0 IN %s %s", name, word, tail))
+	if err != nil {
+		return nil, fmt.Errorf("parse rr: %w", err)
+	}
+
+	rr.Header().Class = dns.ClassNONE
+	return rr, nil
+}
+
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexAny(s, " \t")
+	if idx == -1 {
+		return s, ""
+	}
+
+	return s[:idx], strings.TrimSpace(s[idx:])
+}
@@ -0,0 +1,99 @@
+package nsupdate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/nsupdate"
+)
+
+func TestParseScript(t *testing.T) {
+	script := `
+server 127.0.0.1 5454
+key hmac-sha256:tst. c2VjcmV0
+zone example.com.
+; comment
+update add a.example.com. 60 IN A 1.2.3.4
+add txt.example.com 60 TXT "hello world"
+ttl 300
+update add b.example.com. AAAA 2001:db8::1
+send
+update delete a.example.com. A 1.2.3.4
+del b.example.com. AAAA
+delete c.example.com.
+
+update delete d.example.com. 0 IN TXT
+`
+
+	msgs, err := nsupdate.ParseScript(strings.NewReader(script))
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+
+	require.Equal(t, "example.com.", msgs[0].Zone)
+	require.Len(t, msgs[0].Updates, 3)
+	require.Equal(t, "a.example.com.\t60\tIN\tA\t1.2.3.4", msgs[0].Updates[0].String())
+	require.Equal(t, "txt.example.com.\t60\tIN\tTXT\t\"hello world\"", msgs[0].Updates[1].String())
+	require.Equal(t, uint32(300), msgs[0].Updates[2].Header().Ttl)
+
+	require.Len(t, msgs[1].Updates, 3)
+	del := msgs[1].Updates[0].Header()
+	require.Equal(t, uint16(dns.ClassNONE), del.Class)
+	require.Equal(t, dns.TypeA, del.Rrtype)
+
+	rrset := msgs[1].Updates[1].Header()
+	require.Equal(t, uint16(dns.ClassANY), rrset.Class)
+	require.Equal(t, dns.TypeAAAA, rrset.Rrtype)
+
+	name := msgs[1].Updates[2].Header()
+	require.Equal(t, uint16(dns.ClassANY), name.Class)
+	require.Equal(t, dns.TypeANY, name.Rrtype)
+	require.Equal(t, "c.example.com.", name.Name)
+
+	require.Len(t, msgs[2].Updates, 1)
+	require.Equal(t, "example.com.", msgs[2].Zone)
+	require.Equal(t, dns.TypeTXT, msgs[2].Updates[0].Header().Rrtype)
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	cases := []string{
+		"update add a.example.com. A 1.2.3.4",
+		"prereq nxdomain a.example.com.",
+		"update add a.example.com. 60 A not-an-ip",
+		"update delete a.example.com. NOPE",
+		"lol",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc, func(t *testing.T) {
+			_, err := nsupdate.ParseScript(strings.NewReader(tc))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseOperation(t *testing.T) {
+	ttl := uint32(60)
+	rrs, err := nsupdate.ParseOperation("replace", "a.example.com A 1.2.3.4", &ttl)
+	require.NoError(t, err)
+	require.Len(t, rrs, 2)
+
+	rrset := rrs[0].Header()
+	require.Equal(t, uint16(dns.ClassANY), rrset.Class)
+	require.Equal(t, dns.TypeA, rrset.Rrtype)
+	require.Equal(t, "a.example.com.", rrset.Name)
+	require.Equal(t, "a.example.com.\t60\tIN\tA\t1.2.3.4", rrs[1].String())
+
+	rrs, err = nsupdate.ParseOperation("DELETE", "a.example.com. A", nil)
+	require.NoError(t, err)
+	require.Len(t, rrs, 1)
+	require.Equal(t, uint16(dns.ClassANY), rrs[0].Header().Class)
+
+	_, err = nsupdate.ParseOperation("replace", "a.example.com. A 1.2.3.4", nil)
+	require.Error(t, err)
+
+	_, err = nsupdate.ParseOperation("prereq", "nxdomain a.example.com.", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,191 @@
+// Package secret resolves secrets referenced from the config: inline values with ${ENV} interpolation, files,
+// systemd credentials, environment variables and external commands.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secret values in logs and dumps.
+const Redacted = "[redacted]"
+
+const (
+	// CredentialsDirEnv is set by systemd for services with LoadCredential= or SetCredential=.
+	CredentialsDirEnv = "CREDENTIALS_DIRECTORY"
+	execTimeout       = 30 * time.Second
+)
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Ref is the secret from a single source. In the config it's either a string (the inline value)
+// or a map with exactly one of the value, file, credential, env or exec keys.
+type Ref struct {
+	// Value is the inline value, ${NAME} references are replaced with environment variables
+	Value string `koanf:"value"`
+	// File is read as is, without trailing newlines
+	File string `koanf:"file"`
+	// Credential is the systemd credential name, i.e. the file in $CREDENTIALS_DIRECTORY
+	Credential string `koanf:"credential"`
+	// Env is the environment variable name
+	Env string `koanf:"env"`
+	// Exec is the command printing the secret to stdout, e.g. a vault client
+	Exec []string `koanf:"exec"`
+
+	resolved string
+}
+
+func (r *Ref) UnmarshalText(data []byte) error {
+	*r = Ref{
+		Value: string(data),
+	}
+	return nil
+}
+
+// MarshalText never reveals the secret, so the config may be dumped safely.
+func (r Ref) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r Ref) String() string {
+	if r.IsZero() {
+		return ""
+	}
+
+	return Redacted
+}
+
+// IsZero reports whether the secret is not configured at all.
+func (r Ref) IsZero() bool {
+	return r.Value == "" && r.File == "" && r.Credential == "" && r.Env == "" && len(r.Exec) == 0
+}
+
+// Source names where the secret is read from: value, file, credential, env or exec.
+func (r Ref) Source() string {
+	switch {
+	case r.File != "":
+		return "file"
+	case r.Credential != "":
+		return "credential"
+	case r.Env != "":
+		return "env"
+	case len(r.Exec) > 0:
+		return "exec"
+	case r.Value != "":
+		return "value"
+	default:
+		return ""
+	}
+}
+
+// Reveal returns the value read by Resolve.
+func (r Ref) Reveal() string {
+	return r.resolved
+}
+
+// Resolve reads the secret from its source.
+func (r *Ref) Resolve(ctx context.Context) error {
+	value, err := r.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.resolved = value
+	return nil
+}
+
+func (r *Ref) read(ctx context.Context) (string, error) {
+	sources := 0
+	for _, set := range []bool{r.Value != "", r.File != "", r.Credential != "", r.Env != "", len(r.Exec) > 0} {
+		if set {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return "", errors.New("only one of value, file, credential, env or exec may be set")
+	}
+
+	switch {
+	case r.File != "":
+		return readFile(r.File)
+	case r.Credential != "":
+		return readCredential(r.Credential)
+	case r.Env != "":
+		value, ok := os.LookupEnv(r.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", r.Env)
+		}
+		return value, nil
+	case len(r.Exec) > 0:
+		return runExec(ctx, r.Exec)
+	default:
+		return expandEnv(r.Value)
+	}
+}
+
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func readCredential(name string) (string, error) {
+	dir := os.Getenv(CredentialsDirEnv)
+	if dir == "" {
+		return "", fmt.Errorf("credential %q requested, but $%s is not set", name, CredentialsDirEnv)
+	}
+
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid credential name %q", name)
+	}
+
+	return readFile(filepath.Join(dir, name))
+}
+
+func runExec(ctx context.Context, command []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// stdout may hold a part of the secret, so only stderr goes into the error
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("run %q: %w: %s", command[0], err, msg)
+		}
+
+		return "", fmt.Errorf("run %q: %w", command[0], err)
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// expandEnv replaces ${NAME} with the environment variables, bare $NAME are kept as is,
+// since they are common in generated passwords.
+func expandEnv(value string) (string, error) {
+	var errs []error
+	out := envRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRef.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("environment variable %q is not set", name))
+		}
+		return v
+	})
+
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package secret_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/secret"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte("from-file\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cred"), []byte("from-credential"), 0o600))
+	t.Setenv(secret.CredentialsDirEnv, dir)
+	t.Setenv("TST_SECRET", "from-env")
+
+	cases := []struct {
+		name     string
+		ref      secret.Ref
+		expected string
+		err      bool
+	}{
+		{
+			name:     "inline",
+			ref:      secret.Ref{Value: "pa$$word"},
+			expected: "pa$$word",
+		},
+		{
+			name:     "interpolated",
+			ref:      secret.Ref{Value: "prefix-${TST_SECRET}"},
+			expected: "prefix-from-env",
+		},
+		{
+			name: "interpolated unset",
+			ref:  secret.Ref{Value: "${TST_UNSET}"},
+			err:  true,
+		},
+		{
+			name:     "file",
+			ref:      secret.Ref{File: filepath.Join(dir, "file")},
+			expected: "from-file",
+		},
+		{
+			name: "missing file",
+			ref:  secret.Ref{File: filepath.Join(dir, "missing")},
+			err:  true,
+		},
+		{
+			name:     "credential",
+			ref:      secret.Ref{Credential: "cred"},
+			expected: "from-credential",
+		},
+		{
+			name: "credential outside of dir",
+			ref:  secret.Ref{Credential: "../cred"},
+			err:  true,
+		},
+		{
+			name:     "env",
+			ref:      secret.Ref{Env: "TST_SECRET"},
+			expected: "from-env",
+		},
+		{
+			name: "env unset",
+			ref:  secret.Ref{Env: "TST_UNSET"},
+			err:  true,
+		},
+		{
+			name:     "exec",
+			ref:      secret.Ref{Exec: []string{"sh", "-c", "echo from-exec"}},
+			expected: "from-exec",
+		},
+		{
+			name: "exec failed",
+			ref:  secret.Ref{Exec: []string{"sh", "-c", "echo leaked; exit 1"}},
+			err:  true,
+		},
+		{
+			name: "several sources",
+			ref:  secret.Ref{Value: "inline", Env: "TST_SECRET"},
+			err:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.ref.Resolve(context.Background())
+			if tc.err {
+				require.Error(t, err)
+				require.NotContains(t, err.Error(), "leaked")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, tc.ref.Reveal())
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	var ref secret.Ref
+	require.Empty(t, ref.String())
+
+	require.NoError(t, ref.UnmarshalText([]byte("s3cr3t")))
+	require.NoError(t, ref.Resolve(context.Background()))
+	require.Equal(t, "s3cr3t", ref.Reveal())
+
+	require.Equal(t, secret.Redacted, ref.String())
+	require.Equal(t, secret.Redacted, fmt.Sprintf("%v", ref))
+	require.Equal(t, secret.Redacted, fmt.Sprintf("%s", &ref))
+
+	text, err := ref.MarshalText()
+	require.NoError(t, err)
+	require.Equal(t, secret.Redacted, string(text))
+}
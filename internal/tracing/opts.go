@@ -0,0 +1,41 @@
+package tracing
+
+type providerConfig struct {
+	exporter    Exporter
+	endpoint    string
+	serviceName string
+	sampleRatio float64
+}
+
+type Option func(*providerConfig)
+
+func WithExporter(exporter Exporter) Option {
+	return func(cfg *providerConfig) {
+		cfg.exporter = exporter
+	}
+}
+
+// WithEndpoint sets the OTLP/HTTP collector URL, e.g. "http://otel-collector:4318".
+// The OTEL_EXPORTER_OTLP_* environment variables are used if the endpoint is empty.
+func WithEndpoint(endpoint string) Option {
+	return func(cfg *providerConfig) {
+		cfg.endpoint = endpoint
+	}
+}
+
+func WithServiceName(name string) Option {
+	return func(cfg *providerConfig) {
+		if name != "" {
+			cfg.serviceName = name
+		}
+	}
+}
+
+// WithSampleRatio sets the fraction of sampled root spans, all of them are sampled by default.
+func WithSampleRatio(ratio float64) Option {
+	return func(cfg *providerConfig) {
+		if ratio > 0 {
+			cfg.sampleRatio = ratio
+		}
+	}
+}
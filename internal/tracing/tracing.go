@@ -0,0 +1,137 @@
+// Package tracing configures OpenTelemetry tracing, spans are dropped until the Provider is created.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName         = "github.com/buglloc/DNSGateway"
+	DefaultServiceName = "dns-gateway"
+)
+
+type Exporter string
+
+const (
+	ExporterNone   Exporter = ""
+	ExporterOTLP   Exporter = "otlp"
+	ExporterStdout Exporter = "stdout"
+)
+
+func ParseExporter(in string) (Exporter, error) {
+	switch strings.ToLower(in) {
+	case "", "none":
+		return ExporterNone, nil
+	case "otlp":
+		return ExporterOTLP, nil
+	case "stdout":
+		return ExporterStdout, nil
+	default:
+		return ExporterNone, fmt.Errorf("unsupported tracing exporter: %s", in)
+	}
+}
+
+// Tracer returns the DNSGateway tracer of the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts the child span of the span kept in the context.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// NewProvider creates the tracer provider and installs it as the global one.
+func NewProvider(ctx context.Context, opts ...Option) (*Provider, error) {
+	cfg := providerConfig{
+		serviceName: DefaultServiceName,
+		sampleRatio: 1,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.exporter {
+	case ExporterOTLP:
+		var otlpOpts []otlptracehttp.Option
+		if cfg.endpoint != "" {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithEndpointURL(cfg.endpoint))
+		}
+
+		exporter, err = otlptracehttp.New(ctx, otlpOpts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(
+			stdouttrace.WithWriter(os.Stdout),
+			stdouttrace.WithPrettyPrint(),
+		)
+	default:
+		return nil, errors.New("no tracing exporter configured")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", cfg.exporter, err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.serviceName),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.sampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return &Provider{
+		tp: tp,
+	}, nil
+}
+
+// Shutdown flushes pending spans.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.tp.Shutdown(ctx)
+}
@@ -0,0 +1,117 @@
+// Package tsigkey generates TSIG keys and renders configuration snippets of DNS clients using them.
+package tsigkey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// MinSize is the minimal size of the generated secret, so it's never shorter than 32 base64 chars.
+const MinSize = 32
+
+// DefaultAlgorithm is used unless the other one is requested.
+const DefaultAlgorithm = dns.HmacSHA256
+
+// algorithms are the supported TSIG algorithms, the only place to add a new one to both generated keys and the listener.
+var algorithms = map[string]func() hash.Hash{
+	dns.HmacSHA1:   sha1.New,
+	dns.HmacSHA224: sha256.New224,
+	dns.HmacSHA256: sha256.New,
+	dns.HmacSHA384: sha512.New384,
+	dns.HmacSHA512: sha512.New,
+}
+
+type Key struct {
+	Name      string
+	Algorithm string
+	Secret    string
+}
+
+// ParseAlgorithm accepts the algorithm name with or without the "hmac-" prefix and the trailing dot, e.g. "sha256".
+func ParseAlgorithm(s string) (string, error) {
+	if s == "" {
+		return DefaultAlgorithm, nil
+	}
+
+	name := strings.ToLower(dns.Fqdn(s))
+	if !strings.HasPrefix(name, "hmac-") {
+		name = "hmac-" + name
+	}
+
+	if _, ok := algorithms[name]; !ok {
+		return "", fmt.Errorf("unsupported TSIG algorithm: %s", s)
+	}
+
+	return name, nil
+}
+
+// Algorithms returns supported algorithms without the trailing dot, e.g. for the flag help.
+func Algorithms() []string {
+	return []string{"hmac-sha1", "hmac-sha224", "hmac-sha256", "hmac-sha384", "hmac-sha512"}
+}
+
+// NewKey generates the key with the random secret of the algorithm digest size.
+func NewKey(name, algorithm string) (Key, error) {
+	algorithm, err := ParseAlgorithm(algorithm)
+	if err != nil {
+		return Key{}, err
+	}
+
+	secret, err := NewSecret(algorithm)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Name:      dns.Fqdn(name),
+		Algorithm: algorithm,
+		Secret:    secret,
+	}, nil
+}
+
+// NewSecret generates the random base64 encoded secret for the algorithm.
+func NewSecret(algorithm string) (string, error) {
+	algorithm, err := ParseAlgorithm(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, max(algorithms[algorithm]().Size(), MinSize))
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate secret: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// AlgorithmName returns the algorithm without the trailing dot, as most of clients expect it.
+func (k Key) AlgorithmName() string {
+	return strings.TrimSuffix(k.Algorithm, ".")
+}
+
+// MAC calculates the TSIG MAC of the message prepared by dns.TsigProvider callers, e.g. for the custom provider
+// looking secrets up by itself.
+func MAC(algorithm, secret string, msg []byte) ([]byte, error) {
+	newHash, ok := algorithms[dns.CanonicalName(algorithm)]
+	if !ok {
+		return nil, dns.ErrKeyAlg
+	}
+
+	rawSecret, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(newHash, rawSecret)
+	_, _ = mac.Write(msg)
+	return mac.Sum(nil), nil
+}
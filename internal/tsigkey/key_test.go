@@ -0,0 +1,124 @@
+package tsigkey_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/tsigkey"
+)
+
+func TestParseAlgorithm(t *testing.T) {
+	for in, expected := range map[string]string{
+		"":             dns.HmacSHA256,
+		"sha512":       dns.HmacSHA512,
+		"HMAC-SHA1":    dns.HmacSHA1,
+		"hmac-sha384.": dns.HmacSHA384,
+	} {
+		actual, err := tsigkey.ParseAlgorithm(in)
+		require.NoError(t, err, in)
+		require.Equal(t, expected, actual, in)
+	}
+
+	_, err := tsigkey.ParseAlgorithm("hmac-md5")
+	require.Error(t, err)
+}
+
+func TestNewKey(t *testing.T) {
+	for alg, size := range map[string]int{
+		"sha1":   tsigkey.MinSize,
+		"sha256": 32,
+		"sha512": 64,
+	} {
+		key, err := tsigkey.NewKey("tst", alg)
+		require.NoError(t, err)
+		require.Equal(t, "tst.", key.Name)
+
+		raw, err := base64.StdEncoding.DecodeString(key.Secret)
+		require.NoError(t, err)
+		require.Len(t, raw, size, alg)
+	}
+
+	// the key is usable for TSIG as is
+	key, err := tsigkey.NewKey("tst.", "")
+	require.NoError(t, err)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeSOA)
+	m.SetTsig(key.Name, key.Algorithm, 300, 0)
+	buf, _, err := dns.TsigGenerate(m, key.Secret, "", false)
+	require.NoError(t, err)
+	require.NoError(t, dns.TsigVerify(buf, key.Secret, "", false))
+}
+
+func TestSnippet(t *testing.T) {
+	key := tsigkey.Key{
+		Name:      "tst.",
+		Algorithm: dns.HmacSHA256,
+		Secret:    "c2VjcmV0",
+	}
+
+	target, err := tsigkey.NewTarget(":53", []string{"example.com."})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:53", target.Addr())
+
+	certbot, err := tsigkey.Snippet(tsigkey.SnippetCertbot, key, target)
+	require.NoError(t, err)
+	require.Contains(t, certbot, "dns_rfc2136_algorithm = HMAC-SHA256\n")
+
+	lego, err := tsigkey.Snippet(tsigkey.SnippetLego, key, target)
+	require.NoError(t, err)
+	require.Contains(t, lego, "RFC2136_TSIG_ALGORITHM=hmac-sha256.\n")
+
+	nsupdate, err := tsigkey.Snippet(tsigkey.SnippetNSUpdate, key, target)
+	require.NoError(t, err)
+	require.Contains(t, nsupdate, "key \"tst.\" {\n\talgorithm hmac-sha256;\n\tsecret \"c2VjcmV0\";\n};\n")
+
+	externalDNS, err := tsigkey.Snippet(tsigkey.SnippetExternalDNS, key, target)
+	require.NoError(t, err)
+	require.Contains(t, externalDNS, "--rfc2136-zone=example.com.\n")
+
+	_, err = tsigkey.Snippet("bind", key, target)
+	require.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := tsigkey.ParseKey("hmac-sha512:tst:c2VjcmV0")
+	require.NoError(t, err)
+	require.Equal(t, tsigkey.Key{Name: "tst.", Algorithm: dns.HmacSHA512, Secret: "c2VjcmV0"}, key)
+
+	key, err = tsigkey.ParseKey("tst.:c2VjcmV0")
+	require.NoError(t, err)
+	require.Equal(t, dns.HmacSHA256, key.Algorithm)
+
+	for _, in := range []string{"tst.", "a:b:c:d", ":c2VjcmV0", "hmac-md5:tst.:c2VjcmV0"} {
+		_, err := tsigkey.ParseKey(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestParseBindKey(t *testing.T) {
+	key, err := tsigkey.ParseBindKey(`
+# generated by tsig-keygen
+key "tst." {
+	algorithm hmac-sha384;
+	secret "c2VjcmV0";
+};
+`)
+	require.NoError(t, err)
+	require.Equal(t, tsigkey.Key{Name: "tst.", Algorithm: dns.HmacSHA384, Secret: "c2VjcmV0"}, key)
+
+	// round trip with the nsupdate snippet
+	target, err := tsigkey.NewTarget("127.0.0.1:53", []string{"example.com."})
+	require.NoError(t, err)
+	snippet, err := tsigkey.Snippet(tsigkey.SnippetNSUpdate, key, target)
+	require.NoError(t, err)
+	parsed, err := tsigkey.ParseBindKey(snippet)
+	require.NoError(t, err)
+	require.Equal(t, key, parsed)
+
+	_, err = tsigkey.ParseBindKey(`key "tst." { algorithm hmac-sha256; };`)
+	require.Error(t, err)
+}
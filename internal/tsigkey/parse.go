@@ -0,0 +1,80 @@
+package tsigkey
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	bindKeyName      = regexp.MustCompile(`key\s+"?([^"\s{]+)"?\s*\{`)
+	bindKeyAlgorithm = regexp.MustCompile(`algorithm\s+"?([^";\s]+)"?\s*;`)
+	bindKeySecret    = regexp.MustCompile(`secret\s+"([^"]+)"\s*;`)
+)
+
+// ParseKey parses the key in the nsupdate -y format: [algorithm:]name:secret.
+func ParseKey(s string) (Key, error) {
+	parts := strings.Split(s, ":")
+	var algorithm, name, secret string
+	switch len(parts) {
+	case 2:
+		name, secret = parts[0], parts[1]
+	case 3:
+		algorithm, name, secret = parts[0], parts[1], parts[2]
+	default:
+		return Key{}, errors.New("invalid key: [algorithm:]name:secret is expected")
+	}
+
+	return newKey(name, algorithm, secret)
+}
+
+// ReadBindKeyFile reads the key file in the BIND format, as tsig-keygen and nsupdate snippets print it.
+func ReadBindKeyFile(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, fmt.Errorf("read key file: %w", err)
+	}
+
+	return ParseBindKey(string(data))
+}
+
+// ParseBindKey parses the first key statement, the algorithm defaults to hmac-sha256 like nsupdate does.
+func ParseBindKey(data string) (Key, error) {
+	name := bindKeyName.FindStringSubmatch(data)
+	if name == nil {
+		return Key{}, errors.New("invalid key file: no key statement")
+	}
+
+	secret := bindKeySecret.FindStringSubmatch(data)
+	if secret == nil {
+		return Key{}, errors.New("invalid key file: no secret")
+	}
+
+	var algorithm string
+	if m := bindKeyAlgorithm.FindStringSubmatch(data); m != nil {
+		algorithm = m[1]
+	}
+
+	return newKey(name[1], algorithm, secret[1])
+}
+
+func newKey(name, algorithm, secret string) (Key, error) {
+	if name == "" || secret == "" {
+		return Key{}, errors.New("invalid key: name and secret are required")
+	}
+
+	algorithm, err := ParseAlgorithm(algorithm)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Name:      dns.Fqdn(name),
+		Algorithm: algorithm,
+		Secret:    secret,
+	}, nil
+}
@@ -0,0 +1,110 @@
+package tsigkey
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	SnippetCertbot     = "certbot"
+	SnippetLego        = "lego"
+	SnippetNSUpdate    = "nsupdate"
+	SnippetExternalDNS = "externaldns"
+)
+
+// Snippets lists supported snippets in the order they are printed.
+var Snippets = []string{SnippetCertbot, SnippetLego, SnippetNSUpdate, SnippetExternalDNS}
+
+// Target is what the client needs to know besides the key: the DNSGateway address and zones.
+type Target struct {
+	Host  string
+	Port  string
+	Zones []string
+}
+
+// NewTarget makes the target from the listener address, the unspecified host is replaced with the loopback one.
+func NewTarget(addr string, zones []string) (Target, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid server address %q: %w", addr, err)
+	}
+
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	return Target{
+		Host:  host,
+		Port:  port,
+		Zones: zones,
+	}, nil
+}
+
+func (t Target) Addr() string {
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+// Snippet renders the named client configuration, see Snippets.
+func Snippet(name string, key Key, target Target) (string, error) {
+	switch name {
+	case SnippetCertbot:
+		return certbotSnippet(key, target), nil
+	case SnippetLego:
+		return legoSnippet(key, target), nil
+	case SnippetNSUpdate:
+		return nsupdateSnippet(key), nil
+	case SnippetExternalDNS:
+		return externalDNSSnippet(key, target), nil
+	default:
+		return "", fmt.Errorf("unknown snippet %q, expected one of: %s", name, strings.Join(Snippets, ", "))
+	}
+}
+
+// certbotSnippet is the credentials file of the certbot-dns-rfc2136 plugin.
+func certbotSnippet(key Key, target Target) string {
+	return fmt.Sprintf(`# rfc2136.ini, use with: certbot certonly --dns-rfc2136 --dns-rfc2136-credentials rfc2136.ini
+dns_rfc2136_server = %s
+dns_rfc2136_port = %s
+dns_rfc2136_name = %s
+dns_rfc2136_secret = %s
+dns_rfc2136_algorithm = %s
+`, target.Host, target.Port, key.Name, key.Secret, strings.ToUpper(key.AlgorithmName()))
+}
+
+// legoSnippet is the environment of the lego rfc2136 provider.
+func legoSnippet(key Key, target Target) string {
+	return fmt.Sprintf(`# use with: lego --dns rfc2136 ...
+RFC2136_NAMESERVER=%s
+RFC2136_TSIG_KEY=%s
+RFC2136_TSIG_SECRET=%s
+RFC2136_TSIG_ALGORITHM=%s
+`, target.Addr(), key.Name, key.Secret, key.Algorithm)
+}
+
+// nsupdateSnippet is the key file in the BIND format, it's the tsig-keygen output as well.
+func nsupdateSnippet(key Key) string {
+	return fmt.Sprintf(`# %s.key, use with: nsupdate -k %s.key
+key "%s" {
+	algorithm %s;
+	secret "%s";
+};
+`, strings.TrimSuffix(key.Name, "."), strings.TrimSuffix(key.Name, "."), key.Name, key.AlgorithmName(), key.Secret)
+}
+
+// externalDNSSnippet is the arguments of the ExternalDNS rfc2136 provider, which requires zone transfers.
+func externalDNSSnippet(key Key, target Target) string {
+	var sb strings.Builder
+	sb.WriteString("# ExternalDNS args, the client must have xfr_allowed: true\n")
+	sb.WriteString("--provider=rfc2136\n")
+	_, _ = fmt.Fprintf(&sb, "--rfc2136-host=%s\n", target.Host)
+	_, _ = fmt.Fprintf(&sb, "--rfc2136-port=%s\n", target.Port)
+	for _, zone := range target.Zones {
+		_, _ = fmt.Fprintf(&sb, "--rfc2136-zone=%s\n", zone)
+	}
+	_, _ = fmt.Fprintf(&sb, "--rfc2136-tsig-keyname=%s\n", key.Name)
+	_, _ = fmt.Fprintf(&sb, "--rfc2136-tsig-secret=%s\n", key.Secret)
+	_, _ = fmt.Fprintf(&sb, "--rfc2136-tsig-secret-alg=%s\n", key.AlgorithmName())
+	sb.WriteString("--rfc2136-tsig-axfr\n")
+	return sb.String()
+}
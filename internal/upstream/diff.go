@@ -0,0 +1,55 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrDiffUnsupported = errors.New("upstream can't describe pending changes")
+
+// Differ is implemented by transactions which can describe pending changes without committing them,
+// in the upstream own terms: e.g. user rule lines for AdGuard Home or API calls for Cloudflare.
+type Differ interface {
+	Diff(ctx context.Context) ([]string, error)
+}
+
+// TxDiff returns pending changes of the transaction, one change per line.
+func TxDiff(ctx context.Context, tx Tx) ([]string, error) {
+	d, ok := tx.(Differ)
+	if !ok {
+		return nil, ErrDiffUnsupported
+	}
+
+	return d.Diff(ctx)
+}
+
+// LinesDiff returns removed ("- line") and added ("+ line") lines, lines are compared as multisets.
+func LinesDiff(before, after []string) []string {
+	afterCnt := make(map[string]int, len(after))
+	for _, l := range after {
+		afterCnt[l]++
+	}
+
+	beforeCnt := make(map[string]int, len(before))
+	var out []string
+	for _, l := range before {
+		beforeCnt[l]++
+		if afterCnt[l] > 0 {
+			afterCnt[l]--
+			continue
+		}
+
+		out = append(out, "- "+l)
+	}
+
+	for _, l := range after {
+		if beforeCnt[l] > 0 {
+			beforeCnt[l]--
+			continue
+		}
+
+		out = append(out, "+ "+l)
+	}
+
+	return out
+}
@@ -0,0 +1,15 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinesDiff(t *testing.T) {
+	before := []string{"a", "b", "b", "c"}
+	after := []string{"b", "c", "d", "d"}
+
+	require.Equal(t, []string{"- a", "- b", "+ d", "+ d"}, LinesDiff(before, after))
+	require.Empty(t, LinesDiff(before, before))
+}
@@ -0,0 +1,142 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var ErrNegativeUnsupported = errors.New("negative rules are not supported by upstream")
+
+// Negative describes a rule that answers without records, e.g. AdGuard Home $dnsrewrite=NXDOMAIN;;
+type Negative uint8
+
+const (
+	NegativeNone Negative = iota
+	NegativeNXDomain
+	NegativeRefused
+	NegativeNoData
+)
+
+// NegativeMarkerPrefix starts the TXT record value clients with the negative permission use to manage negative rules,
+// it's also the way negative rules are stored by storages keeping records as is, e.g.:
+//
+//	internal.example.com. TXT "dnsgateway:nxdomain"
+//	internal.example.com. TXT "dnsgateway:refused"
+//	internal.example.com. TXT "dnsgateway:nodata"
+//	internal.example.com. TXT "dnsgateway:nodata:AAAA"
+const NegativeMarkerPrefix = "dnsgateway:"
+
+func (n Negative) Rcode() int {
+	switch n {
+	case NegativeNXDomain:
+		return dns.RcodeNameError
+	case NegativeRefused:
+		return dns.RcodeRefused
+	default:
+		return dns.RcodeSuccess
+	}
+}
+
+func (n Negative) String() string {
+	switch n {
+	case NegativeNone:
+		return "none"
+	case NegativeNXDomain:
+		return "nxdomain"
+	case NegativeRefused:
+		return "refused"
+	case NegativeNoData:
+		return "nodata"
+	default:
+		return fmt.Sprintf("unknown_%d", n)
+	}
+}
+
+// NewNegativeRule creates a negative rule. NXDOMAIN and REFUSED always cover the whole name,
+// while NODATA may be limited to the single type. TypeNone means any type.
+func NewNegativeRule(name string, typ RType, negative Negative) (Rule, error) {
+	switch negative {
+	case NegativeNXDomain, NegativeRefused:
+		if typ != dns.TypeNone {
+			return Rule{}, fmt.Errorf("%s rule can't be limited to type %s", negative, TypeString(typ))
+		}
+	case NegativeNoData:
+	default:
+		return Rule{}, fmt.Errorf("invalid negative rule kind: %s", negative)
+	}
+
+	return Rule{
+		Name:     name,
+		Type:     typ,
+		Negative: negative,
+	}, nil
+}
+
+// IsNegative reports whether the rule answers without records.
+func (r *Rule) IsNegative() bool {
+	return r.Negative != NegativeNone
+}
+
+func (r *Rule) negativeMarker() string {
+	out := NegativeMarkerPrefix + r.Negative.String()
+	if r.Type != dns.TypeNone {
+		out += ":" + TypeString(r.Type)
+	}
+
+	return out
+}
+
+// IsNegativeMarker reports whether the record is the TXT marker of a negative rule.
+func IsNegativeMarker(rr dns.RR) bool {
+	txt, ok := rr.(*dns.TXT)
+	return ok && len(txt.Txt) == 1 && strings.HasPrefix(txt.Txt[0], NegativeMarkerPrefix)
+}
+
+// NegativeRuleFromRR parses the TXT marker of a negative rule, see NegativeMarkerPrefix.
+func NegativeRuleFromRR(rr dns.RR) (Rule, error) {
+	if !IsNegativeMarker(rr) {
+		return Rule{}, fmt.Errorf("not a negative marker: %s", rr)
+	}
+
+	return ruleFromNegativeMarker(dns.Fqdn(rr.Header().Name), rr.(*dns.TXT).Txt[0])
+}
+
+// RuleFromStoredRR reverses Rule.RR for storages keeping records as is. The marker is decoded only when the storage
+// knows the rule was negative, so plain TXT values starting with NegativeMarkerPrefix are kept as is.
+func RuleFromStoredRR(rr dns.RR, negative bool) (Rule, error) {
+	if negative {
+		return NegativeRuleFromRR(rr)
+	}
+
+	return RuleFromRR(rr)
+}
+
+func ruleFromNegativeMarker(name string, marker string) (Rule, error) {
+	kind, typStr, _ := strings.Cut(strings.TrimPrefix(marker, NegativeMarkerPrefix), ":")
+
+	var negative Negative
+	switch kind {
+	case "nxdomain":
+		negative = NegativeNXDomain
+	case "refused":
+		negative = NegativeRefused
+	case "nodata":
+		negative = NegativeNoData
+	default:
+		return Rule{}, fmt.Errorf("unknown negative marker: %q", marker)
+	}
+
+	typ := dns.TypeNone
+	if typStr != "" {
+		var err error
+		typ, err = ParseType(typStr)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid type in negative marker %q: %w", marker, err)
+		}
+	}
+
+	return NewNegativeRule(name, typ, negative)
+}
@@ -0,0 +1,84 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var ErrOpaqueUnsupported = errors.New("record type is unknown and opaque rdata is not supported by upstream")
+
+// IsOpaqueType reports whether the rule of the given type keeps wire rdata as is (RFC 3597),
+// because the gateway doesn't know the type.
+func IsOpaqueType(typ RType) bool {
+	if isKnownType(typ) || isRDataType(typ) {
+		return false
+	}
+
+	switch typ {
+	case dns.TypeNone, dns.TypeSOA, dns.TypeOPT, dns.TypeTKEY, dns.TypeTSIG,
+		dns.TypeIXFR, dns.TypeAXFR, dns.TypeMAILB, dns.TypeMAILA, dns.TypeANY:
+		// meta types and the zone apex SOA, which is served by the listener
+		return false
+	default:
+		return true
+	}
+}
+
+// IsOpaque reports whether the rule keeps wire rdata as is (RFC 3597).
+func (r *Rule) IsOpaque() bool {
+	return !r.IsNegative() && IsOpaqueType(r.Type)
+}
+
+// ParseType parses record type name, including RFC 3597 generic "TYPEnnn" notation.
+func ParseType(s string) (RType, error) {
+	s = strings.ToUpper(s)
+	if typ, ok := dns.StringToType[s]; ok {
+		return typ, nil
+	}
+
+	if num, ok := strings.CutPrefix(s, "TYPE"); ok {
+		typ, err := strconv.ParseUint(num, 10, 16)
+		if err == nil {
+			return RType(typ), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown record type: %s", s)
+}
+
+func newRuleOpaque(name string, rrType uint16, valStr string) (Rule, error) {
+	owner := name
+	if owner == "" {
+		owner = "."
+	}
+
+	// both generic "\# len hex" and type specific (if miekg/dns knows the type) presentations are accepted
+	rr, err := dns.NewRR(fmt.Sprintf("%s 0 IN %s %s", owner, dns.Type(rrType), valStr))
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid %s rdata %q: %w", TypeString(rrType), valStr, err)
+	}
+
+	if rr == nil || rr.Header().Rrtype != rrType {
+		return Rule{}, fmt.Errorf("invalid %s rdata %q", TypeString(rrType), valStr)
+	}
+
+	return ruleFromOpaqueRR(name, rr)
+}
+
+func ruleFromOpaqueRR(name string, rr dns.RR) (Rule, error) {
+	generic := new(dns.RFC3597)
+	if err := generic.ToRFC3597(rr); err != nil {
+		return Rule{}, fmt.Errorf("convert %s to generic rdata: %w", TypeString(rr.Header().Rrtype), err)
+	}
+
+	return Rule{
+		Name:     name,
+		Type:     rr.Header().Rrtype,
+		Value:    generic,
+		ValueStr: RDataString(generic),
+	}, nil
+}
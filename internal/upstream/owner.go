@@ -0,0 +1,35 @@
+package upstream
+
+import (
+	"strings"
+)
+
+// OwnerMarkerPrefix starts the comment upstreams use to keep the record owner, e.g.:
+//
+//	dnsgateway:owner=acme-client.
+const OwnerMarkerPrefix = NegativeMarkerPrefix + "owner="
+
+// OwnerComment returns the comment marking the record as owned by the given client.
+func OwnerComment(owner string) string {
+	if owner == "" {
+		return ""
+	}
+
+	return OwnerMarkerPrefix + owner
+}
+
+// OwnerFromComment extracts the record owner from the comment, foreign comments mean no owner.
+func OwnerFromComment(comment string) string {
+	owner, ok := strings.CutPrefix(strings.TrimSpace(comment), OwnerMarkerPrefix)
+	if !ok {
+		return ""
+	}
+
+	return owner
+}
+
+// IsOwnedBy reports whether the record was created by the given client.
+// Records without owner are created outside of DNSGateway, e.g. by humans, and are owned by nobody.
+func (r *Rule) IsOwnedBy(owner string) bool {
+	return r.Owner != "" && r.Owner == owner
+}
@@ -18,6 +18,10 @@ type Rule struct {
 	Type     RType
 	Value    RValue
 	ValueStr string
+	Scope    Scope
+	Negative Negative
+	// Owner is the client created the record, it doesn't take part in matching
+	Owner string
 }
 
 func NewRule(name string, typ RType, content string) (Rule, error) {
@@ -56,8 +60,20 @@ func RuleFromRR(rr dns.RR) (Rule, error) {
 		value = v
 		valueStr = fmt.Sprintf("%d %d %d %s", v.Priority, v.Weight, v.Port, v.Target)
 
+	case *dns.NS:
+		value = v.Ns
+		valueStr = v.Ns
+
+	case *dns.CAA, *dns.SSHFP, *dns.TLSA, *dns.HTTPS, *dns.SVCB, *dns.NAPTR, *dns.DS:
+		value = v
+		valueStr = RDataString(v)
+
 	default:
-		return Rule{}, fmt.Errorf("unsupported rr: %s", rr)
+		if !IsOpaqueType(rr.Header().Rrtype) {
+			return Rule{}, fmt.Errorf("unsupported rr: %s", rr)
+		}
+
+		return ruleFromOpaqueRR(dns.Fqdn(rr.Header().Name), rr)
 	}
 
 	return Rule{
@@ -75,6 +91,14 @@ func (r *Rule) RR() (dns.RR, error) {
 		Class:  dns.ClassINET,
 	}
 
+	if r.IsNegative() {
+		hdr.Rrtype = dns.TypeTXT
+		return &dns.TXT{
+			Hdr: hdr,
+			Txt: []string{r.negativeMarker()},
+		}, nil
+	}
+
 	switch r.Type {
 	case dns.TypeA:
 		return &dns.A{
@@ -124,11 +148,37 @@ func (r *Rule) RR() (dns.RR, error) {
 			Target:   srv.Target,
 		}, nil
 
+	case dns.TypeNS:
+		return &dns.NS{
+			Hdr: hdr,
+			Ns:  r.Value.(string),
+		}, nil
+
 	default:
-		return nil, fmt.Errorf("unsupported rrType %d: %s", r.Type, TypeString(r.Type))
+		if !isRDataType(r.Type) && !IsOpaqueType(r.Type) {
+			return nil, fmt.Errorf("unsupported rrType %d: %s", r.Type, TypeString(r.Type))
+		}
+
+		rr := dns.Copy(r.Value.(dns.RR))
+		*rr.Header() = hdr
+		return rr, nil
 	}
 }
 
+// String formats the rule as a zone file line followed by the non-global scope.
+func (r *Rule) String() string {
+	out := fmt.Sprintf("%s %s %s", r.Name, TypeString(r.Type), r.ValueStr)
+	if rr, err := r.RR(); err == nil {
+		out = rr.String()
+	}
+
+	if !r.Scope.IsZero() {
+		out += " ; " + r.Scope.String()
+	}
+
+	return out
+}
+
 func (r *Rule) Same(other *Rule) bool {
 	if other.Type == dns.TypeAXFR {
 		return r.matchAxfrRule(other)
@@ -138,6 +188,10 @@ func (r *Rule) Same(other *Rule) bool {
 }
 
 func (r *Rule) matchAxfrRule(other *Rule) bool {
+	if !r.matchScope(other) {
+		return false
+	}
+
 	if other.Name == "" {
 		return true
 	}
@@ -154,7 +208,9 @@ func (r *Rule) matchAxfrRule(other *Rule) bool {
 }
 
 func (r *Rule) matchPlainRule(other *Rule) bool {
-	if other.Type != dns.TypeNone && other.Type != r.Type {
+	// negative rule without type covers the whole name
+	anyType := r.IsNegative() && r.Type == dns.TypeNone
+	if other.Type != dns.TypeNone && other.Type != r.Type && !anyType {
 		return false
 	}
 
@@ -162,6 +218,10 @@ func (r *Rule) matchPlainRule(other *Rule) bool {
 		return false
 	}
 
+	if !r.matchScope(other) {
+		return false
+	}
+
 	if !r.matchValue(other) {
 		return false
 	}
@@ -169,7 +229,20 @@ func (r *Rule) matchPlainRule(other *Rule) bool {
 	return true
 }
 
+// matchScope requires the same scope, unless the other rule asks for any.
+func (r *Rule) matchScope(other *Rule) bool {
+	return other.Scope.IsAny() || r.Scope.Equal(other.Scope)
+}
+
 func (r *Rule) matchValue(other *Rule) bool {
+	if other.IsNegative() {
+		return r.Negative == other.Negative && r.Type == other.Type
+	}
+
+	if r.IsNegative() {
+		return other.Value == nil && other.ValueStr == ""
+	}
+
 	if r.Type == dns.TypeTXT {
 		return matchTXTValue(r, other)
 	}
@@ -193,11 +266,16 @@ func txtValueStr(parts []string) string {
 	return strings.Join(parts, "")
 }
 
-func TypeString(typ RType) string {
-	out, ok := dns.TypeToString[typ]
-	if !ok {
-		return fmt.Sprintf("unknown_%d", typ)
+// RDataString returns the RR data in presentation format, e.g. `0 issue "letsencrypt.org"` for CAA.
+func RDataString(rr dns.RR) string {
+	if generic, ok := rr.(*dns.RFC3597); ok {
+		// RFC3597.String() uses generic class notation too, so the header can't be just trimmed
+		return fmt.Sprintf("\\# %d %s", len(generic.Rdata)/2, generic.Rdata)
 	}
 
-	return out
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func TypeString(typ RType) string {
+	return dns.Type(typ).String()
 }
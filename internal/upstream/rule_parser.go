@@ -36,8 +36,18 @@ func newRule(name string, rrType uint16, valStr string) (Rule, error) {
 	case dns.TypeSRV:
 		out, err = newRuleSRV(name, valStr)
 
+	case dns.TypeNS:
+		out, err = newRuleNS(name, valStr)
+
 	default:
-		return Rule{}, fmt.Errorf("unsupported rrType %d: %s", rrType, TypeString(rrType))
+		switch {
+		case isRDataType(rrType):
+			out, err = newRuleRData(name, rrType, valStr)
+		case IsOpaqueType(rrType):
+			out, err = newRuleOpaque(name, rrType, valStr)
+		default:
+			return Rule{}, fmt.Errorf("unsupported rrType %d: %s", rrType, TypeString(rrType))
+		}
 	}
 
 	if err != nil {
@@ -192,6 +202,64 @@ func newRuleSRV(name string, valStr string) (Rule, error) {
 	}, nil
 }
 
+func newRuleNS(name string, valStr string) (Rule, error) {
+	domain := fqdn.FQDN(valStr)
+	if err := fqdn.ValidateHostname(domain); err != nil {
+		return Rule{}, fmt.Errorf("invalid ns host %q: %w", valStr, err)
+	}
+
+	return Rule{
+		Name:     name,
+		Type:     dns.TypeNS,
+		Value:    domain,
+		ValueStr: domain,
+	}, nil
+}
+
+// isKnownType reports whether the rule value has a dedicated representation.
+func isKnownType(rrType uint16) bool {
+	switch rrType {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeMX, dns.TypePTR, dns.TypeTXT, dns.TypeSRV, dns.TypeNS:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRDataType reports whether the rule value is kept as a parsed dns.RR,
+// so we don't have to duplicate miekg/dns parsers for every complex record type.
+func isRDataType(rrType uint16) bool {
+	switch rrType {
+	case dns.TypeCAA, dns.TypeSSHFP, dns.TypeTLSA, dns.TypeHTTPS, dns.TypeSVCB, dns.TypeNAPTR, dns.TypeDS:
+		return true
+	default:
+		return false
+	}
+}
+
+func newRuleRData(name string, rrType uint16, valStr string) (Rule, error) {
+	owner := name
+	if owner == "" {
+		owner = "."
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s 0 IN %s %s", owner, TypeString(rrType), valStr))
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid %s rdata %q: %w", TypeString(rrType), valStr, err)
+	}
+
+	if rr == nil || rr.Header().Rrtype != rrType {
+		return Rule{}, fmt.Errorf("invalid %s rdata %q", TypeString(rrType), valStr)
+	}
+
+	return Rule{
+		Name:     name,
+		Type:     rrType,
+		Value:    rr,
+		ValueStr: RDataString(rr),
+	}, nil
+}
+
 func parseIP(in string) net.IP {
 	for _, c := range in {
 		if c != '.' && c != ':' &&
@@ -50,3 +50,179 @@ func TestRuleSameTXTMatchesChunks(t *testing.T) {
 	require.True(t, stored.Same(&sameChunks))
 	require.False(t, stored.Same(&differentChunks))
 }
+
+func TestRuleSameScope(t *testing.T) {
+	global := Rule{
+		Name:     "a.example.com.",
+		Type:     dns.TypeA,
+		ValueStr: "1.2.3.4",
+	}
+	kids := Rule{
+		Name:     "a.example.com.",
+		Type:     dns.TypeA,
+		ValueStr: "1.2.3.4",
+		Scope:    NewScope(nil, []string{"user_child", "device_pc"}),
+	}
+
+	require.True(t, global.Same(&Rule{Name: "a.example.com.", Type: dns.TypeA}))
+	require.False(t, kids.Same(&Rule{Name: "a.example.com.", Type: dns.TypeA}))
+	require.False(t, global.Same(&kids))
+	require.True(t, kids.Same(&Rule{
+		Name:  "a.example.com.",
+		Type:  dns.TypeA,
+		Scope: NewScope(nil, []string{"device_pc", "user_child"}),
+	}))
+	require.False(t, kids.Same(&Rule{Type: dns.TypeAXFR}))
+
+	// unscoped lookups see rules of every scope
+	anyScope := Rule{Name: "a.example.com.", Type: dns.TypeA, Scope: AnyScope()}
+	require.True(t, kids.Same(&anyScope))
+	require.True(t, global.Same(&anyScope))
+	require.True(t, kids.Same(&Rule{Type: dns.TypeAXFR, Scope: AnyScope()}))
+	require.False(t, kids.Same(&Rule{Name: "a.example.com.", Type: dns.TypeA, ValueStr: "1.2.3.5", Scope: AnyScope()}))
+}
+
+func TestRuleNegative(t *testing.T) {
+	nxdomain, err := NewNegativeRule("internal.example.com.", dns.TypeNone, NegativeNXDomain)
+	require.NoError(t, err)
+	nodata, err := NewNegativeRule("internal.example.com.", dns.TypeAAAA, NegativeNoData)
+	require.NoError(t, err)
+
+	_, err = NewNegativeRule("internal.example.com.", dns.TypeA, NegativeRefused)
+	require.Error(t, err)
+
+	// queries of any type see the whole name negative rule
+	require.True(t, nxdomain.Same(&Rule{Name: "internal.example.com.", Type: dns.TypeA}))
+	require.True(t, nodata.Same(&Rule{Name: "internal.example.com.", Type: dns.TypeAAAA}))
+	require.False(t, nodata.Same(&Rule{Name: "internal.example.com.", Type: dns.TypeA}))
+	require.False(t, nxdomain.Same(&Rule{Name: "internal.example.com.", Type: dns.TypeA, ValueStr: "1.2.3.4"}))
+
+	// negative rules are managed with TXT markers
+	for _, rule := range []Rule{nxdomain, nodata} {
+		rr, err := rule.RR()
+		require.NoError(t, err)
+		require.Equal(t, dns.TypeTXT, rr.Header().Rrtype)
+
+		require.True(t, IsNegativeMarker(rr))
+
+		parsed, err := NegativeRuleFromRR(rr)
+		require.NoError(t, err)
+		require.Equal(t, rule, parsed)
+		require.True(t, rule.Same(&parsed))
+
+		// markers are decoded only on demand, so plain TXT values are never taken over
+		plain, err := RuleFromStoredRR(rr, false)
+		require.NoError(t, err)
+		require.False(t, plain.IsNegative())
+		require.Equal(t, dns.TypeTXT, plain.Type)
+	}
+
+	require.False(t, nxdomain.Same(&nodata))
+
+	marker, err := NewRule("internal.example.com.", dns.TypeTXT, "dnsgateway:nodata:AAAA")
+	require.NoError(t, err)
+	require.False(t, marker.IsNegative())
+	require.Equal(t, "dnsgateway:nodata:AAAA", marker.ValueStr)
+
+	_, err = NegativeRuleFromRR(&dns.TXT{Hdr: dns.RR_Header{Name: "a.example.com.", Rrtype: dns.TypeTXT}, Txt: []string{"hello"}})
+	require.Error(t, err)
+}
+
+func TestRuleExtendedTypes(t *testing.T) {
+	cases := []struct {
+		typ      RType
+		in       string
+		valueStr string
+	}{
+		{dns.TypeNS, "ns1.example.net", "ns1.example.net."},
+		{dns.TypeCAA, `0 issue "letsencrypt.org"`, `0 issue "letsencrypt.org"`},
+		{dns.TypeSSHFP, "4 2 abcdef", "4 2 ABCDEF"},
+		{dns.TypeTLSA, "3 1 1 abcdef", "3 1 1 abcdef"},
+		{dns.TypeHTTPS, "1 . alpn=h3,h2", `1 . alpn="h3,h2"`},
+		{dns.TypeSVCB, "1 dns.example.com alpn=dot", `1 dns.example.com. alpn="dot"`},
+		{dns.TypeNAPTR, `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .`, `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .`},
+		{dns.TypeDS, "60485 5 1 2bb183af5f22588179a53b0a98631fad1a292118", "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118"},
+	}
+
+	for _, tc := range cases {
+		t.Run(TypeString(tc.typ), func(t *testing.T) {
+			rule, err := NewRule("example.com.", tc.typ, tc.in)
+			require.NoError(t, err)
+			require.Equal(t, tc.valueStr, rule.ValueStr)
+
+			rr, err := rule.RR()
+			require.NoError(t, err)
+			require.Equal(t, tc.typ, rr.Header().Rrtype)
+			require.Equal(t, "example.com.", rr.Header().Name)
+
+			parsed, err := RuleFromRR(rr)
+			require.NoError(t, err)
+			require.Equal(t, rule.ValueStr, parsed.ValueStr)
+			require.True(t, rule.Same(&parsed))
+		})
+	}
+
+	_, err := NewRule("example.com.", dns.TypeCAA, "lol")
+	require.Error(t, err)
+}
+
+func TestRuleOpaque(t *testing.T) {
+	generic, err := NewRule("opaque.example.com.", 65280, `\# 4 0A000001`)
+	require.NoError(t, err)
+	require.True(t, generic.IsOpaque())
+	require.Equal(t, `\# 4 0a000001`, generic.ValueStr)
+
+	// types known to miekg/dns, but not to the gateway are kept as generic rdata too
+	uri, err := NewRule("_http._tcp.example.com.", dns.TypeURI, `10 1 "https://example.com/"`)
+	require.NoError(t, err)
+	require.True(t, uri.IsOpaque())
+
+	uriRR, err := dns.NewRR(`_http._tcp.example.com. 60 IN URI 10 1 "https://example.com/"`)
+	require.NoError(t, err)
+	parsed, err := RuleFromRR(uriRR)
+	require.NoError(t, err)
+	require.True(t, uri.Same(&parsed))
+
+	rr, err := generic.RR()
+	require.NoError(t, err)
+	require.Equal(t, "opaque.example.com.\t0\tCLASS1\tTYPE65280\t\\# 4 0a000001", rr.String())
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("opaque.example.com.", 65280)
+	msg.Answer = append(msg.Answer, rr)
+	wire, err := msg.Pack()
+	require.NoError(t, err)
+	require.NoError(t, msg.Unpack(wire))
+
+	parsed, err = RuleFromRR(msg.Answer[0])
+	require.NoError(t, err)
+	require.True(t, generic.Same(&parsed))
+
+	_, err = NewRule("example.com.", dns.TypeSOA, "ns.example.com. admin.example.com. 1 2 3 4 5")
+	require.Error(t, err)
+}
+
+func TestParseType(t *testing.T) {
+	typ, err := ParseType("caa")
+	require.NoError(t, err)
+	require.Equal(t, dns.TypeCAA, typ)
+
+	typ, err = ParseType("TYPE65280")
+	require.NoError(t, err)
+	require.EqualValues(t, 65280, typ)
+	require.Equal(t, "TYPE65280", TypeString(typ))
+
+	_, err = ParseType("lol")
+	require.Error(t, err)
+}
+
+func TestRuleSameAnyScopeAXFR(t *testing.T) {
+	scoped, err := NewRule("a.example.com.", dns.TypeA, "1.2.3.4")
+	require.NoError(t, err)
+	scoped.Scope = NewScope([]string{"192.168.1.0/24"}, nil)
+
+	require.False(t, scoped.Same(&Rule{Type: dns.TypeAXFR}))
+	require.True(t, scoped.Same(&Rule{Type: dns.TypeAXFR, Scope: AnyScope()}))
+	require.True(t, scoped.Same(&Rule{Name: "example.com.", Type: dns.TypeAXFR, Scope: AnyScope()}))
+	require.False(t, scoped.Same(&Rule{Name: "example.org.", Type: dns.TypeAXFR, Scope: AnyScope()}))
+}
@@ -0,0 +1,70 @@
+package upstream
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrScopeUnsupported = errors.New("scoped rules are not supported by upstream")
+
+// Scope limits the rule to a set of DNS clients, e.g. AdGuard Home $client/$ctag modifiers.
+// Zero scope means the rule is global.
+type Scope struct {
+	Clients []string
+	CTags   []string
+	any     bool
+}
+
+// AnyScope matches rules of every scope, e.g. to answer public queries or to snapshot the whole record set,
+// while the zero scope matches global rules only.
+func AnyScope() Scope {
+	return Scope{
+		any: true,
+	}
+}
+
+func (s Scope) IsAny() bool {
+	return s.any
+}
+
+func NewScope(clients, ctags []string) Scope {
+	return Scope{
+		Clients: normalizeScopeValues(clients),
+		CTags:   normalizeScopeValues(ctags),
+	}
+}
+
+func (s Scope) IsZero() bool {
+	return len(s.Clients) == 0 && len(s.CTags) == 0
+}
+
+func (s Scope) Equal(other Scope) bool {
+	return slices.Equal(normalizeScopeValues(s.Clients), normalizeScopeValues(other.Clients)) &&
+		slices.Equal(normalizeScopeValues(s.CTags), normalizeScopeValues(other.CTags))
+}
+
+func (s Scope) String() string {
+	if s.IsZero() {
+		return "global"
+	}
+
+	var parts []string
+	if len(s.Clients) > 0 {
+		parts = append(parts, "client="+strings.Join(s.Clients, "|"))
+	}
+	if len(s.CTags) > 0 {
+		parts = append(parts, "ctag="+strings.Join(s.CTags, "|"))
+	}
+	return strings.Join(parts, ",")
+}
+
+func normalizeScopeValues(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+
+	out := slices.Clone(in)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
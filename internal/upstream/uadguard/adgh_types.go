@@ -7,3 +7,14 @@ type ErrorRsp struct {
 type FilteringStatusRsp struct {
 	Rules []string `json:"user_rules"`
 }
+
+type RewriteEntry struct {
+	Domain  string `json:"domain"`
+	Answer  string `json:"answer"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+type RewriteUpdateReq struct {
+	Target RewriteEntry `json:"target"`
+	Update RewriteEntry `json:"update"`
+}
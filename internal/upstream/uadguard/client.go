@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/buglloc/DNSGateway/internal/metrics"
 	"github.com/buglloc/DNSGateway/internal/upstream"
 	"github.com/buglloc/DNSGateway/internal/upstream/uadguard/rules"
 	"github.com/buglloc/DNSGateway/internal/xhttp"
@@ -26,10 +28,13 @@ const (
 var _ upstream.Upstream = (*Upstream)(nil)
 
 type Upstream struct {
-	httpc   *resty.Client
-	parser  *rules.Parser
-	log     zerolog.Logger
-	autoPTR bool
+	httpc         *resty.Client
+	parser        *rules.Parser
+	log           zerolog.Logger
+	mode          Mode
+	ownershipPath string
+	ownership     *Ownership
+	quarantined   atomic.Int64
 }
 
 func NewUpstream(opts ...Option) (*Upstream, error) {
@@ -47,6 +52,7 @@ func NewUpstreamWithHTTP(httpc *http.Client, opts ...Option) (*Upstream, error)
 			Str("source", "agh-upstream").
 			Logger(),
 		parser: rules.NewParser(rulesMarkerBegin, rulesMarkerEnd),
+		mode:   ModeRules,
 	}
 
 	for _, opt := range opts {
@@ -56,6 +62,18 @@ func NewUpstreamWithHTTP(httpc *http.Client, opts ...Option) (*Upstream, error)
 	if client.httpc.BaseURL == "" {
 		return nil, errors.New("no upstream configured, use WithUpstream()")
 	}
+
+	if client.mode == ModeRewrites {
+		if client.ownershipPath == "" {
+			return nil, errors.New("no ownership file configured for rewrites mode, use WithOwnershipFile()")
+		}
+
+		own, err := NewOwnership(client.ownershipPath)
+		if err != nil {
+			return nil, fmt.Errorf("load rewrites ownership: %w", err)
+		}
+		client.ownership = own
+	}
 	return client, nil
 }
 
@@ -65,23 +83,109 @@ func (c *Upstream) Query(ctx context.Context, r upstream.Rule) ([]upstream.Rule,
 		return nil, err
 	}
 
-	return rh.Query(r), nil
+	out := rh.Query(r)
+	if c.mode != ModeRewrites {
+		return out, nil
+	}
+
+	rw, err := c.fetchRewrites(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, rw.Query(r)...), nil
 }
 
 func (c *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	raw, err := c.fetchRawRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rh, err := c.parseRules(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{
+		upc:       c,
+		httpc:     c.httpc,
+		origin:    raw,
+		rules:     rh,
+		ownership: c.ownership,
+		log:       c.log,
+	}
+
+	if c.mode == ModeRewrites {
+		tx.rewrites, err = c.fetchRewrites(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tx, nil
+}
+
+// QuarantinedRules returns unparseable lines inside the managed block.
+func (c *Upstream) QuarantinedRules(ctx context.Context) ([]rules.Quarantined, error) {
 	rh, err := c.fetchRules(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Tx{
-		httpc:   c.httpc,
-		rules:   rh,
-		autoPTR: c.autoPTR,
-	}, nil
+	return rh.Quarantined(), nil
+}
+
+// DropQuarantinedRules removes unparseable lines from the managed block and returns them.
+func (c *Upstream) DropQuarantinedRules(ctx context.Context) ([]rules.Quarantined, error) {
+	tx, err := c.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	adgTx := tx.(*Tx)
+	dropped := adgTx.rulesDropQuarantined()
+	if err := adgTx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return dropped, nil
 }
 
 func (c *Upstream) fetchRules(ctx context.Context) (*rules.Storage, error) {
+	raw, err := c.fetchRawRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseRules(raw)
+}
+
+func (c *Upstream) parseRules(raw []string) (*rules.Storage, error) {
+	rh, err := c.parser.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined := rh.Quarantined()
+	if prev := c.setQuarantined(len(quarantined)); prev != int64(len(quarantined)) {
+		// report only changes, otherwise every query would spam the same warnings
+		for _, q := range quarantined {
+			c.log.Warn().Err(q.Err).Str("line", q.Line).Msg("quarantined unparseable line inside DNSGateway rules")
+		}
+	}
+
+	return rh, nil
+}
+
+// setQuarantined stores the number of quarantined lines and returns the previous one.
+func (c *Upstream) setQuarantined(n int) int64 {
+	metrics.AdguardQuarantined.Set(float64(n))
+	return c.quarantined.Swap(int64(n))
+}
+
+func (c *Upstream) fetchRawRules(ctx context.Context) ([]string, error) {
 	var rsp FilteringStatusRsp
 	var errRsp ErrorRsp
 	httpRsp, err := c.httpc.R().
@@ -97,5 +201,24 @@ func (c *Upstream) fetchRules(ctx context.Context) (*rules.Storage, error) {
 		return nil, errors.New(errRsp.Message)
 	}
 
-	return c.parser.Parse(rsp.Rules)
+	return rsp.Rules, nil
+}
+
+func (c *Upstream) fetchRewrites(ctx context.Context) (*Rewrites, error) {
+	var rsp []RewriteEntry
+	var errRsp ErrorRsp
+	httpRsp, err := c.httpc.R().
+		SetContext(ctx).
+		SetResult(&rsp).
+		SetError(&errRsp).
+		Get("/control/rewrite/list")
+	if err != nil {
+		return nil, fmt.Errorf("make http request: %w", err)
+	}
+
+	if httpRsp.IsError() {
+		return nil, errors.New(errRsp.Message)
+	}
+
+	return NewRewrites(rsp, c.ownership), nil
 }
@@ -14,6 +14,7 @@ import (
 
 	"github.com/buglloc/DNSGateway/internal/upstream"
 	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+	"github.com/buglloc/DNSGateway/internal/upstream/uautoptr"
 )
 
 func TestSrvMock(t *testing.T) {
@@ -77,12 +78,14 @@ func TestSrvMock(t *testing.T) {
 	srv := httptest.NewServer(&adghMux)
 	defer srv.Close()
 
-	c, err := uadguard.NewUpstream(
+	adg, err := uadguard.NewUpstream(
 		uadguard.WithUpstream(srv.URL),
-		uadguard.WithAutoPTR(true),
 	)
 	require.NoError(t, err)
 
+	c, err := uautoptr.NewUpstream(adg)
+	require.NoError(t, err)
+
 	rr, err := c.Query(context.Background(), upstream.Rule{
 		Name: "lol",
 		Type: dns.TypePTR,
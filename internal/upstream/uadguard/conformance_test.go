@@ -0,0 +1,196 @@
+package uadguard_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+)
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, newTestUpstream)
+}
+
+func TestConformanceTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, dns.TypeHTTPS, dns.TypeSVCB)
+}
+
+func TestConformanceOpaqueTypes(t *testing.T) {
+	upstreamtest.RunRefusedTypes(t, newTestUpstream, upstreamtest.OpaqueTypes...)
+}
+
+func newTestUpstream(t *testing.T) upstream.Upstream {
+	srv := newAdGuardMock(t, "lol", "kek")
+
+	c, err := uadguard.NewUpstream(
+		uadguard.WithUpstream(srv.URL),
+	)
+	require.NoError(t, err)
+	return c
+}
+
+func TestConformanceRewrites(t *testing.T) {
+	upstreamtest.Run(t, func(t *testing.T) upstream.Upstream {
+		srv := newAdGuardMock(t, "lol", "kek")
+
+		c, err := uadguard.NewUpstream(
+			uadguard.WithUpstream(srv.URL),
+			uadguard.WithMode(uadguard.ModeRewrites),
+			uadguard.WithOwnershipFile(filepath.Join(t.TempDir(), "ownership.json")),
+		)
+		require.NoError(t, err)
+		return c
+	})
+}
+
+type adGuardMock struct {
+	*httptest.Server
+	mu       sync.Mutex
+	rules    []string
+	rewrites []uadguard.RewriteEntry
+	// failRules makes set_rules to fail, e.g. to check a partially failed commit
+	failRules bool
+}
+
+func (m *adGuardMock) Rules() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.rules...)
+}
+
+func (m *adGuardMock) Rewrites() []uadguard.RewriteEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]uadguard.RewriteEntry(nil), m.rewrites...)
+}
+
+func newAdGuardMock(t *testing.T, rules ...string) *adGuardMock {
+	var mux http.ServeMux
+	m := &adGuardMock{
+		rules: rules,
+	}
+	mu := &m.mu
+
+	mux.HandleFunc("/control/filtering/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET expected", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Rules []string `json:"user_rules"`
+		}{
+			Rules: m.rules,
+		})
+	})
+
+	mux.HandleFunc("/control/filtering/set_rules", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST expected", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Rules []string `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if m.failRules {
+			http.Error(w, "set_rules failed", http.StatusInternalServerError)
+			return
+		}
+		m.rules = req.Rules
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("GET /control/rewrite/list", func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(m.rewrites)
+	})
+
+	rewriteIdx := func(e uadguard.RewriteEntry) int {
+		for i, rw := range m.rewrites {
+			if rw.Domain == e.Domain && rw.Answer == e.Answer {
+				return i
+			}
+		}
+		return -1
+	}
+
+	mux.HandleFunc("POST /control/rewrite/add", func(w http.ResponseWriter, r *http.Request) {
+		var req uadguard.RewriteEntry
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if rewriteIdx(req) != -1 {
+			http.Error(w, "rewrite already exists", http.StatusBadRequest)
+			return
+		}
+		m.rewrites = append(m.rewrites, req)
+	})
+
+	mux.HandleFunc("POST /control/rewrite/delete", func(w http.ResponseWriter, r *http.Request) {
+		var req uadguard.RewriteEntry
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		idx := rewriteIdx(req)
+		if idx == -1 {
+			http.Error(w, "rewrite not found", http.StatusBadRequest)
+			return
+		}
+		m.rewrites = append(m.rewrites[:idx], m.rewrites[idx+1:]...)
+	})
+
+	mux.HandleFunc("PUT /control/rewrite/update", func(w http.ResponseWriter, r *http.Request) {
+		var req uadguard.RewriteUpdateReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		idx := rewriteIdx(req.Target)
+		if idx == -1 {
+			http.Error(w, "rewrite not found", http.StatusBadRequest)
+			return
+		}
+		m.rewrites[idx] = req.Update
+	})
+
+	m.Server = httptest.NewServer(&mux)
+	t.Cleanup(m.Server.Close)
+	return m
+}
@@ -0,0 +1,26 @@
+package uadguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Mode string
+
+const (
+	// ModeRules keeps every record as $dnsrewrite filtering rule inside the DNSGateway block.
+	ModeRules Mode = "rules"
+	// ModeRewrites keeps A/AAAA/CNAME records in the DNS rewrites list and everything else as filtering rules.
+	ModeRewrites Mode = "rewrites"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "", string(ModeRules):
+		return ModeRules, nil
+	case string(ModeRewrites):
+		return ModeRewrites, nil
+	default:
+		return "", fmt.Errorf("unknown adguard mode: %s", s)
+	}
+}
@@ -1,5 +1,9 @@
 package uadguard
 
+import (
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
 type Option func(*Upstream)
 
 func WithUpstream(upstream string) Option {
@@ -18,14 +22,20 @@ func WithBasicAuth(login, password string) Option {
 	}
 }
 
-func WithAutoPTR(enabled bool) Option {
+func WithMode(mode Mode) Option {
+	return func(client *Upstream) {
+		client.mode = mode
+	}
+}
+
+func WithOwnershipFile(path string) Option {
 	return func(client *Upstream) {
-		client.autoPTR = enabled
+		client.ownershipPath = path
 	}
 }
 
 func WithDebug(verbose bool) Option {
 	return func(client *Upstream) {
-		client.httpc.SetDebug(verbose)
+		xhttp.SetDebug(client.httpc, verbose)
 	}
 }
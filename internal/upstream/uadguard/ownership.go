@@ -0,0 +1,127 @@
+package uadguard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Ownership keeps the list of DNS rewrites created by DNSGateway,
+// because AdGuard Home has nowhere to store this mark within a rewrite itself.
+type Ownership struct {
+	path    string
+	mu      sync.Mutex
+	entries map[RewriteEntry]string
+}
+
+// ownedRewrite is the persisted ownership entry, files written before owners were tracked have no owner.
+type ownedRewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+	Owner  string `json:"owner,omitempty"`
+}
+
+func NewOwnership(path string) (*Ownership, error) {
+	o := &Ownership{
+		path:    path,
+		entries: make(map[RewriteEntry]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return o, nil
+		}
+
+		return nil, fmt.Errorf("read ownership file: %w", err)
+	}
+
+	var entries []ownedRewrite
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse ownership file: %w", err)
+	}
+
+	for _, e := range entries {
+		o.entries[RewriteEntry{Domain: e.Domain, Answer: e.Answer}] = e.Owner
+	}
+
+	return o, nil
+}
+
+func (o *Ownership) IsOwned(e RewriteEntry) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, ok := o.entries[ownershipKey(e)]
+	return ok
+}
+
+// Owner returns the client created the rewrite.
+func (o *Ownership) Owner(e RewriteEntry) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.entries[ownershipKey(e)]
+}
+
+// Update marks added entries as owned by the given clients, forgets deleted ones and persists the result.
+func (o *Ownership) Update(added map[RewriteEntry]string, deleted []RewriteEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range deleted {
+		delete(o.entries, ownershipKey(e))
+	}
+
+	for e, owner := range added {
+		o.entries[ownershipKey(e)] = owner
+	}
+
+	entries := make([]ownedRewrite, 0, len(o.entries))
+	for e, owner := range o.entries {
+		entries = append(entries, ownedRewrite{
+			Domain: e.Domain,
+			Answer: e.Answer,
+			Owner:  owner,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Domain != entries[j].Domain {
+			return entries[i].Domain < entries[j].Domain
+		}
+		return entries[i].Answer < entries[j].Answer
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal ownership: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), ".ownership-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write ownership: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close ownership: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), o.path)
+}
+
+func ownershipKey(e RewriteEntry) RewriteEntry {
+	return RewriteEntry{
+		Domain: e.Domain,
+		Answer: e.Answer,
+	}
+}
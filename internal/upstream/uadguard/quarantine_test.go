@@ -0,0 +1,72 @@
+package uadguard_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+)
+
+func TestQuarantine(t *testing.T) {
+	srv := newAdGuardMock(t,
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"@@||ya.ru^$important",
+		"|ya.ru^$dnsrewrite=NOERROR;A;",
+		"|broken.ya.ru^$dnsrewrite=NOERROR;A;lol",
+		"# ---- DNSGateway rules end ----",
+	)
+
+	c, err := uadguard.NewUpstream(uadguard.WithUpstream(srv.URL))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	rr, err := c.Query(ctx, upstream.Rule{Type: dns.TypeAXFR})
+	require.NoError(t, err)
+	require.Len(t, rr, 2)
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.AdguardQuarantined))
+
+	quarantined, err := c.QuarantinedRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, quarantined, 2)
+	require.Equal(t, "@@||ya.ru^$important", quarantined[0].Line)
+	require.Equal(t, "|broken.ya.ru^$dnsrewrite=NOERROR;A;lol", quarantined[1].Line)
+
+	// quarantined lines are kept in place
+	tx, err := c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "ya.ru.", Type: dns.TypeA, ValueStr: "1.2.3.3"}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.4"),
+		ValueStr: "1.2.3.4",
+	}))
+	require.NoError(t, tx.Commit(ctx))
+	require.Equal(t, []string{
+		"# ---- DNSGateway rules begin ----",
+		"@@||ya.ru^$important",
+		"|ya.ru^$dnsrewrite=NOERROR;A;",
+		"|broken.ya.ru^$dnsrewrite=NOERROR;A;lol",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.4",
+		"# ---- DNSGateway rules end ----",
+	}, srv.Rules())
+
+	dropped, err := c.DropQuarantinedRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, dropped, 2)
+	require.Equal(t, []string{
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;A;",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.4",
+		"# ---- DNSGateway rules end ----",
+	}, srv.Rules())
+	require.Zero(t, testutil.ToFloat64(metrics.AdguardQuarantined))
+}
@@ -0,0 +1,130 @@
+package uadguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard/rules"
+)
+
+const maxReplays = 3
+
+var ErrConflict = errors.New("user rules were concurrently changed in a conflicting way")
+
+type rulesOp struct {
+	delete         bool
+	dropQuarantine bool
+	rule           upstream.Rule
+	deleted        []string
+}
+
+func (t *Tx) rulesDelete(q upstream.Rule) []upstream.Rule {
+	deleted := t.rules.Delete(q)
+	if len(deleted) > 0 {
+		t.changed = true
+	}
+
+	t.ops = append(t.ops, rulesOp{
+		delete:  true,
+		rule:    q,
+		deleted: formatRules(deleted),
+	})
+	return deleted
+}
+
+func (t *Tx) rulesAppend(r upstream.Rule) {
+	t.changed = true
+	t.rules.Append(r)
+	t.ops = append(t.ops, rulesOp{
+		rule: r,
+	})
+}
+
+func (t *Tx) rulesDropQuarantined() []rules.Quarantined {
+	dropped := t.rules.DropQuarantined()
+	if len(dropped) > 0 {
+		t.changed = true
+	}
+
+	t.ops = append(t.ops, rulesOp{
+		dropQuarantine: true,
+	})
+	return dropped
+}
+
+// syncRules makes sure that we are about to overwrite the same user rules we have read.
+// Otherwise, the transaction operations are replayed on top of the fresh rules,
+// which only fails if some delete would remove rules the client never saw.
+func (t *Tx) syncRules(ctx context.Context) error {
+	for attempt := 0; ; attempt++ {
+		actual, err := t.upc.fetchRawRules(ctx)
+		if err != nil {
+			return fmt.Errorf("re-fetch rules: %w", err)
+		}
+
+		if slices.Equal(actual, t.origin) {
+			return nil
+		}
+
+		if attempt >= maxReplays {
+			metrics.AdguardConflicts.Inc()
+			return fmt.Errorf("%w: rules are still changing after %d replays", ErrConflict, attempt)
+		}
+
+		fresh, err := t.upc.parseRules(actual)
+		if err != nil {
+			return fmt.Errorf("parse fresh rules: %w", err)
+		}
+
+		if err := replayOps(fresh, t.ops); err != nil {
+			metrics.AdguardConflicts.Inc()
+			return err
+		}
+
+		metrics.AdguardReplays.Inc()
+		t.upc.log.Warn().Int("attempt", attempt+1).Msg("user rules were changed concurrently, transaction replayed")
+		t.rules = fresh
+		t.origin = actual
+	}
+}
+
+func replayOps(s *rules.Storage, ops []rulesOp) error {
+	for _, op := range ops {
+		if op.dropQuarantine {
+			s.DropQuarantined()
+			continue
+		}
+
+		if !op.delete {
+			if len(s.Query(op.rule)) > 0 && op.rule.ValueStr != "" {
+				// somebody already added the same rule
+				continue
+			}
+
+			s.Append(op.rule)
+			continue
+		}
+
+		for _, d := range formatRules(s.Delete(op.rule)) {
+			if !slices.Contains(op.deleted, d) {
+				return fmt.Errorf("%w: %q would be deleted by %s %s", ErrConflict, d, upstream.TypeString(op.rule.Type), op.rule.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func formatRules(in []upstream.Rule) []string {
+	out := make([]string, len(in))
+	for i, r := range in {
+		rule := rules.Rule{Rule: &r}
+		out[i] = rule.Format()
+	}
+
+	return out
+}
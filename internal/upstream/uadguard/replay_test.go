@@ -0,0 +1,97 @@
+package uadguard_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+)
+
+func TestCommitReplay(t *testing.T) {
+	srv := newAdGuardMock(t,
+		"lol",
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"# ---- DNSGateway rules end ----",
+	)
+
+	c, err := uadguard.NewUpstream(uadguard.WithUpstream(srv.URL))
+	require.NoError(t, err)
+
+	replays := testutil.ToFloat64(metrics.AdguardReplays)
+	conflicts := testutil.ToFloat64(metrics.AdguardConflicts)
+	ctx := context.Background()
+	tx, err := c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "ya.ru.", Type: dns.TypeA}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.4"),
+		ValueStr: "1.2.3.4",
+	}))
+
+	// concurrent edit from the UI
+	srv.mu.Lock()
+	srv.rules = []string{
+		"lol",
+		"kek",
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"|other.ya.ru^$dnsrewrite=NOERROR;A;1.2.3.5",
+		"# ---- DNSGateway rules end ----",
+	}
+	srv.mu.Unlock()
+
+	require.NoError(t, tx.Commit(ctx))
+	require.Equal(t, []string{
+		"lol",
+		"kek",
+		"# ---- DNSGateway rules begin ----",
+		"|other.ya.ru^$dnsrewrite=NOERROR;A;1.2.3.5",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.4",
+		"# ---- DNSGateway rules end ----",
+	}, srv.Rules())
+	require.Equal(t, replays+1, testutil.ToFloat64(metrics.AdguardReplays))
+	require.Equal(t, conflicts, testutil.ToFloat64(metrics.AdguardConflicts))
+}
+
+func TestCommitConflict(t *testing.T) {
+	srv := newAdGuardMock(t,
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"# ---- DNSGateway rules end ----",
+	)
+
+	c, err := uadguard.NewUpstream(uadguard.WithUpstream(srv.URL))
+	require.NoError(t, err)
+
+	conflicts := testutil.ToFloat64(metrics.AdguardConflicts)
+	ctx := context.Background()
+	tx, err := c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "ya.ru.", Type: dns.TypeA}))
+
+	// the RRset delete would remove a record the client never saw
+	concurrent := []string{
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.9",
+		"# ---- DNSGateway rules end ----",
+	}
+	srv.mu.Lock()
+	srv.rules = concurrent
+	srv.mu.Unlock()
+
+	err = tx.Commit(ctx)
+	require.ErrorIs(t, err, uadguard.ErrConflict)
+	require.Equal(t, concurrent, srv.Rules())
+	require.Equal(t, conflicts+1, testutil.ToFloat64(metrics.AdguardConflicts))
+}
@@ -0,0 +1,194 @@
+package uadguard
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/fqdn"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+type rewriteRule struct {
+	entry   RewriteEntry
+	rule    upstream.Rule
+	pending bool
+}
+
+// Rewrites is the DNSGateway owned part of the AdGuard Home DNS rewrites list.
+type Rewrites struct {
+	owned    []rewriteRule
+	foreign  map[RewriteEntry]struct{}
+	toDelete []RewriteEntry
+	toAdd    []RewriteEntry
+}
+
+func NewRewrites(entries []RewriteEntry, own *Ownership) *Rewrites {
+	s := &Rewrites{
+		foreign: make(map[RewriteEntry]struct{}),
+	}
+
+	for _, e := range entries {
+		e = ownershipKey(e)
+		if !own.IsOwned(e) {
+			s.foreign[e] = struct{}{}
+			continue
+		}
+
+		rule, err := RuleFromRewrite(e)
+		if err != nil {
+			// owned rewrites are always created from valid rules, so somebody has changed it
+			s.foreign[e] = struct{}{}
+			continue
+		}
+		rule.Owner = own.Owner(e)
+
+		s.owned = append(s.owned, rewriteRule{
+			entry: e,
+			rule:  rule,
+		})
+	}
+
+	return s
+}
+
+func (s *Rewrites) Query(q upstream.Rule) []upstream.Rule {
+	var out []upstream.Rule
+	for _, r := range s.owned {
+		if !r.rule.Same(&q) {
+			continue
+		}
+
+		out = append(out, r.rule)
+	}
+
+	return out
+}
+
+func (s *Rewrites) Delete(q upstream.Rule) []upstream.Rule {
+	n := 0
+	var deleted []upstream.Rule
+	for _, r := range s.owned {
+		if !r.rule.Same(&q) {
+			s.owned[n] = r
+			n++
+			continue
+		}
+
+		deleted = append(deleted, r.rule)
+		if r.pending {
+			s.dropPending(r.entry)
+		} else {
+			s.toDelete = append(s.toDelete, r.entry)
+		}
+	}
+
+	s.owned = s.owned[:n]
+	return deleted
+}
+
+func (s *Rewrites) Append(r upstream.Rule) error {
+	entry := RewriteFromRule(r)
+	if _, exists := s.foreign[entry]; exists {
+		return fmt.Errorf("rewrite %s -> %s already exists and is not managed by DNSGateway", entry.Domain, entry.Answer)
+	}
+
+	s.owned = append(s.owned, rewriteRule{
+		entry:   entry,
+		rule:    r,
+		pending: true,
+	})
+	s.toAdd = append(s.toAdd, entry)
+	return nil
+}
+
+// Owner returns the owner of the managed rewrite.
+func (s *Rewrites) Owner(e RewriteEntry) string {
+	for _, r := range s.owned {
+		if r.entry == e {
+			return r.rule.Owner
+		}
+	}
+
+	return ""
+}
+
+func (s *Rewrites) Changed() bool {
+	return len(s.toDelete) > 0 || len(s.toAdd) > 0
+}
+
+// Changes returns pending changes, adds and deletes of the same domain are paired into updates.
+func (s *Rewrites) Changes() (updates []RewriteUpdateReq, deletes []RewriteEntry, adds []RewriteEntry) {
+	deletes = append(deletes, s.toDelete...)
+	for _, add := range s.toAdd {
+		paired := false
+		for i, del := range deletes {
+			if del.Domain != add.Domain {
+				continue
+			}
+
+			updates = append(updates, RewriteUpdateReq{
+				Target: del,
+				Update: add,
+			})
+			deletes = append(deletes[:i], deletes[i+1:]...)
+			paired = true
+			break
+		}
+
+		if !paired {
+			adds = append(adds, add)
+		}
+	}
+
+	return
+}
+
+func (s *Rewrites) dropPending(e RewriteEntry) {
+	for i, add := range s.toAdd {
+		if add == e {
+			s.toAdd = append(s.toAdd[:i], s.toAdd[i+1:]...)
+			return
+		}
+	}
+}
+
+// IsRewriteRule reports whether the rule can be expressed with the AdGuard Home DNS rewrites API.
+func IsRewriteRule(r upstream.Rule) bool {
+	if !r.Scope.IsZero() || r.IsNegative() {
+		return false
+	}
+
+	switch r.Type {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME:
+		return true
+	default:
+		return false
+	}
+}
+
+func RewriteFromRule(r upstream.Rule) RewriteEntry {
+	answer := r.ValueStr
+	if r.Type == dns.TypeCNAME {
+		answer = fqdn.UnFQDN(answer)
+	}
+
+	return RewriteEntry{
+		Domain: fqdn.UnFQDN(r.Name),
+		Answer: answer,
+	}
+}
+
+func RuleFromRewrite(e RewriteEntry) (upstream.Rule, error) {
+	name := fqdn.FQDN(e.Domain)
+	ip := net.ParseIP(e.Answer)
+	switch {
+	case ip == nil:
+		return upstream.NewRule(name, dns.TypeCNAME, e.Answer)
+	case ip.To4() != nil:
+		return upstream.NewRule(name, dns.TypeA, e.Answer)
+	default:
+		return upstream.NewRule(name, dns.TypeAAAA, e.Answer)
+	}
+}
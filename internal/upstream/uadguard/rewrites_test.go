@@ -0,0 +1,185 @@
+package uadguard_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uadguard"
+)
+
+func TestRewritesMode(t *testing.T) {
+	srv := newAdGuardMock(t, "lol")
+	srv.rewrites = []uadguard.RewriteEntry{
+		{Domain: "ya.ru", Answer: "1.2.3.3"},
+		{Domain: "human.ya.ru", Answer: "ya.ru"},
+	}
+
+	c, err := uadguard.NewUpstream(
+		uadguard.WithUpstream(srv.URL),
+		uadguard.WithMode(uadguard.ModeRewrites),
+		uadguard.WithOwnershipFile(filepath.Join(t.TempDir(), "ownership.json")),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	// foreign rewrites are invisible
+	rr, err := c.Query(ctx, upstream.Rule{Type: dns.TypeAXFR})
+	require.NoError(t, err)
+	require.Empty(t, rr)
+
+	tx, err := c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "ya.ru.", Type: dns.TypeA}))
+	require.Error(t, tx.Append(upstream.Rule{
+		Name:     "ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.3"),
+		ValueStr: "1.2.3.3",
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "gw.ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.4"),
+		ValueStr: "1.2.3.4",
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "gw.ya.ru.",
+		Type:     dns.TypeTXT,
+		Value:    []string{"lol"},
+		ValueStr: "lol",
+	}))
+	require.NoError(t, tx.Commit(ctx))
+
+	require.Equal(t, []uadguard.RewriteEntry{
+		{Domain: "ya.ru", Answer: "1.2.3.3"},
+		{Domain: "human.ya.ru", Answer: "ya.ru"},
+		{Domain: "gw.ya.ru", Answer: "1.2.3.4"},
+	}, srv.Rewrites())
+	require.Equal(t, []string{
+		"lol",
+		"# ---- DNSGateway rules begin ----",
+		"|gw.ya.ru^$dnsrewrite=NOERROR;TXT;lol",
+		"# ---- DNSGateway rules end ----",
+	}, srv.Rules())
+
+	// delete + append of the same name must be sent as update
+	tx, err = c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "gw.ya.ru.", Type: dns.TypeA}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "gw.ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.5"),
+		ValueStr: "1.2.3.5",
+	}))
+	require.NoError(t, tx.Commit(ctx))
+
+	require.Equal(t, []uadguard.RewriteEntry{
+		{Domain: "ya.ru", Answer: "1.2.3.3"},
+		{Domain: "human.ya.ru", Answer: "ya.ru"},
+		{Domain: "gw.ya.ru", Answer: "1.2.3.5"},
+	}, srv.Rewrites())
+
+	rr, err = c.Query(ctx, upstream.Rule{Name: "gw.ya.ru.", Type: dns.TypeNone})
+	require.NoError(t, err)
+	require.Len(t, rr, 2)
+}
+
+func TestRewritesFailedRulesCommit(t *testing.T) {
+	srv := newAdGuardMock(t,
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;TXT;a",
+		"# ---- DNSGateway rules end ----",
+	)
+
+	c, err := uadguard.NewUpstream(
+		uadguard.WithUpstream(srv.URL),
+		uadguard.WithMode(uadguard.ModeRewrites),
+		uadguard.WithOwnershipFile(filepath.Join(t.TempDir(), "ownership.json")),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	gw := upstream.Rule{
+		Name:     "gw.ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.4"),
+		ValueStr: "1.2.3.4",
+	}
+	tx, err := c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Append(gw))
+	require.NoError(t, tx.Commit(ctx))
+
+	initialRules := srv.Rules()
+	initialRewrites := srv.Rewrites()
+
+	// the failed rules commit reverts rewrites of the same transaction
+	tx, err = c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "gw.ya.ru.", Type: dns.TypeA}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "gw.ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.5"),
+		ValueStr: "1.2.3.5",
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "new.ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.6"),
+		ValueStr: "1.2.3.6",
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "gw.ya.ru.",
+		Type:     dns.TypeTXT,
+		Value:    []string{"lol"},
+		ValueStr: "lol",
+	}))
+
+	srv.mu.Lock()
+	srv.failRules = true
+	srv.mu.Unlock()
+	require.Error(t, tx.Commit(ctx))
+	require.Equal(t, initialRules, srv.Rules())
+	require.Equal(t, initialRewrites, srv.Rewrites())
+
+	// the conflict is detected before any rewrite is touched
+	srv.mu.Lock()
+	srv.failRules = false
+	srv.mu.Unlock()
+	tx, err = c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "ya.ru.", Type: dns.TypeTXT}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "new.ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.6"),
+		ValueStr: "1.2.3.6",
+	}))
+
+	concurrent := []string{
+		"# ---- DNSGateway rules begin ----",
+		"|ya.ru^$dnsrewrite=NOERROR;TXT;a",
+		"|ya.ru^$dnsrewrite=NOERROR;TXT;b",
+		"# ---- DNSGateway rules end ----",
+	}
+	srv.mu.Lock()
+	srv.rules = concurrent
+	srv.mu.Unlock()
+	require.ErrorIs(t, tx.Commit(ctx), uadguard.ErrConflict)
+	require.Equal(t, initialRewrites, srv.Rewrites())
+
+	// the restored rewrite is still owned
+	tx, err = c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{Name: "gw.ya.ru.", Type: dns.TypeA}))
+	require.NoError(t, tx.Commit(ctx))
+	require.Empty(t, srv.Rewrites())
+}
@@ -30,6 +30,7 @@ func (p *Parser) Parse(in []string) (*Storage, error) {
 	afterRulesIdx := -1
 	ourRules := make([]Rule, 0)
 	inOurRules := false
+	var ownerLine, ruleOwner string
 	for i, r := range in {
 		if !inOurRules {
 			if r == p.beginMarker {
@@ -44,14 +45,42 @@ func (p *Parser) Parse(in []string) (*Storage, error) {
 			break
 		}
 
+		if owner, ok := parseOwnerComment(r); ok {
+			if ownerLine != "" {
+				ourRules = append(ourRules, orphanedOwner(ownerLine))
+			}
+
+			ownerLine, ruleOwner = r, owner
+			continue
+		}
+
 		rule, err := p.ParseRule([]byte(r))
 		if err != nil {
-			return nil, fmt.Errorf("invalid rule %q: %w", r, err)
+			if errors.Is(err, io.EOF) {
+				err = errors.New("empty line")
+			}
+
+			if ownerLine != "" {
+				ourRules = append(ourRules, orphanedOwner(ownerLine))
+			}
+
+			// keep foreign lines as is, so a single broken line doesn't break the whole upstream
+			rule = Rule{
+				raw: r,
+				err: err,
+			}
+		} else if ownerLine != "" {
+			rule.Owner = ruleOwner
 		}
 
+		ownerLine, ruleOwner = "", ""
 		ourRules = append(ourRules, rule)
 	}
 
+	if ownerLine != "" {
+		ourRules = append(ourRules, orphanedOwner(ownerLine))
+	}
+
 	if beforeLen == -1 {
 		beforeLen = len(in)
 	}
@@ -79,6 +108,26 @@ func (p *Parser) Parse(in []string) (*Storage, error) {
 	}, nil
 }
 
+// parseOwnerComment parses the comment line preceding the rule with its owner, e.g.:
+//
+//	! dnsgateway:owner=acme-client.
+func parseOwnerComment(line string) (string, bool) {
+	comment, ok := strings.CutPrefix(strings.TrimSpace(line), "!")
+	if !ok {
+		return "", false
+	}
+
+	owner := upstream.OwnerFromComment(comment)
+	return owner, owner != ""
+}
+
+func orphanedOwner(line string) Rule {
+	return Rule{
+		raw: line,
+		err: errors.New("owner comment without rule"),
+	}
+}
+
 // ParseRule parses AdBlock rule with dnsrewrite option
 // AdBlock syntax: https://github.com/AdguardTeam/AdGuardHome/wiki/Hosts-Blocklists#adblock-style
 // examples:
@@ -87,9 +136,14 @@ func (p *Parser) Parse(in []string) (*Storage, error) {
 //	|2.0.0.0.0.0.0.0.4.f.7.0.0.0.0.0.0.0.4.3.0.0.0.0.8.b.6.0.2.0.a.2.ip6.arpa^$dnsrewrite=NOERROR;PTR;example.net.
 //	|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3
 //	|ya.ru^$dnsrewrite=NOERROR;AAAA;::1
+//	|ya.ru^$dnsrewrite=NOERROR;A;10.0.0.1,client=192.168.0.0/24|'Frank\'s laptop',ctag=device_pc
+//	|internal.ya.ru^$dnsrewrite=NXDOMAIN;;
+//	|internal.ya.ru^$dnsrewrite=REFUSED;;
+//	|ya.ru^$dnsrewrite=NOERROR;;
+//	|ya.ru^$dnsrewrite=NOERROR;AAAA;
 //
 // Limitation:
-//   - only dnsrewrite
+//   - only dnsrewrite, client and ctag modifiers
 //   - only strict match
 func (p *Parser) ParseRule(in []byte) (Rule, error) {
 	in = bytes.TrimSpace(in)
@@ -112,37 +166,195 @@ func (p *Parser) ParseRule(in []byte) (Rule, error) {
 		nameBytes = append([]byte{'*', '.'}, nameBytes[1:]...)
 	}
 	name := fqdn.FQDN(string(nameBytes))
-
 	in = in[idx+1:]
-	idx = bytes.IndexByte(in, ';')
-	if idx == -1 {
-		return Rule{}, errors.New("expected ';' as end of RRCode")
-	}
-	if !bytes.Equal(in[:idx], []byte("$dnsrewrite=NOERROR")) {
-		return Rule{}, fmt.Errorf("invalid dnsrewrite option: %s", string(in[:idx]))
+	if len(in) == 0 || in[0] != '$' {
+		return Rule{}, errors.New("expected '$' as start of modifiers")
 	}
-	in = in[idx+1:]
 
-	idx = bytes.IndexByte(in, ';')
-	if idx == -1 {
-		return Rule{}, errors.New("expected ';' as end of RRType")
+	var rewrite []byte
+	var scope upstream.Scope
+	for _, mod := range splitModifiers(in[1:]) {
+		key, value, _ := bytes.Cut(mod, []byte{'='})
+		switch string(key) {
+		case "dnsrewrite":
+			if rewrite != nil {
+				return Rule{}, errors.New("duplicate dnsrewrite modifier")
+			}
+			rewrite = value
+
+		case "client":
+			clients, err := parseClients(string(value))
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid client modifier: %w", err)
+			}
+			scope.Clients = clients
+
+		case "ctag":
+			scope.CTags = strings.Split(string(value), "|")
+
+		default:
+			return Rule{}, fmt.Errorf("unsupported modifier: %s", string(key))
+		}
 	}
-	rrType, err := strToRRType(string(in[:idx]))
-	if err != nil {
-		return Rule{}, fmt.Errorf("invalid RRType: %w", err)
+
+	if rewrite == nil {
+		return Rule{}, errors.New("dnsrewrite modifier is required")
 	}
-	in = in[idx+1:]
 
-	uRule, err := upstream.NewRule(name, rrType, UnescapeString(strings.TrimSpace(string(in))))
+	uRule, err := parseDNSRewrite(name, rewrite)
 	if err != nil {
 		return Rule{}, err
 	}
 
+	uRule.Scope = upstream.NewScope(scope.Clients, scope.CTags)
 	return Rule{
 		Rule: &uRule,
 	}, nil
 }
 
+func parseDNSRewrite(name string, in []byte) (upstream.Rule, error) {
+	rcode, in, _ := bytes.Cut(in, []byte{';'})
+	switch string(rcode) {
+	case "NXDOMAIN", "REFUSED":
+		if len(bytes.Trim(in, ";")) != 0 {
+			return upstream.Rule{}, fmt.Errorf("unexpected %s answer: %s", string(rcode), string(in))
+		}
+
+		negative := upstream.NegativeNXDomain
+		if string(rcode) == "REFUSED" {
+			negative = upstream.NegativeRefused
+		}
+		return upstream.NewNegativeRule(name, dns.TypeNone, negative)
+
+	case "NOERROR":
+	default:
+		return upstream.Rule{}, fmt.Errorf("invalid dnsrewrite option: $dnsrewrite=%s", string(rcode))
+	}
+
+	idx := bytes.IndexByte(in, ';')
+	if idx == -1 {
+		return upstream.Rule{}, errors.New("expected ';' as end of RRType")
+	}
+
+	if idx == 0 {
+		if len(in) != 1 {
+			return upstream.Rule{}, fmt.Errorf("unexpected answer without RRType: %s", string(in[1:]))
+		}
+
+		// NOERROR;; - empty answer for any type
+		return upstream.NewNegativeRule(name, dns.TypeNone, upstream.NegativeNoData)
+	}
+
+	rrType, err := strToRRType(string(in[:idx]))
+	if err != nil {
+		return upstream.Rule{}, fmt.Errorf("invalid RRType: %w", err)
+	}
+
+	if !IsSupportedType(rrType) {
+		return upstream.Rule{}, fmt.Errorf("unsupported RRType: %s", upstream.TypeString(rrType))
+	}
+
+	value := strings.TrimSpace(string(in[idx+1:]))
+	if value == "" {
+		return upstream.NewNegativeRule(name, rrType, upstream.NegativeNoData)
+	}
+
+	return upstream.NewRule(name, rrType, UnescapeString(value))
+}
+
+// splitModifiers splits rule modifiers by unescaped commas outside of quoted $client names,
+// e.g. $client='a,b' is the single modifier.
+func splitModifiers(in []byte) [][]byte {
+	var out [][]byte
+	escaped := false
+	var quote byte
+	start := 0
+	for i, c := range in {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '\'' || c == '"') && startsClientName(in[start:i]):
+			quote = c
+		case c == ',':
+			out = append(out, in[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(out, in[start:])
+}
+
+// startsClientName reports whether the next byte of the modifier starts a client name of $client,
+// so quotes elsewhere, e.g. in TXT values, are kept as is.
+func startsClientName(mod []byte) bool {
+	if !bytes.HasPrefix(mod, []byte("client=")) {
+		return false
+	}
+
+	last := mod[len(mod)-1]
+	return last == '=' || last == '|'
+}
+
+// parseClients parses $client modifier value, e.g.: 127.0.0.1|'Frank\'s laptop'|192.168.0.0/24
+func parseClients(in string) ([]string, error) {
+	var out []string
+	for len(in) > 0 {
+		quote := in[0]
+		if quote != '\'' && quote != '"' {
+			idx := strings.IndexByte(in, '|')
+			if idx == -1 {
+				idx = len(in)
+			}
+
+			if idx == 0 {
+				return nil, errors.New("empty client")
+			}
+
+			out = append(out, UnescapeString(in[:idx]))
+			in = strings.TrimPrefix(in[idx:], "|")
+			continue
+		}
+
+		end := -1
+		escaped := false
+		for i := 1; i < len(in); i++ {
+			switch {
+			case escaped:
+				escaped = false
+			case in[i] == '\\':
+				escaped = true
+			case in[i] == quote:
+				end = i
+			}
+
+			if end != -1 {
+				break
+			}
+		}
+
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated quote in %q", in)
+		}
+
+		out = append(out, UnescapeString(in[1:end]))
+		in = in[end+1:]
+		if len(in) > 0 {
+			if in[0] != '|' {
+				return nil, fmt.Errorf("expected '|' after quoted client, got %q", in[0])
+			}
+			in = in[1:]
+		}
+	}
+
+	return out, nil
+}
+
 func strToRRType(s string) (rr uint16, err error) {
 	// TypeNone and TypeReserved are special cases in package dns.
 	if strings.EqualFold(s, "none") || strings.EqualFold(s, "reserved") {
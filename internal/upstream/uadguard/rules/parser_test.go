@@ -120,11 +120,97 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseQuarantine(t *testing.T) {
+	in := []string{
+		"lol",
+		"--b--",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"||ads.example.com^",
+		"",
+		"|ya.ru^$dnsrewrite=NOERROR;AAAA;::1",
+		"--e--",
+	}
+
+	p := NewParser("--b--", "--e--")
+	s, err := p.Parse(in)
+	require.NoError(t, err)
+
+	require.Len(t, s.Rules(), 2)
+	require.Empty(t, s.Query(upstream.Rule{Name: "ads.example.com."}))
+
+	quarantined := s.Quarantined()
+	require.Len(t, quarantined, 2)
+	require.Equal(t, "||ads.example.com^", quarantined[0].Line)
+	require.Error(t, quarantined[0].Err)
+	require.Equal(t, "", quarantined[1].Line)
+
+	require.Equal(t, in, s.Dump())
+
+	require.Len(t, s.DropQuarantined(), 2)
+	require.Empty(t, s.Quarantined())
+	require.Equal(t, []string{
+		"lol",
+		"--b--",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"|ya.ru^$dnsrewrite=NOERROR;AAAA;::1",
+		"--e--",
+	}, s.Dump())
+}
+
+func TestParseOwner(t *testing.T) {
+	in := []string{
+		"--b--",
+		"! dnsgateway:owner=acme.",
+		"|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3",
+		"|ya.ru^$dnsrewrite=NOERROR;AAAA;::1",
+		"! dnsgateway:owner=other.",
+		"||ads.example.com^",
+		"! dnsgateway:owner=other.",
+		"--e--",
+	}
+
+	p := NewParser("--b--", "--e--")
+	s, err := p.Parse(in)
+	require.NoError(t, err)
+
+	rules := s.Rules()
+	require.Len(t, rules, 2)
+	require.Equal(t, "acme.", rules[0].Owner)
+	require.Empty(t, rules[1].Owner)
+
+	quarantined := s.Quarantined()
+	require.Len(t, quarantined, 3)
+	require.Equal(t, "! dnsgateway:owner=other.", quarantined[0].Line)
+	require.Equal(t, "||ads.example.com^", quarantined[1].Line)
+	require.Equal(t, "! dnsgateway:owner=other.", quarantined[2].Line)
+
+	require.Equal(t, in, s.Dump())
+
+	s.Delete(upstream.Rule{Name: "ya.ru.", Type: dns.TypeA})
+	s.DropQuarantined()
+	s.Append(upstream.Rule{
+		Name:     "owned.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.4"),
+		ValueStr: "1.2.3.4",
+		Owner:    "acme.",
+	})
+	require.Equal(t, []string{
+		"--b--",
+		"|ya.ru^$dnsrewrite=NOERROR;AAAA;::1",
+		"! dnsgateway:owner=acme.",
+		"|owned.ru^$dnsrewrite=NOERROR;A;1.2.3.4",
+		"--e--",
+	}, s.Dump())
+}
+
 func TestParseRule(t *testing.T) {
 	cases := []struct {
 		in  string
 		out Rule
 		err bool
+		// formatted is the canonical form of the rule, if it differs from the input one
+		formatted string
 	}{
 		{
 			in: "|4.3.2.1.in-addr.arpa^$dnsrewrite=NOERROR;PTR;example.net.",
@@ -256,7 +342,118 @@ func TestParseRule(t *testing.T) {
 			},
 		},
 		{
-			in:  "|ya.ru^$dnsrewrite=REFUSED;;",
+			in: `|ya.ru^$dnsrewrite=NOERROR;A;10.0.0.1,client=192.168.0.0/24|'Frank\'s laptop',ctag=device_pc|os_linux`,
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "ya.ru.",
+					Type:     dns.TypeA,
+					Value:    net.ParseIP("10.0.0.1"),
+					ValueStr: "10.0.0.1",
+					Scope: upstream.Scope{
+						Clients: []string{"192.168.0.0/24", "Frank's laptop"},
+						CTags:   []string{"device_pc", "os_linux"},
+					},
+				},
+			},
+		},
+		{
+			in: `|txt.ya.ru^$dnsrewrite=NOERROR;TXT;a\,b,ctag=user_child`,
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "txt.ya.ru.",
+					Type:     dns.TypeTXT,
+					Value:    []string{"a,b"},
+					ValueStr: "a,b",
+					Scope: upstream.Scope{
+						CTags: []string{"user_child"},
+					},
+				},
+			},
+		},
+		{
+			in:        `|ya.ru^$client='Frank, laptop'|"a,b",dnsrewrite=NOERROR;A;10.0.0.1`,
+			formatted: `|ya.ru^$dnsrewrite=NOERROR;A;10.0.0.1,client='Frank\, laptop'|'a\,b'`,
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "ya.ru.",
+					Type:     dns.TypeA,
+					Value:    net.ParseIP("10.0.0.1"),
+					ValueStr: "10.0.0.1",
+					Scope: upstream.Scope{
+						Clients: []string{"Frank, laptop", "a,b"},
+					},
+				},
+			},
+		},
+		{
+			in:        `|txt.ya.ru^$dnsrewrite=NOERROR;TXT;it's,ctag=user_child`,
+			formatted: `|txt.ya.ru^$dnsrewrite=NOERROR;TXT;it\'s,ctag=user_child`,
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "txt.ya.ru.",
+					Type:     dns.TypeTXT,
+					Value:    []string{"it's"},
+					ValueStr: "it's",
+					Scope: upstream.Scope{
+						CTags: []string{"user_child"},
+					},
+				},
+			},
+		},
+		{
+			in:  "|ya.ru^$dnsrewrite=NOERROR;A;1.2.3.3,important",
+			err: true,
+		},
+		{
+			in:  "|ya.ru^$client='unterminated,dnsrewrite=NOERROR;A;1.2.3.3",
+			err: true,
+		},
+		{
+			in: "|internal.ya.ru^$dnsrewrite=NXDOMAIN;;",
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "internal.ya.ru.",
+					Negative: upstream.NegativeNXDomain,
+				},
+			},
+		},
+		{
+			in: "|internal.ya.ru^$dnsrewrite=REFUSED;;,ctag=user_child",
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "internal.ya.ru.",
+					Negative: upstream.NegativeRefused,
+					Scope: upstream.Scope{
+						CTags: []string{"user_child"},
+					},
+				},
+			},
+		},
+		{
+			in: "|ya.ru^$dnsrewrite=NOERROR;;",
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "ya.ru.",
+					Negative: upstream.NegativeNoData,
+				},
+			},
+		},
+		{
+			in: "|ya.ru^$dnsrewrite=NOERROR;AAAA;",
+			out: Rule{
+				Rule: &upstream.Rule{
+					Name:     "ya.ru.",
+					Type:     dns.TypeAAAA,
+					Negative: upstream.NegativeNoData,
+				},
+			},
+		},
+		{
+			in:  "|ya.ru^$dnsrewrite=NXDOMAIN;A;1.2.3.3",
+			err: true,
+		},
+		{
+			in:  "|ya.ru^$dnsrewrite=SERVFAIL;;",
 			err: true,
 		},
 		{
@@ -278,6 +475,9 @@ func TestParseRule(t *testing.T) {
 			require.EqualExportedValues(t, tc.out, actual)
 
 			ruleStr := strings.TrimSuffix(tc.in, ".")
+			if tc.formatted != "" {
+				ruleStr = tc.formatted
+			}
 			require.Equal(t, ruleStr, actual.Format())
 		})
 	}
@@ -291,6 +491,43 @@ func TestParseRuleCommentCharsInValue(t *testing.T) {
 	require.Equal(t, `|txt.example.com^$dnsrewrite=NOERROR;TXT;foo#bar!baz # not a comment`, actual.Format())
 }
 
+func TestParseRuleSVCB(t *testing.T) {
+	cases := []struct {
+		in       string
+		valueStr string
+	}{
+		{
+			in:       `|example.com^$dnsrewrite=NOERROR;HTTPS;1 . alpn=h3\,h2 ipv4hint=1.2.3.4`,
+			valueStr: `1 . alpn="h3,h2" ipv4hint="1.2.3.4"`,
+		},
+		{
+			in:       `|_dns.example.com^$dnsrewrite=NOERROR;SVCB;1 dns.example.com alpn=dot port=853`,
+			valueStr: `1 dns.example.com. alpn="dot" port="853"`,
+		},
+	}
+
+	p := NewParser("b", "e")
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			actual, err := p.ParseRule([]byte(tc.in))
+			require.NoError(t, err)
+			require.Equal(t, tc.valueStr, actual.ValueStr)
+			require.Equal(t, tc.in, actual.Format())
+		})
+	}
+
+	_, err := p.ParseRule([]byte(`|example.com^$dnsrewrite=NOERROR;CAA;0 issue "letsencrypt.org"`))
+	require.ErrorContains(t, err, "unsupported RRType")
+}
+
+func TestParseRuleNegativeShorthand(t *testing.T) {
+	p := NewParser("b", "e")
+	actual, err := p.ParseRule([]byte("|internal.ya.ru^$dnsrewrite=REFUSED"))
+	require.NoError(t, err)
+	require.Equal(t, upstream.NegativeRefused, actual.Negative)
+	require.Equal(t, "|internal.ya.ru^$dnsrewrite=REFUSED;;", actual.Format())
+}
+
 func TestFormat(t *testing.T) {
 	cases := []struct {
 		out string
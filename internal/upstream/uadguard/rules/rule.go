@@ -2,6 +2,7 @@ package rules
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/miekg/dns"
@@ -12,6 +13,18 @@ import (
 
 type Rule struct {
 	*upstream.Rule
+	raw string
+	err error
+}
+
+// Quarantined is a line inside the managed block which can't be parsed as a DNSGateway rule.
+type Quarantined struct {
+	Line string
+	Err  error
+}
+
+func (r *Rule) IsQuarantined() bool {
+	return r.err != nil
 }
 
 func (r *Rule) Same(other Rule) bool {
@@ -19,10 +32,52 @@ func (r *Rule) Same(other Rule) bool {
 }
 
 func (r *Rule) SameUpstreamRule(other *upstream.Rule) bool {
+	if r.IsQuarantined() {
+		return false
+	}
+
 	return r.Rule.Same(other)
 }
 
 func (r *Rule) Format() string {
+	if r.IsQuarantined() {
+		return r.raw
+	}
+
+	return fmt.Sprintf("|%s^$dnsrewrite=%s", r.formatName(), r.formatRewrite()) + r.formatScope()
+}
+
+// FormatOwner returns the comment line to be placed before the rule, if the rule has owner.
+func (r *Rule) FormatOwner() string {
+	if r.IsQuarantined() || r.Owner == "" {
+		return ""
+	}
+
+	return "! " + upstream.OwnerComment(r.Owner)
+}
+
+func (r *Rule) formatName() string {
+	name := fqdn.UnFQDN(r.Name)
+	if strictName := strings.TrimPrefix(name, "*."); strictName != name {
+		name = "|" + strictName
+	}
+
+	return name
+}
+
+func (r *Rule) formatRewrite() string {
+	switch r.Negative {
+	case upstream.NegativeNXDomain:
+		return "NXDOMAIN;;"
+	case upstream.NegativeRefused:
+		return "REFUSED;;"
+	case upstream.NegativeNoData:
+		if r.Type == dns.TypeNone {
+			return "NOERROR;;"
+		}
+		return fmt.Sprintf("NOERROR;%s;", upstream.TypeString(r.Type))
+	}
+
 	value := r.ValueStr
 	if value == "" {
 		value = fmt.Sprint(r.Value)
@@ -41,15 +96,82 @@ func (r *Rule) Format() string {
 			parts[3] = fqdn.UnFQDN(parts[3])
 			value = strings.Join(parts, " ")
 		}
+	case dns.TypeHTTPS:
+		if v, ok := r.Value.(*dns.HTTPS); ok {
+			value = formatSVCB(&v.SVCB)
+		}
+	case dns.TypeSVCB:
+		if v, ok := r.Value.(*dns.SVCB); ok {
+			value = formatSVCB(v)
+		}
 	}
 
-	name := fqdn.UnFQDN(r.Name)
-	if strictName := strings.TrimPrefix(name, "*."); strictName != name {
-		name = "|" + strictName
+	return fmt.Sprintf("NOERROR;%s;%s", upstream.TypeString(r.Type), EscapeString(value))
+}
+
+func (r *Rule) formatScope() string {
+	var out string
+	if len(r.Scope.Clients) > 0 {
+		clients := make([]string, len(r.Scope.Clients))
+		for i, c := range r.Scope.Clients {
+			clients[i] = formatClient(c)
+		}
+		out += ",client=" + strings.Join(clients, "|")
+	}
+
+	if len(r.Scope.CTags) > 0 {
+		out += ",ctag=" + strings.Join(r.Scope.CTags, "|")
+	}
+
+	return out
+}
+
+// IsSupportedType reports whether AdGuard Home is able to answer with the record type using $dnsrewrite.
+func IsSupportedType(rrType uint16) bool {
+	switch rrType {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeMX, dns.TypePTR, dns.TypeTXT, dns.TypeSRV,
+		dns.TypeHTTPS, dns.TypeSVCB:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatSVCB formats HTTPS/SVCB value the way AdGuard Home expects it: without quoted params.
+func formatSVCB(svcb *dns.SVCB) string {
+	target := svcb.Target
+	if target != "." {
+		target = fqdn.UnFQDN(target)
+	}
+
+	parts := []string{strconv.Itoa(int(svcb.Priority)), target}
+	for _, kv := range svcb.Value {
+		v := kv.String()
+		if v == "" {
+			parts = append(parts, kv.Key().String())
+			continue
+		}
+
+		parts = append(parts, kv.Key().String()+"="+v)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// formatClient quotes client names, while leaving addresses and CIDRs as is.
+func formatClient(c string) string {
+	for _, r := range c {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			continue
+		}
+
+		switch r {
+		case '.', ':', '-', '_', '/':
+			continue
+		}
+
+		return "'" + EscapeString(c) + "'"
 	}
 
-	return fmt.Sprintf(
-		"|%s^$dnsrewrite=NOERROR;%s;%s",
-		name, upstream.TypeString(r.Type), EscapeString(value),
-	)
+	return c
 }
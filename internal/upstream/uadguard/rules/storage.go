@@ -1,6 +1,8 @@
 package rules
 
 import (
+	"slices"
+
 	"github.com/buglloc/DNSGateway/internal/upstream"
 )
 
@@ -11,14 +13,45 @@ type Storage struct {
 }
 
 func (s *Storage) Rules() []upstream.Rule {
-	out := make([]upstream.Rule, len(s.rules))
-	for i, r := range s.rules {
-		out[i] = *r.Rule
+	out := make([]upstream.Rule, 0, len(s.rules))
+	for _, r := range s.rules {
+		if r.IsQuarantined() {
+			continue
+		}
+
+		out = append(out, *r.Rule)
+	}
+
+	return out
+}
+
+// Quarantined returns lines inside the managed block which are kept as is, but ignored.
+func (s *Storage) Quarantined() []Quarantined {
+	var out []Quarantined
+	for _, r := range s.rules {
+		if !r.IsQuarantined() {
+			continue
+		}
+
+		out = append(out, Quarantined{
+			Line: r.raw,
+			Err:  r.err,
+		})
 	}
 
 	return out
 }
 
+// DropQuarantined removes quarantined lines from the managed block.
+func (s *Storage) DropQuarantined() []Quarantined {
+	dropped := s.Quarantined()
+	s.rules = slices.DeleteFunc(s.rules, func(r Rule) bool {
+		return r.IsQuarantined()
+	})
+
+	return dropped
+}
+
 func (s *Storage) Query(q upstream.Rule) []upstream.Rule {
 	var out []upstream.Rule
 	for _, rule := range s.rules {
@@ -60,6 +93,10 @@ func (s *Storage) Dump() []string {
 	copy(out, s.before)
 
 	for _, rule := range s.rules {
+		if owner := rule.FormatOwner(); owner != "" {
+			out = append(out, owner)
+		}
+
 		out = append(out, rule.Format())
 	}
 
@@ -2,86 +2,109 @@ package uadguard
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
 
 	"github.com/buglloc/DNSGateway/internal/upstream"
 	"github.com/buglloc/DNSGateway/internal/upstream/uadguard/rules"
 )
 
-var _ upstream.Tx = (*Tx)(nil)
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+var ErrUnsupportedType = errors.New("record type is not supported by AdGuard Home")
 
 type Tx struct {
-	httpc   *resty.Client
-	rules   *rules.Storage
-	autoPTR bool
-	changed bool
+	upc       *Upstream
+	httpc     *resty.Client
+	origin    []string
+	rules     *rules.Storage
+	ops       []rulesOp
+	rewrites  *Rewrites
+	ownership *Ownership
+	log       zerolog.Logger
+	changed   bool
 }
 
 func (t *Tx) Delete(r upstream.Rule) error {
-	deleted := t.rules.Delete(r)
-	if len(deleted) == 0 {
-		return nil
-	}
-
-	t.changed = true
-	if !t.needPTR(r.Type) {
-		return nil
-	}
-
-	for _, rule := range deleted {
-		arpa, err := dns.ReverseAddr(rule.ValueStr)
-		if err != nil {
-			return fmt.Errorf("generate arpa address: %w", err)
-		}
-
-		_ = t.rules.Delete(upstream.Rule{
-			Name: arpa,
-			Type: dns.TypePTR,
-		})
+	t.rulesDelete(r)
+	if t.rewrites != nil {
+		t.rewrites.Delete(r)
 	}
 
 	return nil
 }
 
 func (t *Tx) Append(r upstream.Rule) error {
-	if r.ValueStr == "" {
-		// TODO(buglloc): fix me
-		r.ValueStr = fmt.Sprint(r.Value)
+	if r.Type != dns.TypeNone && !rules.IsSupportedType(r.Type) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, upstream.TypeString(r.Type))
 	}
 
-	t.changed = true
-	t.rules.Append(r)
-	if !t.needPTR(r.Type) {
+	if r.IsNegative() {
+		t.rulesAppend(r)
 		return nil
 	}
 
-	arpa, err := dns.ReverseAddr(r.ValueStr)
-	if err != nil {
-		return fmt.Errorf("generate arpa address: %w", err)
+	if r.ValueStr == "" {
+		// TODO(buglloc): fix me
+		r.ValueStr = fmt.Sprint(r.Value)
 	}
 
-	_ = t.rules.Delete(upstream.Rule{
-		Name: arpa,
-		Type: dns.TypePTR,
-	})
+	if t.rewrites != nil && IsRewriteRule(r) {
+		return t.rewrites.Append(r)
+	}
 
-	t.rules.Append(upstream.Rule{
-		Name:     arpa,
-		Type:     dns.TypePTR,
-		Value:    r.Name,
-		ValueStr: r.Name,
-	})
+	t.rulesAppend(r)
 	return nil
 }
 
 func (t *Tx) Commit(ctx context.Context) error {
+	rewritesChanged := t.rewrites != nil && t.rewrites.Changed()
+	if !rewritesChanged && !t.changed {
+		return nil
+	}
+
+	// rules are checked first, so a conflict never leaves rewrites applied
+	if t.changed {
+		if err := t.syncRules(ctx); err != nil {
+			return err
+		}
+	}
+
+	var owned map[RewriteEntry]string
+	if rewritesChanged {
+		owned = t.replacedOwners()
+		if err := t.commitRewrites(ctx); err != nil {
+			return fmt.Errorf("commit rewrites: %w", err)
+		}
+	}
+
 	if !t.changed {
 		return nil
 	}
 
+	if err := t.commitRules(ctx); err != nil {
+		if rewritesChanged {
+			if undoErr := t.undoRewrites(ctx, owned); undoErr != nil {
+				err = errors.Join(err, fmt.Errorf("undo rewrites: %w", undoErr))
+			}
+		}
+
+		return err
+	}
+
+	t.upc.setQuarantined(len(t.rules.Quarantined()))
+	return nil
+}
+
+func (t *Tx) commitRules(ctx context.Context) error {
 	httpRsp, err := t.httpc.R().
 		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
@@ -102,12 +125,158 @@ func (t *Tx) Commit(ctx context.Context) error {
 	return nil
 }
 
-func (t *Tx) Close() {}
+// Diff returns the user rules diff and planned DNS rewrites API calls.
+func (t *Tx) Diff(_ context.Context) ([]string, error) {
+	var out []string
+	if t.rewrites != nil {
+		updates, deletes, adds := t.rewrites.Changes()
+		for _, e := range deletes {
+			out = append(out, fmt.Sprintf("delete rewrite %s -> %s", e.Domain, e.Answer))
+		}
 
-func (t *Tx) needPTR(rrType uint16) bool {
-	if !t.autoPTR {
-		return false
+		for _, u := range updates {
+			out = append(out, fmt.Sprintf("update rewrite %s -> %s: %s -> %s", u.Target.Domain, u.Target.Answer, u.Update.Domain, u.Update.Answer))
+		}
+
+		for _, e := range adds {
+			out = append(out, fmt.Sprintf("add rewrite %s -> %s", e.Domain, e.Answer))
+		}
 	}
 
-	return rrType == dns.TypeA || rrType == dns.TypeAAAA
+	if t.changed {
+		out = append(out, upstream.LinesDiff(t.origin, t.rules.Dump())...)
+	}
+
+	return out, nil
 }
+
+func (t *Tx) commitRewrites(ctx context.Context) (err error) {
+	var deleted []RewriteEntry
+	added := make(map[RewriteEntry]string)
+	defer func() {
+		if ownErr := t.ownership.Update(added, deleted); ownErr != nil {
+			err = errors.Join(err, fmt.Errorf("save ownership: %w", ownErr))
+		}
+	}()
+
+	updates, deletes, adds := t.rewrites.Changes()
+	for _, e := range deletes {
+		if err := t.callRewriteAPI(ctx, http.MethodPost, "/control/rewrite/delete", e); err != nil {
+			return fmt.Errorf("delete rewrite %s -> %s: %w", e.Domain, e.Answer, err)
+		}
+
+		deleted = append(deleted, e)
+		t.log.Info().Str("domain", e.Domain).Str("answer", e.Answer).Msg("rewrite deleted")
+	}
+
+	for _, u := range updates {
+		if err := t.callRewriteAPI(ctx, http.MethodPut, "/control/rewrite/update", u); err != nil {
+			return fmt.Errorf("update rewrite %s -> %s: %w", u.Target.Domain, u.Target.Answer, err)
+		}
+
+		deleted = append(deleted, u.Target)
+		added[u.Update] = t.rewrites.Owner(u.Update)
+		t.log.Info().Str("domain", u.Update.Domain).Str("answer", u.Update.Answer).Msg("rewrite updated")
+	}
+
+	for _, e := range adds {
+		if err := t.callRewriteAPI(ctx, http.MethodPost, "/control/rewrite/add", e); err != nil {
+			return fmt.Errorf("add rewrite %s -> %s: %w", e.Domain, e.Answer, err)
+		}
+
+		added[e] = t.rewrites.Owner(e)
+		t.log.Info().Str("domain", e.Domain).Str("answer", e.Answer).Msg("rewrite added")
+	}
+
+	return nil
+}
+
+// replacedOwners returns owners of the rewrites about to be deleted or updated, to restore them on undo.
+func (t *Tx) replacedOwners() map[RewriteEntry]string {
+	out := make(map[RewriteEntry]string)
+	updates, deletes, _ := t.rewrites.Changes()
+	for _, u := range updates {
+		deletes = append(deletes, u.Target)
+	}
+
+	for _, e := range deletes {
+		if t.ownership.IsOwned(e) {
+			out[e] = t.ownership.Owner(e)
+		}
+	}
+
+	return out
+}
+
+// undoRewrites reverts the committed rewrites, so the failed rules commit doesn't leave the transaction half-applied.
+func (t *Tx) undoRewrites(ctx context.Context, owned map[RewriteEntry]string) (err error) {
+	var deleted []RewriteEntry
+	restored := make(map[RewriteEntry]string)
+	defer func() {
+		if ownErr := t.ownership.Update(restored, deleted); ownErr != nil {
+			err = errors.Join(err, fmt.Errorf("save ownership: %w", ownErr))
+		}
+	}()
+
+	var errs []error
+	updates, deletes, adds := t.rewrites.Changes()
+	for _, e := range adds {
+		if err := t.callRewriteAPI(ctx, http.MethodPost, "/control/rewrite/delete", e); err != nil {
+			errs = append(errs, fmt.Errorf("delete rewrite %s -> %s: %w", e.Domain, e.Answer, err))
+			continue
+		}
+
+		deleted = append(deleted, e)
+	}
+
+	for _, u := range updates {
+		revert := RewriteUpdateReq{
+			Target: u.Update,
+			Update: u.Target,
+		}
+		if err := t.callRewriteAPI(ctx, http.MethodPut, "/control/rewrite/update", revert); err != nil {
+			errs = append(errs, fmt.Errorf("update rewrite %s -> %s: %w", u.Update.Domain, u.Update.Answer, err))
+			continue
+		}
+
+		deleted = append(deleted, u.Update)
+		if owner, ok := owned[u.Target]; ok {
+			restored[u.Target] = owner
+		}
+	}
+
+	for _, e := range deletes {
+		if err := t.callRewriteAPI(ctx, http.MethodPost, "/control/rewrite/add", e); err != nil {
+			errs = append(errs, fmt.Errorf("add rewrite %s -> %s: %w", e.Domain, e.Answer, err))
+			continue
+		}
+
+		if owner, ok := owned[e]; ok {
+			restored[e] = owner
+		}
+	}
+
+	if len(errs) == 0 {
+		t.log.Warn().Msg("rewrites reverted, because rules commit failed")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Tx) callRewriteAPI(ctx context.Context, method, path string, body any) error {
+	httpRsp, err := t.httpc.R().
+		SetContext(ctx).
+		SetBody(body).
+		Execute(method, path)
+	if err != nil {
+		return fmt.Errorf("make http request: %w", err)
+	}
+
+	if httpRsp.IsError() {
+		return fmt.Errorf("non-200 response: %s", string(httpRsp.Body()))
+	}
+
+	return nil
+}
+
+func (t *Tx) Close() {}
@@ -0,0 +1,119 @@
+// Package uautoptr manages PTR records for the A/AAAA records of any upstream.
+package uautoptr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+var defaultZones = []string{"in-addr.arpa.", "ip6.arpa."}
+
+type Upstream struct {
+	forward upstream.Upstream
+	reverse upstream.Upstream
+	zones   []string
+	policy  Policy
+	log     zerolog.Logger
+}
+
+func NewUpstream(forward upstream.Upstream, opts ...Option) (*Upstream, error) {
+	if forward == nil {
+		return nil, errors.New("no forward upstream configured")
+	}
+
+	u := &Upstream{
+		forward: forward,
+		reverse: forward,
+		policy:  PolicyKeep,
+		log: log.With().
+			Str("source", "auto-ptr").
+			Logger(),
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	if len(u.zones) == 0 {
+		u.zones = defaultZones
+	}
+
+	if _, err := ParsePolicy(string(u.policy)); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *Upstream) Query(ctx context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	if !u.separateReverse() {
+		return u.forward.Query(ctx, q)
+	}
+
+	if q.Type != dns.TypeAXFR {
+		return u.upstreamFor(q.Name).Query(ctx, q)
+	}
+
+	out, err := u.forward.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	reverse, err := u.reverse.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("query reverse upstream: %w", err)
+	}
+
+	return append(out, reverse...), nil
+}
+
+func (u *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	forward, err := u.forward.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		upc:     u,
+		ctx:     ctx,
+		forward: forward,
+		log:     u.log,
+	}, nil
+}
+
+func (u *Upstream) separateReverse() bool {
+	return u.reverse != u.forward
+}
+
+// upstreamFor returns the upstream serving the name.
+func (u *Upstream) upstreamFor(name string) upstream.Upstream {
+	if u.separateReverse() && u.isReverseName(name) {
+		return u.reverse
+	}
+
+	return u.forward
+}
+
+func (u *Upstream) isReverseName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	name = dns.Fqdn(name)
+	for _, zone := range u.zones {
+		if dns.IsSubDomain(zone, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,242 @@
+package uautoptr_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/uautoptr"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+)
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, func(t *testing.T) upstream.Upstream {
+		// conformance records must not get PTR companions
+		u, err := uautoptr.NewUpstream(umemory.NewUpstream(), uautoptr.WithZones("10.in-addr.arpa."))
+		require.NoError(t, err)
+		return u
+	})
+}
+
+func TestSharedAddress(t *testing.T) {
+	u, err := uautoptr.NewUpstream(umemory.NewUpstream())
+	require.NoError(t, err)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Append(mustRule(t, "a.example.com.", dns.TypeA, "10.0.0.1")))
+		require.NoError(t, tx.Append(mustRule(t, "b.example.com.", dns.TypeA, "10.0.0.1")))
+	})
+	require.Equal(t, []string{"a.example.com."}, ptrNames(t, u, "10.0.0.1"))
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(mustRule(t, "b.example.com.", dns.TypeA, "10.0.0.1")))
+	})
+	require.Equal(t, []string{"a.example.com."}, ptrNames(t, u, "10.0.0.1"))
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Append(mustRule(t, "b.example.com.", dns.TypeA, "10.0.0.1")))
+		require.NoError(t, tx.Delete(upstream.Rule{Name: "a.example.com."}))
+	})
+	require.Equal(t, []string{"b.example.com."}, ptrNames(t, u, "10.0.0.1"))
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{Name: "b.example.com.", Type: dns.TypeA}))
+	})
+	require.Empty(t, ptrNames(t, u, "10.0.0.1"))
+}
+
+func TestPolicy(t *testing.T) {
+	cases := []struct {
+		policy   uautoptr.Policy
+		expected []string
+	}{
+		{
+			policy:   uautoptr.PolicyKeep,
+			expected: []string{"b.example.com."},
+		},
+		{
+			policy:   uautoptr.PolicyLatest,
+			expected: []string{"c.example.com."},
+		},
+		{
+			policy:   uautoptr.PolicyLowest,
+			expected: []string{"a.example.com."},
+		},
+		{
+			policy:   uautoptr.PolicyAll,
+			expected: []string{"a.example.com.", "b.example.com.", "c.example.com."},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			u, err := uautoptr.NewUpstream(umemory.NewUpstream(), uautoptr.WithPolicy(tc.policy))
+			require.NoError(t, err)
+
+			apply(t, u, func(tx upstream.Tx) {
+				require.NoError(t, tx.Append(mustRule(t, "b.example.com.", dns.TypeA, "10.0.0.1")))
+			})
+			apply(t, u, func(tx upstream.Tx) {
+				require.NoError(t, tx.Append(mustRule(t, "a.example.com.", dns.TypeA, "10.0.0.1")))
+				require.NoError(t, tx.Append(mustRule(t, "c.example.com.", dns.TypeA, "10.0.0.1")))
+			})
+
+			require.Equal(t, tc.expected, ptrNames(t, u, "10.0.0.1"))
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := uautoptr.ParsePolicy("")
+	require.NoError(t, err)
+	require.Equal(t, uautoptr.PolicyKeep, p)
+
+	p, err = uautoptr.ParsePolicy("Latest")
+	require.NoError(t, err)
+	require.Equal(t, uautoptr.PolicyLatest, p)
+
+	_, err = uautoptr.ParsePolicy("random")
+	require.Error(t, err)
+}
+
+func TestReverseUpstream(t *testing.T) {
+	forward := umemory.NewUpstream()
+	reverse := umemory.NewUpstream()
+	u, err := uautoptr.NewUpstream(forward, uautoptr.WithReverseUpstream(reverse))
+	require.NoError(t, err)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Append(mustRule(t, "a.example.com.", dns.TypeAAAA, "2001:db8::1")))
+		require.NoError(t, tx.Append(mustRule(t, "1.0.0.10.in-addr.arpa.", dns.TypePTR, "manual.example.com.")))
+	})
+
+	arpa, err := dns.ReverseAddr("2001:db8::1")
+	require.NoError(t, err)
+
+	rules, err := reverse.Query(context.Background(), upstream.Rule{Name: arpa, Type: dns.TypePTR})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	rules, err = reverse.Query(context.Background(), upstream.Rule{Name: "1.0.0.10.in-addr.arpa.", Type: dns.TypePTR})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	rules, err = forward.Query(context.Background(), upstream.Rule{Type: dns.TypeAXFR})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	rules, err = u.Query(context.Background(), upstream.Rule{Type: dns.TypeAXFR})
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+}
+
+func TestZones(t *testing.T) {
+	u, err := uautoptr.NewUpstream(umemory.NewUpstream(), uautoptr.WithZones("168.192.in-addr.arpa."))
+	require.NoError(t, err)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Append(mustRule(t, "a.example.com.", dns.TypeA, "192.168.0.1")))
+		require.NoError(t, tx.Append(mustRule(t, "b.example.com.", dns.TypeA, "10.0.0.1")))
+		require.NoError(t, tx.Append(mustRule(t, "*.example.com.", dns.TypeA, "192.168.0.2")))
+	})
+
+	require.Equal(t, []string{"a.example.com."}, ptrNames(t, u, "192.168.0.1"))
+	require.Empty(t, ptrNames(t, u, "10.0.0.1"))
+	require.Empty(t, ptrNames(t, u, "192.168.0.2"))
+}
+
+func TestCommitQueries(t *testing.T) {
+	forward := &countingUpstream{Upstream: umemory.NewUpstream()}
+	u, err := uautoptr.NewUpstream(forward)
+	require.NoError(t, err)
+
+	apply(t, u, func(tx upstream.Tx) {
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+			require.NoError(t, tx.Append(mustRule(t, "a.example.com.", dns.TypeA, ip)))
+		}
+	})
+	// the committed records are fetched once, not per address
+	require.Equal(t, 1, forward.queries)
+
+	forward.queries = 0
+	apply(t, u, func(tx upstream.Tx) {
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			require.NoError(t, tx.Delete(mustRule(t, "a.example.com.", dns.TypeA, ip)))
+		}
+		require.NoError(t, tx.Delete(upstream.Rule{Name: "b.example.com."}))
+	})
+	require.Equal(t, 2, forward.queries)
+	require.Empty(t, ptrNames(t, u, "10.0.0.1"))
+	require.Equal(t, []string{"a.example.com."}, ptrNames(t, u, "10.0.0.3"))
+}
+
+func TestPTRFailure(t *testing.T) {
+	reverse := &failingUpstream{Upstream: umemory.NewUpstream()}
+	u, err := uautoptr.NewUpstream(umemory.NewUpstream(), uautoptr.WithReverseUpstream(reverse))
+	require.NoError(t, err)
+
+	tx, err := u.Tx(context.Background())
+	require.NoError(t, err)
+	defer tx.Close()
+
+	require.NoError(t, tx.Append(mustRule(t, "a.example.com.", dns.TypeA, "10.0.0.1")))
+	require.ErrorContains(t, tx.Commit(context.Background()), "update PTR records")
+}
+
+type countingUpstream struct {
+	upstream.Upstream
+	queries int
+}
+
+func (u *countingUpstream) Query(ctx context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	u.queries++
+	return u.Upstream.Query(ctx, q)
+}
+
+type failingUpstream struct {
+	upstream.Upstream
+}
+
+func (u *failingUpstream) Tx(_ context.Context) (upstream.Tx, error) {
+	return nil, errors.New("reverse upstream is down")
+}
+
+func mustRule(t *testing.T, name string, typ upstream.RType, value string) upstream.Rule {
+	t.Helper()
+
+	r, err := upstream.NewRule(name, typ, value)
+	require.NoError(t, err)
+	return r
+}
+
+func apply(t *testing.T, u upstream.Upstream, fn func(tx upstream.Tx)) {
+	t.Helper()
+
+	tx, err := u.Tx(context.Background())
+	require.NoError(t, err)
+	defer tx.Close()
+
+	fn(tx)
+	require.NoError(t, tx.Commit(context.Background()))
+}
+
+func ptrNames(t *testing.T, u upstream.Upstream, ip string) []string {
+	t.Helper()
+
+	arpa, err := dns.ReverseAddr(ip)
+	require.NoError(t, err)
+
+	rules, err := u.Query(context.Background(), upstream.Rule{Name: arpa, Type: dns.TypePTR})
+	require.NoError(t, err)
+
+	var out []string
+	for _, r := range rules {
+		out = append(out, r.ValueStr)
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package uautoptr
+
+import (
+	"github.com/buglloc/DNSGateway/internal/fqdn"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+type Option func(*Upstream)
+
+// WithReverseUpstream makes reverse zones to be served by the other upstream.
+func WithReverseUpstream(reverse upstream.Upstream) Option {
+	return func(u *Upstream) {
+		if reverse == nil {
+			return
+		}
+
+		u.reverse = reverse
+	}
+}
+
+// WithZones limits managed PTR records to the given reverse zones, e.g. "168.192.in-addr.arpa.".
+func WithZones(zones ...string) Option {
+	return func(u *Upstream) {
+		for _, zone := range zones {
+			u.zones = append(u.zones, fqdn.FQDN(zone))
+		}
+	}
+}
+
+func WithPolicy(policy Policy) Option {
+	return func(u *Upstream) {
+		u.policy = policy
+	}
+}
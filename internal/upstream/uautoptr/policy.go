@@ -0,0 +1,79 @@
+package uautoptr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Policy decides which names the PTR record points to when several forward records share an address.
+type Policy string
+
+const (
+	// PolicyKeep keeps the current PTR while its name still has a forward record,
+	// otherwise the name added first in the transaction (or the lowest one) wins.
+	PolicyKeep Policy = "keep"
+	// PolicyLatest makes the name added last in the transaction win.
+	PolicyLatest Policy = "latest"
+	// PolicyLowest makes the alphabetically lowest name win, so the result doesn't depend on history.
+	PolicyLowest Policy = "lowest"
+	// PolicyAll creates PTR records for every name.
+	PolicyAll Policy = "all"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(s)); p {
+	case "":
+		return PolicyKeep, nil
+	case PolicyKeep, PolicyLatest, PolicyLowest, PolicyAll:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid auto PTR policy: %s", s)
+	}
+}
+
+// choose returns the desired PTR targets, where names are the forward names pointing to the address,
+// current are the existing PTR targets and added are names added by the transaction in order.
+func (p Policy) choose(names, current, added []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	names = slices.Clone(names)
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	switch p {
+	case PolicyAll:
+		return names
+
+	case PolicyLowest:
+		return names[:1]
+
+	case PolicyLatest:
+		for i := len(added) - 1; i >= 0; i-- {
+			if slices.Contains(names, added[i]) {
+				return []string{added[i]}
+			}
+		}
+	}
+
+	var kept []string
+	for _, name := range current {
+		if slices.Contains(names, name) && !slices.Contains(kept, name) {
+			kept = append(kept, name)
+		}
+	}
+	if len(kept) > 0 {
+		slices.Sort(kept)
+		return kept
+	}
+
+	for _, name := range added {
+		if slices.Contains(names, name) {
+			return []string{name}
+		}
+	}
+
+	return names[:1]
+}
@@ -0,0 +1,344 @@
+package uautoptr
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	upc      *Upstream
+	ctx      context.Context
+	forward  upstream.Tx
+	reverse  upstream.Tx
+	affected map[string]*address
+	// origin is the forward records before the transaction, fetched once for deletes
+	origin []upstream.Rule
+	log    zerolog.Logger
+}
+
+// address is the forward address touched by the transaction.
+type address struct {
+	ip    net.IP
+	scope upstream.Scope
+	added []string
+}
+
+func (t *Tx) Delete(r upstream.Rule) error {
+	if t.upc.isReverseName(r.Name) && t.upc.separateReverse() {
+		tx, err := t.reverseTx()
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(r)
+	}
+
+	if canHaveAddress(r.Type) {
+		// the rule may be a wide RRset delete, so check what is going to be deleted
+		origin, err := t.originRules()
+		if err != nil {
+			return fmt.Errorf("query deleted addresses: %w", err)
+		}
+
+		for _, rule := range origin {
+			if rule.Same(&r) {
+				t.touch(rule, false)
+			}
+		}
+	}
+
+	return t.forward.Delete(r)
+}
+
+func (t *Tx) Append(r upstream.Rule) error {
+	if t.upc.isReverseName(r.Name) && t.upc.separateReverse() {
+		tx, err := t.reverseTx()
+		if err != nil {
+			return err
+		}
+
+		return tx.Append(r)
+	}
+
+	if err := t.forward.Append(r); err != nil {
+		return err
+	}
+
+	t.touch(r, true)
+	return nil
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	if err := t.forward.Commit(ctx); err != nil {
+		return err
+	}
+
+	if t.reverse != nil {
+		if err := t.reverse.Commit(ctx); err != nil {
+			return fmt.Errorf("commit reverse upstream: %w", err)
+		}
+	}
+
+	if err := t.syncPTR(ctx); err != nil {
+		// forward records are already committed, while PTR records are recalculated on the next change of the address
+		return fmt.Errorf("update PTR records: %w", err)
+	}
+
+	return nil
+}
+
+// Diff returns pending changes of the forward and reverse upstreams,
+// PTR records are recalculated only after the commit, so they are not included.
+func (t *Tx) Diff(ctx context.Context) ([]string, error) {
+	out, err := upstream.TxDiff(ctx, t.forward)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.reverse != nil {
+		reverse, err := upstream.TxDiff(ctx, t.reverse)
+		if err != nil {
+			return nil, fmt.Errorf("reverse upstream: %w", err)
+		}
+
+		out = append(out, reverse...)
+	}
+
+	return out, nil
+}
+
+func (t *Tx) Close() {
+	t.forward.Close()
+	if t.reverse != nil {
+		t.reverse.Close()
+	}
+}
+
+func (t *Tx) originRules() ([]upstream.Rule, error) {
+	if t.origin != nil {
+		return t.origin, nil
+	}
+
+	rules, err := allRules(t.ctx, t.upc.forward)
+	if err != nil {
+		return nil, err
+	}
+
+	t.origin = rules
+	return rules, nil
+}
+
+func (t *Tx) reverseTx() (upstream.Tx, error) {
+	if t.reverse != nil {
+		return t.reverse, nil
+	}
+
+	tx, err := t.upc.reverse.Tx(t.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create reverse upstream tx: %w", err)
+	}
+
+	t.reverse = tx
+	return tx, nil
+}
+
+func (t *Tx) touch(r upstream.Rule, added bool) {
+	if r.IsNegative() || (r.Type != dns.TypeA && r.Type != dns.TypeAAAA) {
+		return
+	}
+
+	if strings.HasPrefix(r.Name, "*.") {
+		// wildcards can't be a PTR target
+		return
+	}
+
+	ip := ruleIP(r)
+	if ip == nil {
+		return
+	}
+
+	arpa, err := dns.ReverseAddr(ip.String())
+	if err != nil || !t.upc.isReverseName(arpa) {
+		return
+	}
+
+	if t.affected == nil {
+		t.affected = make(map[string]*address)
+	}
+
+	key := arpa + "|" + r.Scope.String()
+	addr, ok := t.affected[key]
+	if !ok {
+		addr = &address{
+			ip:    ip,
+			scope: r.Scope,
+		}
+		t.affected[key] = addr
+	}
+
+	if added {
+		addr.added = append(addr.added, dns.Fqdn(r.Name))
+	}
+}
+
+// syncPTR recalculates PTR records of the touched addresses from the committed forward records,
+// so the PTR record goes away only with the last forward record pointing to the address.
+func (t *Tx) syncPTR(ctx context.Context) error {
+	if len(t.affected) == 0 {
+		return nil
+	}
+
+	// the committed records are fetched once for all addresses
+	forward, err := allRules(ctx, t.upc.forward)
+	if err != nil {
+		return fmt.Errorf("query forward records: %w", err)
+	}
+
+	reverse := forward
+	if t.upc.separateReverse() {
+		reverse, err = allRules(ctx, t.upc.reverse)
+		if err != nil {
+			return fmt.Errorf("query reverse records: %w", err)
+		}
+	}
+
+	tx, err := t.upc.reverse.Tx(ctx)
+	if err != nil {
+		return fmt.Errorf("create reverse upstream tx: %w", err)
+	}
+	defer tx.Close()
+
+	keys := make([]string, 0, len(t.affected))
+	for key := range t.affected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changed := false
+	for _, key := range keys {
+		addr := t.affected[key]
+		arpa, _ := dns.ReverseAddr(addr.ip.String())
+
+		names := forwardNames(forward, addr)
+		current := matchRules(reverse, upstream.Rule{
+			Name:  arpa,
+			Type:  dns.TypePTR,
+			Scope: addr.scope,
+		})
+
+		currentNames := make([]string, len(current))
+		for i, r := range current {
+			currentNames[i] = dns.Fqdn(r.ValueStr)
+		}
+
+		desired := t.upc.policy.choose(names, currentNames, addr.added)
+		if sameNames(currentNames, desired) {
+			continue
+		}
+
+		if err := tx.Delete(upstream.Rule{Name: arpa, Type: dns.TypePTR, Scope: addr.scope}); err != nil {
+			return fmt.Errorf("delete PTR for %s: %w", addr.ip, err)
+		}
+
+		for _, name := range desired {
+			err := tx.Append(upstream.Rule{
+				Name:     arpa,
+				Type:     dns.TypePTR,
+				Value:    name,
+				ValueStr: name,
+				Scope:    addr.scope,
+			})
+			if err != nil {
+				return fmt.Errorf("append PTR for %s: %w", addr.ip, err)
+			}
+		}
+
+		t.log.Info().Stringer("ip", addr.ip).Strs("names", desired).Msg("PTR updated")
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return tx.Commit(ctx)
+}
+
+// forwardNames returns names of the forward records pointing to the address.
+func forwardNames(rules []upstream.Rule, addr *address) []string {
+	rrType := dns.TypeAAAA
+	if addr.ip.To4() != nil {
+		rrType = dns.TypeA
+	}
+
+	var out []string
+	for _, r := range matchRules(rules, upstream.Rule{Type: rrType, Scope: addr.scope}) {
+		if ip := ruleIP(r); ip != nil && ip.Equal(addr.ip) {
+			out = append(out, dns.Fqdn(r.Name))
+		}
+	}
+
+	return out
+}
+
+// allRules returns records of every scope, so the transaction fetches them once instead of querying every name.
+func allRules(ctx context.Context, u upstream.Upstream) ([]upstream.Rule, error) {
+	rules, err := u.Query(ctx, upstream.Rule{
+		Type:  dns.TypeAXFR,
+		Scope: upstream.AnyScope(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rules == nil {
+		rules = []upstream.Rule{}
+	}
+
+	return rules, nil
+}
+
+func matchRules(rules []upstream.Rule, q upstream.Rule) []upstream.Rule {
+	var out []upstream.Rule
+	for _, r := range rules {
+		if r.Same(&q) {
+			out = append(out, r)
+		}
+	}
+
+	return out
+}
+
+func canHaveAddress(rrType uint16) bool {
+	return rrType == dns.TypeA || rrType == dns.TypeAAAA || rrType == dns.TypeNone
+}
+
+func ruleIP(r upstream.Rule) net.IP {
+	if ip, ok := r.Value.(net.IP); ok {
+		return ip
+	}
+
+	return net.ParseIP(r.ValueStr)
+}
+
+func sameNames(a, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,121 @@
+package ucloudflare_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/ucloudflare"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+)
+
+const testZoneID = "c74b3a3b1002eba34d6cb7f8a62b69da"
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, newTestUpstream)
+}
+
+func TestConformanceTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, upstreamtest.ExtendedTypes...)
+}
+
+func TestConformanceOpaqueTypes(t *testing.T) {
+	upstreamtest.RunRefusedTypes(t, newTestUpstream, upstreamtest.OpaqueTypes...)
+}
+
+func newTestUpstream(t *testing.T) upstream.Upstream {
+	srv := newCloudflareMock(t)
+
+	cfc, err := cloudflare.NewWithAPIToken("token",
+		cloudflare.BaseURL(srv.URL),
+		cloudflare.HTTPClient(srv.Client()),
+		cloudflare.UsingRateLimit(1000),
+	)
+	require.NoError(t, err)
+
+	c, err := ucloudflare.NewUpstreamWithCFC(cfc,
+		ucloudflare.WithZoneID(testZoneID),
+	)
+	require.NoError(t, err)
+	return c
+}
+
+// newCloudflareMock emulates the small subset of the Cloudflare DNS records API used by the upstream.
+func newCloudflareMock(t *testing.T) *httptest.Server {
+	var mux http.ServeMux
+	var mu sync.Mutex
+	var records []cloudflare.DNSRecord
+	var lastID int
+
+	writeResult := func(w http.ResponseWriter, result any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"success":  true,
+			"errors":   []any{},
+			"messages": []any{},
+			"result":   result,
+			"result_info": cloudflare.ResultInfo{
+				Page:       1,
+				PerPage:    len(records),
+				TotalPages: 1,
+				Count:      len(records),
+				Total:      len(records),
+			},
+		})
+	}
+
+	prefix := fmt.Sprintf("/zones/%s/dns_records", testZoneID)
+	mux.HandleFunc("GET "+prefix, func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		writeResult(w, records)
+	})
+
+	mux.HandleFunc("POST "+prefix, func(w http.ResponseWriter, r *http.Request) {
+		var rec cloudflare.DNSRecord
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		lastID++
+		rec.ID = fmt.Sprintf("id-%d", lastID)
+		rec.Name = strings.TrimSuffix(rec.Name, ".")
+		records = append(records, rec)
+		writeResult(w, rec)
+	})
+
+	mux.HandleFunc("DELETE "+prefix+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := r.PathValue("id")
+		for i, rec := range records {
+			if rec.ID != id {
+				continue
+			}
+
+			records = append(records[:i], records[i+1:]...)
+			writeResult(w, map[string]string{"id": id})
+			return
+		}
+
+		http.Error(w, "record not found", http.StatusNotFound)
+	})
+
+	srv := httptest.NewServer(&mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -0,0 +1,174 @@
+package ucloudflare
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// dataFromRR builds Cloudflare structured "data" field for record types which have no plain content.
+func dataFromRR(rr dns.RR) (map[string]any, error) {
+	switch v := rr.(type) {
+	case *dns.CAA:
+		return map[string]any{
+			"flags": v.Flag,
+			"tag":   v.Tag,
+			"value": v.Value,
+		}, nil
+
+	case *dns.SSHFP:
+		return map[string]any{
+			"algorithm":   v.Algorithm,
+			"type":        v.Type,
+			"fingerprint": v.FingerPrint,
+		}, nil
+
+	case *dns.TLSA:
+		return map[string]any{
+			"usage":         v.Usage,
+			"selector":      v.Selector,
+			"matching_type": v.MatchingType,
+			"certificate":   v.Certificate,
+		}, nil
+
+	case *dns.HTTPS:
+		return svcbData(&v.SVCB), nil
+
+	case *dns.SVCB:
+		return svcbData(v), nil
+
+	case *dns.NAPTR:
+		return map[string]any{
+			"order":       v.Order,
+			"preference":  v.Preference,
+			"flags":       v.Flags,
+			"service":     v.Service,
+			"regex":       v.Regexp,
+			"replacement": v.Replacement,
+		}, nil
+
+	case *dns.DS:
+		return map[string]any{
+			"key_tag":     v.KeyTag,
+			"algorithm":   v.Algorithm,
+			"digest_type": v.DigestType,
+			"digest":      v.Digest,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedRecordType, upstream.TypeString(rr.Header().Rrtype))
+	}
+}
+
+func svcbData(v *dns.SVCB) map[string]any {
+	params := make([]string, len(v.Value))
+	for i, kv := range v.Value {
+		params[i] = kv.Key().String()
+		if value := kv.String(); value != "" {
+			params[i] += "=" + strconv.Quote(value)
+		}
+	}
+
+	return map[string]any{
+		"priority": v.Priority,
+		"target":   v.Target,
+		"value":    strings.Join(params, " "),
+	}
+}
+
+// contentFromData formats Cloudflare structured "data" field as RR data in presentation format.
+func contentFromData(rType uint16, raw any) (string, error) {
+	data, ok := raw.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("unexpected %s data type: %T", upstream.TypeString(rType), raw)
+	}
+
+	var f dataFields
+	var out string
+	switch rType {
+	case dns.TypeCAA:
+		out = fmt.Sprintf("%d %s %q", f.uint8(data, "flags"), f.string(data, "tag"), f.string(data, "value"))
+
+	case dns.TypeSSHFP:
+		out = fmt.Sprintf("%d %d %s", f.uint8(data, "algorithm"), f.uint8(data, "type"), f.string(data, "fingerprint"))
+
+	case dns.TypeTLSA:
+		out = fmt.Sprintf("%d %d %d %s",
+			f.uint8(data, "usage"), f.uint8(data, "selector"), f.uint8(data, "matching_type"), f.string(data, "certificate"),
+		)
+
+	case dns.TypeHTTPS, dns.TypeSVCB:
+		out = fmt.Sprintf("%d %s %s", f.uint16(data, "priority"), f.string(data, "target"), f.optString(data, "value"))
+
+	case dns.TypeNAPTR:
+		out = fmt.Sprintf("%d %d %q %q %q %s",
+			f.uint16(data, "order"), f.uint16(data, "preference"),
+			f.optString(data, "flags"), f.optString(data, "service"), f.optString(data, "regex"),
+			f.string(data, "replacement"),
+		)
+
+	case dns.TypeDS:
+		out = fmt.Sprintf("%d %d %d %s",
+			f.uint16(data, "key_tag"), f.uint8(data, "algorithm"), f.uint8(data, "digest_type"), f.string(data, "digest"),
+		)
+
+	default:
+		return "", fmt.Errorf("%w: %s", errUnsupportedRecordType, upstream.TypeString(rType))
+	}
+
+	if f.err != nil {
+		return "", fmt.Errorf("invalid %s data: %w", upstream.TypeString(rType), f.err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// dataFields collects the first error, so data conversions stay readable.
+type dataFields struct {
+	err error
+}
+
+func (f *dataFields) uint8(data map[string]any, name string) uint8 {
+	v := f.uint16(data, name)
+	if v > math.MaxUint8 {
+		f.setErr(name, fmt.Errorf("out of range: %d", v))
+		return 0
+	}
+
+	return uint8(v)
+}
+
+func (f *dataFields) uint16(data map[string]any, name string) uint16 {
+	v, err := uint16Field(data, name)
+	f.setErr(name, err)
+	return v
+}
+
+func (f *dataFields) string(data map[string]any, name string) string {
+	v, err := stringField(data, name)
+	f.setErr(name, err)
+	return v
+}
+
+func (f *dataFields) optString(data map[string]any, name string) string {
+	v, err := stringField(data, name)
+	if err != nil && !errors.Is(err, errEmptyField) && !errors.Is(err, errMissingField) {
+		f.setErr(name, err)
+	}
+
+	return v
+}
+
+func (f *dataFields) setErr(name string, err error) {
+	if err == nil || f.err != nil {
+		return
+	}
+
+	f.err = fmt.Errorf("field %q: %w", name, err)
+}
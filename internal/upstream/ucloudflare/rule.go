@@ -15,7 +15,11 @@ import (
 
 var noProxied = false
 
-var errUnsupportedRecordType = errors.New("unsupported cloudflare record type")
+var (
+	errUnsupportedRecordType = errors.New("unsupported cloudflare record type")
+	errMissingField          = errors.New("missing field")
+	errEmptyField            = errors.New("empty field")
+)
 
 type Rule struct {
 	cfRecord cloudflare.DNSRecord
@@ -42,12 +46,19 @@ func RuleFromCF(r cloudflare.DNSRecord) (Rule, error) {
 			return Rule{}, err
 		}
 		content = srv
+	case "CAA", "SSHFP", "TLSA", "HTTPS", "SVCB", "NAPTR", "DS":
+		rdata, err := contentFromData(rType, r.Data)
+		if err != nil {
+			return Rule{}, err
+		}
+		content = rdata
 	}
 
 	uRule, err := upstream.NewRule(fqdn.FQDN(r.Name), rType, strings.TrimSpace(content))
 	if err != nil {
 		return Rule{}, err
 	}
+	uRule.Owner = upstream.OwnerFromComment(r.Comment)
 
 	return Rule{
 		cfRecord: r,
@@ -62,6 +73,7 @@ func RuleFromUpstream(r upstream.Rule) (Rule, error) {
 		Content: r.ValueStr,
 		TTL:     defaultTTL,
 		Proxied: &noProxied,
+		Comment: upstream.OwnerComment(r.Owner),
 	}
 
 	switch r.Type {
@@ -95,6 +107,18 @@ func RuleFromUpstream(r upstream.Rule) (Rule, error) {
 
 		record.Content = srv.Target
 		record.Data = data
+
+	case dns.TypeCAA, dns.TypeSSHFP, dns.TypeTLSA, dns.TypeHTTPS, dns.TypeSVCB, dns.TypeNAPTR, dns.TypeDS:
+		rr, ok := r.Value.(dns.RR)
+		if !ok {
+			return Rule{}, fmt.Errorf("unexpected %s value type: %T", upstream.TypeString(r.Type), r.Value)
+		}
+
+		data, err := dataFromRR(rr)
+		if err != nil {
+			return Rule{}, err
+		}
+		record.Data = data
 	}
 
 	return Rule{
@@ -105,7 +129,8 @@ func RuleFromUpstream(r upstream.Rule) (Rule, error) {
 
 func isSupportedRecordType(rType uint16) bool {
 	switch rType {
-	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeMX, dns.TypePTR, dns.TypeTXT, dns.TypeSRV:
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeMX, dns.TypePTR, dns.TypeTXT, dns.TypeSRV,
+		dns.TypeNS, dns.TypeCAA, dns.TypeSSHFP, dns.TypeTLSA, dns.TypeHTTPS, dns.TypeSVCB, dns.TypeNAPTR, dns.TypeDS:
 		return true
 	default:
 		return false
@@ -141,7 +166,7 @@ func srvContentFromCF(r cloudflare.DNSRecord) (string, error) {
 func uint16Field(data map[string]any, name string) (uint16, error) {
 	v, ok := data[name]
 	if !ok {
-		return 0, errors.New("missing field")
+		return 0, errMissingField
 	}
 
 	switch n := v.(type) {
@@ -185,7 +210,7 @@ func uint16Field(data map[string]any, name string) (uint16, error) {
 func stringField(data map[string]any, name string) (string, error) {
 	v, ok := data[name]
 	if !ok {
-		return "", errors.New("missing field")
+		return "", errMissingField
 	}
 
 	s, ok := v.(string)
@@ -193,7 +218,7 @@ func stringField(data map[string]any, name string) (string, error) {
 		return "", fmt.Errorf("unexpected type %T", v)
 	}
 	if s == "" {
-		return "", errors.New("empty field")
+		return "", errEmptyField
 	}
 
 	return s, nil
@@ -63,6 +63,8 @@ func (s *Storage) Delete(q upstream.Rule) ([]upstream.Rule, error) {
 			deleted = append(deleted, rule.upRecord)
 			if rule.cfRecord.ID != "" {
 				s.toDelete = append(s.toDelete, rule.cfRecord)
+			} else {
+				s.dropPending(rule)
 			}
 			continue
 		}
@@ -86,6 +88,16 @@ func (s *Storage) Append(r upstream.Rule) error {
 	return nil
 }
 
+// dropPending forgets the record appended in the same transaction, so it won't be created on commit.
+func (s *Storage) dropPending(rule Rule) {
+	for i, rec := range s.toAdd {
+		if rec.Name == rule.cfRecord.Name && rec.Type == rule.cfRecord.Type && rec.Content == rule.cfRecord.Content {
+			s.toAdd = append(s.toAdd[:i], s.toAdd[i+1:]...)
+			return
+		}
+	}
+}
+
 func (s *Storage) ToDelete() []cloudflare.DNSRecord {
 	return s.toDelete
 }
@@ -0,0 +1,44 @@
+package ucloudflare_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/ucloudflare"
+)
+
+func TestStorageDeletePending(t *testing.T) {
+	s, err := ucloudflare.NewCFStorage([]cloudflare.DNSRecord{
+		{ID: "id-1", Name: "a.example.com", Type: "A", Content: "192.0.2.1", TTL: 60},
+	})
+	require.NoError(t, err)
+
+	// the record appended and deleted in the same transaction must not be created on commit
+	require.NoError(t, s.Append(upstream.Rule{
+		Name:  "b.example.com.",
+		Type:  dns.TypeA,
+		Value: net.ParseIP("192.0.2.2"),
+	}))
+	deleted, err := s.Delete(upstream.Rule{
+		Name: "b.example.com.",
+		Type: dns.TypeA,
+	})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Empty(t, s.ToAdd())
+	require.Empty(t, s.ToDelete())
+
+	// the existing one is deleted by its ID
+	_, err = s.Delete(upstream.Rule{
+		Name: "a.example.com.",
+		Type: dns.TypeA,
+	})
+	require.NoError(t, err)
+	require.Len(t, s.ToDelete(), 1)
+	require.Equal(t, "id-1", s.ToDelete()[0].ID)
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/buglloc/DNSGateway/internal/upstream"
 )
 
-var _ upstream.Tx = (*Tx)(nil)
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
 
 type Tx struct {
 	cfc    *cloudflare.API
@@ -26,6 +29,18 @@ func (t *Tx) Delete(r upstream.Rule) error {
 }
 
 func (t *Tx) Append(r upstream.Rule) error {
+	if !r.Scope.IsZero() {
+		return upstream.ErrScopeUnsupported
+	}
+
+	if r.IsNegative() {
+		return upstream.ErrNegativeUnsupported
+	}
+
+	if !isSupportedRecordType(r.Type) {
+		return fmt.Errorf("%w: %s", errUnsupportedRecordType, upstream.TypeString(r.Type))
+	}
+
 	if r.ValueStr == "" {
 		// TODO(buglloc): fix me
 		r.ValueStr = fmt.Sprint(r.Value)
@@ -46,6 +61,28 @@ func (t *Tx) Commit(ctx context.Context) error {
 	return nil
 }
 
+// Diff returns planned Cloudflare API calls.
+func (t *Tx) Diff(_ context.Context) ([]string, error) {
+	var out []string
+	for _, rr := range t.store.ToDelete() {
+		out = append(out, fmt.Sprintf("DELETE %s %s %s (id %s)", rr.Name, strings.ToUpper(rr.Type), recordContent(rr), rr.ID))
+	}
+
+	for _, rr := range t.store.ToAdd() {
+		out = append(out, fmt.Sprintf("CREATE %s %s %s", rr.Name, strings.ToUpper(rr.Type), recordContent(rr)))
+	}
+
+	return out, nil
+}
+
+func recordContent(rr cloudflare.DNSRecord) string {
+	if rr.Data != nil {
+		return fmt.Sprintf("%s %v", rr.Content, rr.Data)
+	}
+
+	return rr.Content
+}
+
 func (t *Tx) processDeletes(ctx context.Context, recs []cloudflare.DNSRecord) error {
 	for _, rr := range recs {
 		if rr.ID == "" {
@@ -87,6 +124,7 @@ func (t *Tx) processAdds(ctx context.Context, recs []cloudflare.DNSRecord) error
 			TTL:      rr.TTL,
 			Proxied:  rr.Proxied,
 			Priority: rr.Priority,
+			Comment:  rr.Comment,
 		}
 
 		rsp, err := t.cfc.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(t.zoneID), record)
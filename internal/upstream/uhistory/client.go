@@ -0,0 +1,67 @@
+// Package uhistory saves the upstream record set into the history before every commit.
+package uhistory
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/history"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+type Upstream struct {
+	upc   upstream.Upstream
+	store *history.Store
+	log   zerolog.Logger
+}
+
+func NewUpstream(upc upstream.Upstream, store *history.Store) *Upstream {
+	return &Upstream{
+		upc:   upc,
+		store: store,
+		log: log.With().
+			Str("source", "history").
+			Str("upstream", store.Upstream()).
+			Logger(),
+	}
+}
+
+func (u *Upstream) Query(ctx context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	return u.upc.Query(ctx, q)
+}
+
+func (u *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	tx, err := u.upc.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		Tx:  tx,
+		upc: u,
+	}, nil
+}
+
+// snapshot saves the current record set. Failures are logged only: losing the history entry is better
+// than refusing the update.
+func (u *Upstream) snapshot(ctx context.Context) {
+	snap, err := history.NewSnapshot(ctx, u.upc)
+	if err != nil {
+		u.log.Error().Err(err).Msg("unable to snapshot records")
+		return
+	}
+
+	snap, saved, err := u.store.Save(snap)
+	if err != nil {
+		u.log.Error().Err(err).Msg("unable to save snapshot")
+		return
+	}
+
+	if saved {
+		log.Ctx(ctx).Info().Int("snapshot_id", snap.ID).Msg("records snapshot saved")
+	}
+}
@@ -0,0 +1,26 @@
+package uhistory
+
+import (
+	"context"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	upstream.Tx
+	upc *Upstream
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	t.upc.snapshot(ctx)
+	return t.Tx.Commit(ctx)
+}
+
+func (t *Tx) Diff(ctx context.Context) ([]string, error) {
+	return upstream.TxDiff(ctx, t.Tx)
+}
@@ -0,0 +1,83 @@
+package umemory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+var ErrConflict = errors.New("rules were changed by a concurrent transaction")
+
+// Upstream is a reference in-memory implementation of upstream.Upstream.
+// Transactions are isolated snapshots, Commit fails with ErrConflict if another transaction was committed in between.
+type Upstream struct {
+	mu      sync.Mutex
+	rules   []upstream.Rule
+	version uint64
+}
+
+func NewUpstream(rules ...upstream.Rule) *Upstream {
+	return &Upstream{
+		rules: rules,
+	}
+}
+
+func (c *Upstream) Query(_ context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return queryRules(c.rules, q), nil
+}
+
+func (c *Upstream) Tx(_ context.Context) (upstream.Tx, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rules := make([]upstream.Rule, len(c.rules))
+	copy(rules, c.rules)
+	return &Tx{
+		upc:     c,
+		origin:  c.rules,
+		rules:   rules,
+		version: c.version,
+	}, nil
+}
+
+func (c *Upstream) Rules() []upstream.Rule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]upstream.Rule, len(c.rules))
+	copy(out, c.rules)
+	return out
+}
+
+func (c *Upstream) commit(version uint64, rules []upstream.Rule) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != version {
+		return ErrConflict
+	}
+
+	c.rules = rules
+	c.version++
+	return nil
+}
+
+func queryRules(rules []upstream.Rule, q upstream.Rule) []upstream.Rule {
+	var out []upstream.Rule
+	for _, rule := range rules {
+		if !rule.Same(&q) {
+			continue
+		}
+
+		out = append(out, rule)
+	}
+
+	return out
+}
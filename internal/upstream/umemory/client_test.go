@@ -0,0 +1,25 @@
+package umemory_test
+
+import (
+	"testing"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+)
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, newTestUpstream)
+}
+
+func TestConformanceTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, upstreamtest.ExtendedTypes...)
+}
+
+func TestConformanceOpaqueTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, upstreamtest.OpaqueTypes...)
+}
+
+func newTestUpstream(_ *testing.T) upstream.Upstream {
+	return umemory.NewUpstream()
+}
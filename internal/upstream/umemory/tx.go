@@ -0,0 +1,70 @@
+package umemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	upc     *Upstream
+	origin  []upstream.Rule
+	rules   []upstream.Rule
+	version uint64
+	changed bool
+}
+
+func (t *Tx) Delete(r upstream.Rule) error {
+	n := 0
+	for _, rule := range t.rules {
+		if rule.Same(&r) {
+			t.changed = true
+			continue
+		}
+
+		t.rules[n] = rule
+		n++
+	}
+
+	t.rules = t.rules[:n]
+	return nil
+}
+
+func (t *Tx) Append(r upstream.Rule) error {
+	if r.ValueStr == "" {
+		r.ValueStr = fmt.Sprint(r.Value)
+	}
+
+	t.changed = true
+	t.rules = append(t.rules, r)
+	return nil
+}
+
+func (t *Tx) Commit(_ context.Context) error {
+	if !t.changed {
+		return nil
+	}
+
+	return t.upc.commit(t.version, t.rules)
+}
+
+func (t *Tx) Diff(_ context.Context) ([]string, error) {
+	return upstream.LinesDiff(ruleLines(t.origin), ruleLines(t.rules)), nil
+}
+
+func (t *Tx) Close() {}
+
+func ruleLines(rules []upstream.Rule) []string {
+	out := make([]string, len(rules))
+	for i, r := range rules {
+		out[i] = r.String()
+	}
+
+	return out
+}
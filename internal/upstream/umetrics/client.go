@@ -0,0 +1,66 @@
+// Package umetrics reports latency and errors of any upstream to Prometheus.
+package umetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+const (
+	OpQuery  = "query"
+	OpFetch  = "fetch"
+	OpCommit = "commit"
+)
+
+type Upstream struct {
+	upc     upstream.Upstream
+	backend string
+}
+
+// NewUpstream wraps the upstream, backend is used as the metrics label.
+func NewUpstream(upc upstream.Upstream, backend string) *Upstream {
+	return &Upstream{
+		upc:     upc,
+		backend: backend,
+	}
+}
+
+func (u *Upstream) Query(ctx context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	defer u.observe(OpQuery, time.Now())
+
+	out, err := u.upc.Query(ctx, q)
+	if err != nil {
+		u.fail(OpQuery)
+	}
+
+	return out, err
+}
+
+// Tx starts the upstream transaction, which is the place where most upstreams fetch the current state.
+func (u *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	start := time.Now()
+	tx, err := u.upc.Tx(ctx)
+	u.observe(OpFetch, start)
+	if err != nil {
+		u.fail(OpFetch)
+		return nil, err
+	}
+
+	return &Tx{
+		Tx:  tx,
+		upc: u,
+	}, nil
+}
+
+func (u *Upstream) observe(op string, start time.Time) {
+	metrics.UpstreamDuration.WithLabelValues(u.backend, op).Observe(time.Since(start).Seconds())
+}
+
+func (u *Upstream) fail(op string) {
+	metrics.UpstreamErrors.WithLabelValues(u.backend, op).Inc()
+}
@@ -0,0 +1,88 @@
+package umetrics_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/metrics"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+	"github.com/buglloc/DNSGateway/internal/upstream/umetrics"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+)
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, func(_ *testing.T) upstream.Upstream {
+		return umetrics.NewUpstream(umemory.NewUpstream(), "conformance")
+	})
+}
+
+func TestLatency(t *testing.T) {
+	ctx := context.Background()
+	u := umetrics.NewUpstream(umemory.NewUpstream(), "latency")
+
+	tx, err := u.Tx(ctx)
+	require.NoError(t, err)
+	defer tx.Close()
+
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:  "a.example.com.",
+		Type:  dns.TypeA,
+		Value: net.ParseIP("1.2.3.4"),
+	}))
+
+	diff, err := upstream.TxDiff(ctx, tx)
+	require.NoError(t, err)
+	require.Len(t, diff, 1)
+
+	require.NoError(t, tx.Commit(ctx))
+
+	_, err = u.Query(ctx, upstream.Rule{Name: "a.example.com.", Type: dns.TypeA})
+	require.NoError(t, err)
+
+	for _, op := range []string{umetrics.OpFetch, umetrics.OpCommit, umetrics.OpQuery} {
+		require.Equal(t, uint64(1), sampleCount(t, "latency", op), op)
+		require.Zero(t, testutil.ToFloat64(metrics.UpstreamErrors.WithLabelValues("latency", op)), op)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	u := umetrics.NewUpstream(brokenUpstream{}, "broken")
+
+	_, err := u.Tx(ctx)
+	require.Error(t, err)
+
+	_, err = u.Query(ctx, upstream.Rule{Name: "a.example.com."})
+	require.Error(t, err)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.UpstreamErrors.WithLabelValues("broken", umetrics.OpFetch)))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.UpstreamErrors.WithLabelValues("broken", umetrics.OpQuery)))
+	require.Equal(t, uint64(1), sampleCount(t, "broken", umetrics.OpFetch))
+}
+
+type brokenUpstream struct{}
+
+func (brokenUpstream) Tx(_ context.Context) (upstream.Tx, error) {
+	return nil, errors.New("broken")
+}
+
+func (brokenUpstream) Query(_ context.Context, _ upstream.Rule) ([]upstream.Rule, error) {
+	return nil, errors.New("broken")
+}
+
+func sampleCount(t *testing.T, backend, op string) uint64 {
+	var m dto.Metric
+	err := metrics.UpstreamDuration.WithLabelValues(backend, op).(prometheus.Metric).Write(&m)
+	require.NoError(t, err)
+
+	return m.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,33 @@
+package umetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	upstream.Tx
+	upc *Upstream
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	defer t.upc.observe(OpCommit, time.Now())
+
+	err := t.Tx.Commit(ctx)
+	if err != nil {
+		t.upc.fail(OpCommit)
+	}
+
+	return err
+}
+
+func (t *Tx) Diff(ctx context.Context) ([]string, error) {
+	return upstream.TxDiff(ctx, t.Tx)
+}
@@ -0,0 +1,384 @@
+// Package upstreamtest provides a conformance suite for upstream.Upstream implementations.
+package upstreamtest
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+// NewUpstreamFn must return a fresh upstream without any managed rules.
+type NewUpstreamFn func(t *testing.T) upstream.Upstream
+
+// Run checks that the upstream implements upstream.Upstream and upstream.Tx semantics
+// the listener relies on.
+func Run(t *testing.T, newUpstream NewUpstreamFn) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, u upstream.Upstream)
+	}{
+		{"AppendQuery", testAppendQuery},
+		{"DeleteRRset", testDeleteRRset},
+		{"DeleteName", testDeleteName},
+		{"DeleteRR", testDeleteRR},
+		{"TXTChunks", testTXTChunks},
+		{"MarkerTXT", testMarkerTXT},
+		{"AXFR", testAXFR},
+		{"DeleteThenAppend", testDeleteThenAppend},
+		{"AppendThenDelete", testAppendThenDelete},
+		{"NoCommit", testNoCommit},
+		{"Owner", testOwner},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newUpstream(t))
+		})
+	}
+}
+
+// TypeSamples are sample rules of the record types beyond the basic ones, keyed by type.
+var TypeSamples = map[upstream.RType][2]string{
+	dns.TypeNS:    {"sub.example.com.", "ns1.example.net."},
+	dns.TypeCAA:   {"example.com.", `0 issue "letsencrypt.org"`},
+	dns.TypeSSHFP: {"host.example.com.", "4 2 123456789ABCDEF67890123456789ABCDEF67890123456789ABCDEF123456789A"},
+	dns.TypeTLSA:  {"_443._tcp.example.com.", "3 1 1 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+	dns.TypeHTTPS: {"example.com.", `1 . alpn="h3,h2" ipv4hint="1.2.3.4"`},
+	dns.TypeSVCB:  {"_dns.example.com.", `1 dns.example.com. alpn="dot" port="853"`},
+	dns.TypeNAPTR: {"example.com.", `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .`},
+	dns.TypeDS:    {"sub.example.com.", "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118"},
+	dns.TypeURI:   {"_http._tcp.example.com.", `10 1 "https://example.com/"`},
+	privateType:   {"opaque.example.com.", `\# 4 0a000001`},
+}
+
+// privateType is a record type from the private use range, which nobody knows.
+const privateType upstream.RType = 65280
+
+// ExtendedTypes lists all types from TypeSamples.
+var ExtendedTypes = []upstream.RType{
+	dns.TypeNS, dns.TypeCAA, dns.TypeSSHFP, dns.TypeTLSA, dns.TypeHTTPS, dns.TypeSVCB, dns.TypeNAPTR, dns.TypeDS,
+}
+
+// OpaqueTypes lists types from TypeSamples the gateway doesn't know, so they are kept as RFC 3597 generic rdata.
+var OpaqueTypes = []upstream.RType{
+	dns.TypeURI, privateType,
+}
+
+// RunTypes checks that rules of the given record types (see TypeSamples) round-trip through the upstream.
+func RunTypes(t *testing.T, newUpstream NewUpstreamFn, types ...upstream.RType) {
+	for _, typ := range types {
+		t.Run(upstream.TypeString(typ), func(t *testing.T) {
+			sample, ok := TypeSamples[typ]
+			require.True(t, ok, "no sample for type")
+
+			u := newUpstream(t)
+			rule := mustRule(t, sample[0], typ, sample[1])
+			seed(t, u, rule)
+
+			requireRules(t, []upstream.Rule{rule}, query(t, u, upstream.Rule{
+				Name: rule.Name,
+				Type: typ,
+			}))
+
+			// value must match the one read back from the upstream
+			apply(t, u, func(tx upstream.Tx) {
+				require.NoError(t, tx.Delete(mustRule(t, sample[0], typ, sample[1])))
+			})
+			require.Empty(t, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+		})
+	}
+}
+
+// RunRefusedTypes checks that the upstream refuses rules of the given types (see TypeSamples) with a per-type error.
+func RunRefusedTypes(t *testing.T, newUpstream NewUpstreamFn, types ...upstream.RType) {
+	for _, typ := range types {
+		t.Run(upstream.TypeString(typ), func(t *testing.T) {
+			sample, ok := TypeSamples[typ]
+			require.True(t, ok, "no sample for type")
+
+			u := newUpstream(t)
+			tx, err := u.Tx(context.Background())
+			require.NoError(t, err)
+			defer tx.Close()
+
+			err = tx.Append(mustRule(t, sample[0], typ, sample[1]))
+			require.ErrorContains(t, err, upstream.TypeString(typ))
+		})
+	}
+}
+
+func testAppendQuery(t *testing.T, u upstream.Upstream) {
+	rules := []upstream.Rule{
+		mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4"),
+		mustRule(t, "a.example.com.", dns.TypeAAAA, "2001:db8::1"),
+		mustRule(t, "cname.example.com.", dns.TypeCNAME, "a.example.com."),
+		mustRule(t, "example.com.", dns.TypeMX, "10 mail.example.com."),
+		mustRule(t, "txt.example.com.", dns.TypeTXT, "v=spf1 -all"),
+		mustRule(t, "_sip._tcp.example.com.", dns.TypeSRV, "10 5 5060 sip.example.com."),
+		mustRule(t, "4.3.2.1.in-addr.arpa.", dns.TypePTR, "a.example.com."),
+	}
+	apply(t, u, func(tx upstream.Tx) {
+		for _, r := range rules {
+			require.NoError(t, tx.Append(r))
+		}
+	})
+
+	for _, r := range rules {
+		actual := query(t, u, upstream.Rule{
+			Name: r.Name,
+			Type: r.Type,
+		})
+		requireRules(t, []upstream.Rule{r}, actual)
+	}
+
+	actual := query(t, u, upstream.Rule{
+		Name: "a.example.com.",
+		Type: dns.TypeNone,
+	})
+	requireRules(t, rules[:2], actual)
+
+	actual = query(t, u, upstream.Rule{
+		Name: "nope.example.com.",
+		Type: dns.TypeA,
+	})
+	require.Empty(t, actual)
+}
+
+func testDeleteRRset(t *testing.T, u upstream.Upstream) {
+	a1 := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4")
+	a2 := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.5")
+	txt := mustRule(t, "a.example.com.", dns.TypeTXT, "lol")
+	seed(t, u, a1, a2, txt)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name: "a.example.com.",
+			Type: dns.TypeA,
+		}))
+	})
+
+	requireRules(t, []upstream.Rule{txt}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+func testDeleteName(t *testing.T, u upstream.Upstream) {
+	a := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4")
+	txt := mustRule(t, "a.example.com.", dns.TypeTXT, "lol")
+	other := mustRule(t, "b.example.com.", dns.TypeA, "1.2.3.4")
+	seed(t, u, a, txt, other)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name: "a.example.com.",
+			Type: dns.TypeNone,
+		}))
+	})
+
+	requireRules(t, []upstream.Rule{other}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+func testDeleteRR(t *testing.T, u upstream.Upstream) {
+	a1 := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4")
+	a2 := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.5")
+	seed(t, u, a1, a2)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name:     "a.example.com.",
+			Type:     dns.TypeA,
+			ValueStr: "1.2.3.4",
+		}))
+	})
+
+	requireRules(t, []upstream.Rule{a2}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+func testTXTChunks(t *testing.T, u upstream.Upstream) {
+	foo := mustRule(t, "txt.example.com.", dns.TypeTXT, "foo")
+	bar := mustRule(t, "txt.example.com.", dns.TypeTXT, "bar")
+	seed(t, u, foo, bar)
+
+	// same joined value but different chunks must not match
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name:     "txt.example.com.",
+			Type:     dns.TypeTXT,
+			Value:    []string{"fo", "o"},
+			ValueStr: "foo",
+		}))
+	})
+	requireRules(t, []upstream.Rule{foo, bar}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name:     "txt.example.com.",
+			Type:     dns.TypeTXT,
+			Value:    []string{"foo"},
+			ValueStr: "foo",
+		}))
+	})
+	requireRules(t, []upstream.Rule{bar}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+// testMarkerTXT checks that plain TXT records looking like negative markers are kept as is.
+func testMarkerTXT(t *testing.T, u upstream.Upstream) {
+	marker := mustRule(t, "marker.example.com.", dns.TypeTXT, upstream.NegativeMarkerPrefix+"nxdomain")
+	require.False(t, marker.IsNegative())
+	seed(t, u, marker)
+
+	require.Empty(t, query(t, u, upstream.Rule{Name: "marker.example.com.", Type: dns.TypeA}))
+
+	actual := query(t, u, upstream.Rule{Name: "marker.example.com.", Type: dns.TypeTXT})
+	require.Len(t, actual, 1)
+	require.False(t, actual[0].IsNegative())
+	requireRules(t, []upstream.Rule{marker}, actual)
+}
+
+func testAXFR(t *testing.T, u upstream.Upstream) {
+	inZone := []upstream.Rule{
+		mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4"),
+		mustRule(t, "*.b.example.com.", dns.TypeA, "1.2.3.5"),
+		mustRule(t, "example.com.", dns.TypeTXT, "lol"),
+	}
+	other := mustRule(t, "a.example.net.", dns.TypeA, "1.2.3.4")
+	seed(t, u, append([]upstream.Rule{other}, inZone...)...)
+
+	requireRules(t, inZone, query(t, u, upstream.Rule{
+		Name: "example.com.",
+		Type: dns.TypeAXFR,
+	}))
+
+	requireRules(t, append([]upstream.Rule{other}, inZone...), query(t, u, upstream.Rule{
+		Type: dns.TypeAXFR,
+	}))
+}
+
+func testDeleteThenAppend(t *testing.T, u upstream.Upstream) {
+	seed(t, u, mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4"))
+
+	expected := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.5")
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name: "a.example.com.",
+			Type: dns.TypeA,
+		}))
+		require.NoError(t, tx.Append(expected))
+	})
+
+	requireRules(t, []upstream.Rule{expected}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+func testAppendThenDelete(t *testing.T, u upstream.Upstream) {
+	expected := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4")
+	seed(t, u, expected)
+
+	apply(t, u, func(tx upstream.Tx) {
+		require.NoError(t, tx.Append(mustRule(t, "b.example.com.", dns.TypeA, "1.2.3.5")))
+		require.NoError(t, tx.Delete(upstream.Rule{
+			Name: "b.example.com.",
+			Type: dns.TypeA,
+		}))
+	})
+
+	requireRules(t, []upstream.Rule{expected}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+func testNoCommit(t *testing.T, u upstream.Upstream) {
+	expected := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4")
+	seed(t, u, expected)
+
+	tx, err := u.Tx(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{
+		Name: "a.example.com.",
+		Type: dns.TypeNone,
+	}))
+	require.NoError(t, tx.Append(mustRule(t, "b.example.com.", dns.TypeA, "1.2.3.5")))
+	tx.Close()
+
+	requireRules(t, []upstream.Rule{expected}, query(t, u, upstream.Rule{Type: dns.TypeAXFR}))
+}
+
+func testOwner(t *testing.T, u upstream.Upstream) {
+	owned := mustRule(t, "a.example.com.", dns.TypeA, "1.2.3.4")
+	owned.Owner = "acme."
+	other := mustRule(t, "b.example.com.", dns.TypeA, "1.2.3.5")
+	other.Owner = "other."
+	seed(t, u, owned, other)
+
+	actual := query(t, u, upstream.Rule{Name: "a.example.com.", Type: dns.TypeA})
+	require.Len(t, actual, 1)
+	require.Equal(t, "acme.", actual[0].Owner)
+	require.True(t, actual[0].IsOwnedBy("acme."))
+
+	actual = query(t, u, upstream.Rule{Name: "b.example.com.", Type: dns.TypeA})
+	require.Len(t, actual, 1)
+	require.Equal(t, "other.", actual[0].Owner)
+}
+
+func mustRule(t *testing.T, name string, typ upstream.RType, value string) upstream.Rule {
+	t.Helper()
+
+	rule, err := upstream.NewRule(name, typ, value)
+	require.NoError(t, err)
+	return rule
+}
+
+func seed(t *testing.T, u upstream.Upstream, rules ...upstream.Rule) {
+	t.Helper()
+
+	apply(t, u, func(tx upstream.Tx) {
+		for _, r := range rules {
+			require.NoError(t, tx.Append(r))
+		}
+	})
+}
+
+func apply(t *testing.T, u upstream.Upstream, fn func(tx upstream.Tx)) {
+	t.Helper()
+
+	ctx := context.Background()
+	tx, err := u.Tx(ctx)
+	require.NoError(t, err)
+	defer tx.Close()
+
+	fn(tx)
+	require.NoError(t, tx.Commit(ctx))
+}
+
+func query(t *testing.T, u upstream.Upstream, q upstream.Rule) []upstream.Rule {
+	t.Helper()
+
+	out, err := u.Query(context.Background(), q)
+	require.NoError(t, err)
+	return out
+}
+
+func requireRules(t *testing.T, expected, actual []upstream.Rule) {
+	t.Helper()
+
+	require.Equal(t, canonicalRules(t, expected), canonicalRules(t, actual))
+}
+
+// canonicalRules formats rules as sorted zone file lines, so backends may differ in value representation
+// (e.g. trailing dots or net.IP length) but not in the served records.
+func canonicalRules(t *testing.T, rules []upstream.Rule) []string {
+	t.Helper()
+
+	out := make([]string, len(rules))
+	for i, r := range rules {
+		rr, err := r.RR()
+		require.NoError(t, err)
+
+		canonical, err := dns.NewRR(rr.String())
+		require.NoError(t, err)
+		out[i] = canonical.String()
+	}
+
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,158 @@
+package usqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+type Upstream struct {
+	db   *sql.DB
+	path string
+	log  zerolog.Logger
+}
+
+func NewUpstream(opts ...Option) (*Upstream, error) {
+	client := &Upstream{
+		log: log.With().
+			Str("source", "sqlite-upstream").
+			Logger(),
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.path == "" {
+		return nil, errors.New("no database path configured, use WithPath()")
+	}
+
+	db, err := sql.Open("sqlite", dsn(client.path))
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	client.db = db
+	return client, nil
+}
+
+func (c *Upstream) Query(ctx context.Context, r upstream.Rule) ([]upstream.Rule, error) {
+	records, err := queryRecords(ctx, c.db, r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]upstream.Rule, len(records))
+	for i, rec := range records {
+		out[i] = rec.rule
+	}
+	return out, nil
+}
+
+func (c *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	return &Tx{
+		ctx: ctx,
+		tx:  tx,
+		log: c.log,
+	}, nil
+}
+
+func (c *Upstream) Close() error {
+	return c.db.Close()
+}
+
+type record struct {
+	id   int64
+	rule upstream.Rule
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryRecords(ctx context.Context, db querier, q upstream.Rule) ([]record, error) {
+	query := "SELECT id, rr, owner, negative FROM records"
+	var args []any
+	if q.Type != dns.TypeAXFR && q.Name != "" {
+		// everything else is filtered by upstream.Rule.Same below
+		query += " WHERE name = ?"
+		args = append(args, q.Name)
+	}
+	query += " ORDER BY id"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []record
+	for rows.Next() {
+		var id int64
+		var rrStr, owner string
+		var negative bool
+		if err := rows.Scan(&id, &rrStr, &owner, &negative); err != nil {
+			return nil, fmt.Errorf("scan record: %w", err)
+		}
+
+		rule, err := ruleFromRRString(rrStr, negative)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record %d: %w", id, err)
+		}
+		rule.Owner = owner
+
+		if !rule.Same(&q) {
+			continue
+		}
+
+		out = append(out, record{
+			id:   id,
+			rule: rule,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate records: %w", err)
+	}
+
+	return out, nil
+}
+
+func ruleFromRRString(s string, negative bool) (upstream.Rule, error) {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		return upstream.Rule{}, fmt.Errorf("parse rr: %w", err)
+	}
+
+	return upstream.RuleFromStoredRR(rr, negative)
+}
+
+func dsn(path string) string {
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Set("_txlock", "immediate")
+	return "file:" + path + "?" + q.Encode()
+}
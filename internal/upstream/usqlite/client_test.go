@@ -0,0 +1,116 @@
+package usqlite_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+	"github.com/buglloc/DNSGateway/internal/upstream/usqlite"
+)
+
+func TestTx(t *testing.T) {
+	c, err := usqlite.NewUpstream(
+		usqlite.WithPath(filepath.Join(t.TempDir(), "records.db")),
+	)
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	ctx := context.Background()
+	tx, err := c.Tx(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.3"),
+		ValueStr: "1.2.3.3",
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "txt.ya.ru.",
+		Type:     dns.TypeTXT,
+		Value:    []string{"foo", "bar"},
+		ValueStr: "foobar",
+	}))
+	require.NoError(t, tx.Commit(ctx))
+	tx.Close()
+
+	rr, err := c.Query(ctx, upstream.Rule{
+		Name: "ya.ru.",
+		Type: dns.TypeA,
+	})
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	require.Equal(t, "1.2.3.3", rr[0].ValueStr)
+
+	rr, err = c.Query(ctx, upstream.Rule{
+		Name: "txt.ya.ru.",
+		Type: dns.TypeTXT,
+	})
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	require.Equal(t, []string{"foo", "bar"}, rr[0].Value)
+
+	// not committed changes must be rolled back on Close
+	tx, err = c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{
+		Name: "ya.ru.",
+		Type: dns.TypeNone,
+	}))
+	tx.Close()
+
+	rr, err = c.Query(ctx, upstream.Rule{Type: dns.TypeAXFR})
+	require.NoError(t, err)
+	require.Len(t, rr, 2)
+
+	tx, err = c.Tx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(upstream.Rule{
+		Name: "ya.ru.",
+		Type: dns.TypeA,
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.4.5"),
+		ValueStr: "1.2.4.5",
+	}))
+	require.NoError(t, tx.Commit(ctx))
+	tx.Close()
+
+	rr, err = c.Query(ctx, upstream.Rule{
+		Name: "ya.ru.",
+		Type: dns.TypeA,
+	})
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	require.Equal(t, "1.2.4.5", rr[0].ValueStr)
+}
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, newTestUpstream)
+}
+
+func TestConformanceTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, upstreamtest.ExtendedTypes...)
+}
+
+func TestConformanceOpaqueTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, upstreamtest.OpaqueTypes...)
+}
+
+func newTestUpstream(t *testing.T) upstream.Upstream {
+	c, err := usqlite.NewUpstream(
+		usqlite.WithPath(filepath.Join(t.TempDir(), "records.db")),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
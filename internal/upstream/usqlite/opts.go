@@ -0,0 +1,9 @@
+package usqlite
+
+type Option func(*Upstream)
+
+func WithPath(path string) Option {
+	return func(client *Upstream) {
+		client.path = path
+	}
+}
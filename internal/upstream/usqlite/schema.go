@@ -0,0 +1,15 @@
+package usqlite
+
+const schema = `
+CREATE TABLE IF NOT EXISTS records (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	name     TEXT    NOT NULL,
+	type     INTEGER NOT NULL,
+	value    TEXT    NOT NULL,
+	rr       TEXT    NOT NULL,
+	owner    TEXT    NOT NULL DEFAULT '',
+	negative INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS records_name_type ON records (name, type);
+`
@@ -0,0 +1,84 @@
+package usqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	ctx  context.Context
+	tx   *sql.Tx
+	diff []string
+	log  zerolog.Logger
+}
+
+func (t *Tx) Delete(r upstream.Rule) error {
+	records, err := queryRecords(t.ctx, t.tx, r)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		if _, err := t.tx.ExecContext(t.ctx, "DELETE FROM records WHERE id = ?", rec.id); err != nil {
+			return fmt.Errorf("delete record %d: %w", rec.id, err)
+		}
+
+		t.diff = append(t.diff, "- "+rec.rule.String())
+	}
+
+	return nil
+}
+
+func (t *Tx) Append(r upstream.Rule) error {
+	if !r.Scope.IsZero() {
+		return upstream.ErrScopeUnsupported
+	}
+
+	rr, err := r.RR()
+	if err != nil {
+		return fmt.Errorf("generate rr: %w", err)
+	}
+
+	// normalize value the same way the rule is read back
+	rule, err := upstream.RuleFromStoredRR(rr, r.IsNegative())
+	if err != nil {
+		return err
+	}
+
+	_, err = t.tx.ExecContext(t.ctx,
+		"INSERT INTO records (name, type, value, rr, owner, negative) VALUES (?, ?, ?, ?, ?, ?)",
+		rule.Name, rule.Type, rule.ValueStr, rr.String(), r.Owner, rule.IsNegative(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert record: %w", err)
+	}
+
+	t.diff = append(t.diff, "+ "+rule.String())
+	return nil
+}
+
+// Diff returns records deleted and inserted within the transaction, in order.
+func (t *Tx) Diff(_ context.Context) ([]string, error) {
+	return t.diff, nil
+}
+
+func (t *Tx) Commit(_ context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Close() {
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		t.log.Error().Err(err).Msg("rollback failed")
+	}
+}
@@ -0,0 +1,53 @@
+// Package utracing wraps any upstream with OpenTelemetry spans.
+package utracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/buglloc/DNSGateway/internal/tracing"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+type Upstream struct {
+	upc     upstream.Upstream
+	backend attribute.KeyValue
+}
+
+// NewUpstream wraps the upstream, backend is recorded as the "upstream.backend" span attribute.
+func NewUpstream(upc upstream.Upstream, backend string) *Upstream {
+	return &Upstream{
+		upc:     upc,
+		backend: attribute.String("upstream.backend", backend),
+	}
+}
+
+func (u *Upstream) Query(ctx context.Context, q upstream.Rule) ([]upstream.Rule, error) {
+	ctx, span := tracing.Start(ctx, "upstream.query",
+		u.backend,
+		attribute.String("dns.name", q.Name),
+		attribute.String("dns.type", upstream.TypeString(q.Type)),
+	)
+
+	out, err := u.upc.Query(ctx, q)
+	span.SetAttributes(attribute.Int("upstream.records", len(out)))
+	tracing.End(span, err)
+	return out, err
+}
+
+func (u *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	ctx, span := tracing.Start(ctx, "upstream.tx", u.backend)
+	tx, err := u.upc.Tx(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		Tx:  tx,
+		upc: u,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package utracing_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/buglloc/DNSGateway/internal/tracing"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/umemory"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+	"github.com/buglloc/DNSGateway/internal/upstream/utracing"
+)
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, func(_ *testing.T) upstream.Upstream {
+		return utracing.NewUpstream(umemory.NewUpstream(), "conformance")
+	})
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, root := tracing.Start(context.Background(), "request")
+	u := utracing.NewUpstream(umemory.NewUpstream(), "memory")
+
+	tx, err := u.Tx(ctx)
+	require.NoError(t, err)
+	defer tx.Close()
+
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:  "a.example.com.",
+		Type:  dns.TypeA,
+		Value: net.ParseIP("1.2.3.4"),
+	}))
+	require.NoError(t, tx.Commit(ctx))
+
+	_, err = u.Query(ctx, upstream.Rule{Name: "a.example.com.", Type: dns.TypeA})
+	require.NoError(t, err)
+
+	_, err = utracing.NewUpstream(brokenUpstream{}, "broken").Tx(ctx)
+	require.Error(t, err)
+	root.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 5)
+
+	names := make([]string, 0, len(spans))
+	for _, span := range spans[:4] {
+		names = append(names, span.Name())
+		require.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID())
+		require.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	require.Equal(t, []string{"upstream.tx", "upstream.commit", "upstream.query", "upstream.tx"}, names)
+	require.Equal(t, codes.Unset, spans[0].Status().Code)
+	require.Equal(t, codes.Error, spans[3].Status().Code)
+}
+
+type brokenUpstream struct{}
+
+func (brokenUpstream) Tx(_ context.Context) (upstream.Tx, error) {
+	return nil, errors.New("broken")
+}
+
+func (brokenUpstream) Query(_ context.Context, _ upstream.Rule) ([]upstream.Rule, error) {
+	return nil, errors.New("broken")
+}
@@ -0,0 +1,29 @@
+package utracing
+
+import (
+	"context"
+
+	"github.com/buglloc/DNSGateway/internal/tracing"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	upstream.Tx
+	upc *Upstream
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "upstream.commit", t.upc.backend)
+	err := t.Tx.Commit(ctx)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *Tx) Diff(ctx context.Context) ([]string, error) {
+	return upstream.TxDiff(ctx, t.Tx)
+}
@@ -0,0 +1,160 @@
+package uwebhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+const (
+	DefaultRetries      = 3
+	DefaultRetryWait    = 500 * time.Millisecond
+	DefaultRetryMaxWait = 5 * time.Second
+	DefaultTimeout      = 1 * time.Minute
+	DefaultTTL          = 300
+)
+
+var _ upstream.Upstream = (*Upstream)(nil)
+
+type Upstream struct {
+	httpc      *resty.Client
+	listURL    string
+	commitURL  string
+	hmacSecret []byte
+	ttl        uint32
+	log        zerolog.Logger
+}
+
+func NewUpstream(opts ...Option) (*Upstream, error) {
+	return NewUpstreamWithHTTP(xhttp.NewHTTPClient(), opts...)
+}
+
+func NewUpstreamWithHTTP(httpc *http.Client, opts ...Option) (*Upstream, error) {
+	client := &Upstream{
+		httpc: resty.NewWithClient(httpc).
+			SetHeader("User-Agent", "DNSGateway").
+			SetHeader("Content-Type", "application/json").
+			SetRetryCount(DefaultRetries).
+			SetRetryWaitTime(DefaultRetryWait).
+			SetRetryMaxWaitTime(DefaultRetryMaxWait).
+			AddRetryCondition(shouldRetry).
+			SetTimeout(DefaultTimeout),
+		ttl: DefaultTTL,
+		log: log.With().
+			Str("source", "webhook-upstream").
+			Logger(),
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.listURL == "" {
+		return nil, errors.New("no list url configured, use WithListURL()")
+	}
+
+	if client.commitURL == "" {
+		return nil, errors.New("no commit url configured, use WithCommitURL()")
+	}
+
+	return client, nil
+}
+
+func (c *Upstream) Query(ctx context.Context, r upstream.Rule) ([]upstream.Rule, error) {
+	s, err := c.fetchRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Query(r), nil
+}
+
+func (c *Upstream) Tx(ctx context.Context) (upstream.Tx, error) {
+	s, err := c.fetchRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		upc:   c,
+		store: s,
+	}, nil
+}
+
+func (c *Upstream) fetchRules(ctx context.Context) (*Storage, error) {
+	var rsp ListRsp
+	var errRsp ErrorRsp
+	httpRsp, err := c.signRequest(c.httpc.R(), nil).
+		SetContext(ctx).
+		SetResult(&rsp).
+		SetError(&errRsp).
+		Get(c.listURL)
+	if err != nil {
+		return nil, fmt.Errorf("make http request: %w", err)
+	}
+
+	if httpRsp.IsError() {
+		return nil, fmt.Errorf("non-200 response: %s", errorMessage(httpRsp, errRsp))
+	}
+
+	return NewStorage(rsp.Records)
+}
+
+func (c *Upstream) commit(ctx context.Context, changes ChangeSetReq) error {
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("marshal change-set: %w", err)
+	}
+
+	var errRsp ErrorRsp
+	httpRsp, err := c.signRequest(c.httpc.R(), body).
+		SetContext(ctx).
+		SetHeader(HeaderIdempotencyKey, newIdempotencyKey()).
+		SetBody(body).
+		SetError(&errRsp).
+		Post(c.commitURL)
+	if err != nil {
+		return fmt.Errorf("make http request: %w", err)
+	}
+
+	if httpRsp.IsError() {
+		return fmt.Errorf("non-200 response: %s", errorMessage(httpRsp, errRsp))
+	}
+
+	return nil
+}
+
+func shouldRetry(rsp *resty.Response, err error) bool {
+	if err != nil || rsp == nil {
+		return true
+	}
+
+	code := rsp.StatusCode()
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+func newIdempotencyKey() string {
+	var key [16]byte
+	_, _ = rand.Read(key[:])
+	return hex.EncodeToString(key[:])
+}
+
+func errorMessage(rsp *resty.Response, errRsp ErrorRsp) string {
+	if errRsp.Message != "" {
+		return errRsp.Message
+	}
+
+	return string(rsp.Body())
+}
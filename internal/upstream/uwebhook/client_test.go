@@ -0,0 +1,244 @@
+package uwebhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+	"github.com/buglloc/DNSGateway/internal/upstream/upstreamtest"
+	"github.com/buglloc/DNSGateway/internal/upstream/uwebhook"
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+const testSecret = "some-long-secret"
+
+func TestSrvMock(t *testing.T) {
+	var mux http.ServeMux
+	var mu sync.Mutex
+	records := []uwebhook.Record{
+		{Name: "ya.ru.", Type: "A", Value: "1.2.3.3", TTL: 60},
+		{Name: "txt.ya.ru.", Type: "TXT", Value: "lol", TTL: 60},
+	}
+	var changes []uwebhook.ChangeSetReq
+	var idempotencyKeys []string
+	failures := 1
+
+	checkSign := func(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+
+		ts := r.Header.Get(xhttp.HeaderTimestamp)
+		expected := xhttp.Sign([]byte(testSecret), ts, body)
+		if ts == "" || r.Header.Get(xhttp.HeaderSignature) != expected {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return nil, false
+		}
+
+		return body, true
+	}
+
+	mux.HandleFunc("/records", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET expected", http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := checkSign(w, r); !ok {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(uwebhook.ListRsp{
+			Records: records,
+		})
+	})
+
+	mux.HandleFunc("/commit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST expected", http.StatusBadRequest)
+			return
+		}
+
+		body, ok := checkSign(w, r)
+		if !ok {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		idempotencyKeys = append(idempotencyKeys, r.Header.Get(uwebhook.HeaderIdempotencyKey))
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req uwebhook.ChangeSetReq
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		changes = append(changes, req)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := httptest.NewServer(&mux)
+	defer srv.Close()
+
+	c, err := uwebhook.NewUpstream(
+		uwebhook.WithListURL(srv.URL+"/records"),
+		uwebhook.WithCommitURL(srv.URL+"/commit"),
+		uwebhook.WithHMACSecret(testSecret),
+		uwebhook.WithTTL(120),
+		uwebhook.WithRetries(2, 1, 1),
+	)
+	require.NoError(t, err)
+
+	rr, err := c.Query(context.Background(), upstream.Rule{
+		Name: "ya.ru.",
+		Type: dns.TypeA,
+	})
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	require.EqualValues(t, upstream.Rule{
+		Name:     "ya.ru.",
+		Type:     dns.TypeA,
+		Value:    net.ParseIP("1.2.3.3"),
+		ValueStr: "1.2.3.3",
+	}, rr[0])
+
+	tx, err := c.Tx(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Delete(upstream.Rule{
+		Name: "ya.ru.",
+		Type: dns.TypeA,
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:  "ya.ru.",
+		Type:  dns.TypeA,
+		Value: net.ParseIP("1.2.4.5"),
+	}))
+	require.NoError(t, tx.Append(upstream.Rule{
+		Name:     "tmp.ya.ru.",
+		Type:     dns.TypeTXT,
+		Value:    []string{"tmp"},
+		ValueStr: "tmp",
+	}))
+	require.NoError(t, tx.Delete(upstream.Rule{
+		Name: "tmp.ya.ru.",
+		Type: dns.TypeTXT,
+	}))
+	require.NoError(t, tx.Commit(context.Background()))
+
+	expected := []uwebhook.ChangeSetReq{
+		{
+			Deletes: []uwebhook.Record{
+				{Name: "ya.ru.", Type: "A", Value: "1.2.3.3", TTL: 60},
+			},
+			Adds: []uwebhook.Record{
+				{Name: "ya.ru.", Type: "A", Value: "1.2.4.5", TTL: 120},
+			},
+		},
+	}
+	require.EqualValues(t, expected, changes)
+
+	// the retried commit keeps its idempotency key
+	require.Len(t, idempotencyKeys, 2)
+	require.NotEmpty(t, idempotencyKeys[0])
+	require.Equal(t, idempotencyKeys[0], idempotencyKeys[1])
+}
+
+func TestBadSignature(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(xhttp.HeaderSignature) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(uwebhook.ErrorRsp{Message: "unsigned request"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(uwebhook.ListRsp{})
+	}))
+	defer srv.Close()
+
+	c, err := uwebhook.NewUpstream(
+		uwebhook.WithListURL(srv.URL),
+		uwebhook.WithCommitURL(srv.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = c.Query(context.Background(), upstream.Rule{Type: dns.TypeAXFR})
+	require.ErrorContains(t, err, "unsigned request")
+}
+
+func TestConformance(t *testing.T) {
+	upstreamtest.Run(t, newTestUpstream)
+}
+
+func TestConformanceTypes(t *testing.T) {
+	upstreamtest.RunTypes(t, newTestUpstream, upstreamtest.ExtendedTypes...)
+}
+
+func TestConformanceOpaqueTypes(t *testing.T) {
+	upstreamtest.RunRefusedTypes(t, newTestUpstream, upstreamtest.OpaqueTypes...)
+}
+
+func newTestUpstream(t *testing.T) upstream.Upstream {
+	var mu sync.Mutex
+	var records []uwebhook.Record
+
+	var mux http.ServeMux
+	mux.HandleFunc("/records", func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(uwebhook.ListRsp{Records: records})
+	})
+
+	mux.HandleFunc("/commit", func(w http.ResponseWriter, r *http.Request) {
+		var req uwebhook.ChangeSetReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, del := range req.Deletes {
+			for i, rec := range records {
+				if rec == del {
+					records = append(records[:i], records[i+1:]...)
+					break
+				}
+			}
+		}
+		records = append(records, req.Adds...)
+	})
+
+	srv := httptest.NewServer(&mux)
+	t.Cleanup(srv.Close)
+
+	c, err := uwebhook.NewUpstream(
+		uwebhook.WithListURL(srv.URL+"/records"),
+		uwebhook.WithCommitURL(srv.URL+"/commit"),
+	)
+	require.NoError(t, err)
+	return c
+}
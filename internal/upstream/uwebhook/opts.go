@@ -0,0 +1,62 @@
+package uwebhook
+
+import (
+	"time"
+
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+type Option func(*Upstream)
+
+func WithListURL(url string) Option {
+	return func(client *Upstream) {
+		client.listURL = url
+	}
+}
+
+func WithCommitURL(url string) Option {
+	return func(client *Upstream) {
+		client.commitURL = url
+	}
+}
+
+func WithHMACSecret(secret string) Option {
+	return func(client *Upstream) {
+		client.hmacSecret = []byte(secret)
+	}
+}
+
+func WithTTL(ttl uint32) Option {
+	return func(client *Upstream) {
+		if ttl == 0 {
+			return
+		}
+
+		client.ttl = ttl
+	}
+}
+
+func WithRetries(count int, waitTime, maxWaitTime time.Duration) Option {
+	return func(client *Upstream) {
+		client.httpc.SetRetryCount(count)
+		if waitTime > 0 {
+			client.httpc.SetRetryWaitTime(waitTime)
+		}
+
+		if maxWaitTime > 0 {
+			client.httpc.SetRetryMaxWaitTime(maxWaitTime)
+		}
+	}
+}
+
+func WithHeader(name, value string) Option {
+	return func(client *Upstream) {
+		client.httpc.SetHeader(name, value)
+	}
+}
+
+func WithDebug(verbose bool) Option {
+	return func(client *Upstream) {
+		xhttp.SetDebug(client.httpc, verbose)
+	}
+}
@@ -0,0 +1,50 @@
+package uwebhook
+
+import (
+	"strings"
+
+	"github.com/buglloc/DNSGateway/internal/fqdn"
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+type Rule struct {
+	record   Record
+	upRecord upstream.Rule
+	pending  bool
+}
+
+func RuleFromRecord(r Record) (Rule, error) {
+	rType, err := upstream.ParseType(r.Type)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	uRule, err := upstream.NewRule(fqdn.FQDN(r.Name), rType, strings.TrimSpace(r.Value))
+	if err != nil {
+		return Rule{}, err
+	}
+	uRule.Owner = r.Owner
+
+	return Rule{
+		record:   r,
+		upRecord: uRule,
+	}, nil
+}
+
+func RuleFromUpstream(r upstream.Rule, ttl uint32) Rule {
+	return Rule{
+		record: Record{
+			Name:  r.Name,
+			Type:  upstream.TypeString(r.Type),
+			Value: r.ValueStr,
+			TTL:   ttl,
+			Owner: r.Owner,
+		},
+		upRecord: r,
+		pending:  true,
+	}
+}
+
+func (r *Rule) SameUpstream(other upstream.Rule) bool {
+	return r.upRecord.Same(&other)
+}
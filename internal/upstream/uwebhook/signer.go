@@ -0,0 +1,24 @@
+package uwebhook
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+// HeaderIdempotencyKey is the same for all retries of a commit, so the sidecar can skip the already applied one.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+func (c *Upstream) signRequest(req *resty.Request, body []byte) *resty.Request {
+	if len(c.hmacSecret) == 0 {
+		return req
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return req.
+		SetHeader(xhttp.HeaderTimestamp, ts).
+		SetHeader(xhttp.HeaderSignature, xhttp.Sign(c.hmacSecret, ts, body))
+}
@@ -0,0 +1,105 @@
+package uwebhook
+
+import (
+	"fmt"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+type Storage struct {
+	rules    []Rule
+	toDelete []Record
+	toAdd    []Record
+}
+
+func NewStorage(records []Record) (*Storage, error) {
+	var s Storage
+	for _, r := range records {
+		rule, err := RuleFromRecord(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record %s[%s]: %w", r.Name, r.Type, err)
+		}
+
+		s.rules = append(s.rules, rule)
+	}
+
+	return &s, nil
+}
+
+func (s *Storage) Rules() []upstream.Rule {
+	out := make([]upstream.Rule, len(s.rules))
+	for i, r := range s.rules {
+		out[i] = r.upRecord
+	}
+
+	return out
+}
+
+func (s *Storage) Query(q upstream.Rule) []upstream.Rule {
+	var out []upstream.Rule
+	for _, rule := range s.rules {
+		if !rule.SameUpstream(q) {
+			continue
+		}
+
+		out = append(out, rule.upRecord)
+	}
+
+	return out
+}
+
+func (s *Storage) Delete(q upstream.Rule) []upstream.Rule {
+	n := 0
+	var deleted []upstream.Rule
+	for _, rule := range s.rules {
+		if rule.SameUpstream(q) {
+			deleted = append(deleted, rule.upRecord)
+			if rule.pending {
+				s.dropPending(rule.record)
+			} else {
+				s.toDelete = append(s.toDelete, rule.record)
+			}
+			continue
+		}
+
+		s.rules[n] = rule
+		n++
+	}
+
+	s.rules = s.rules[:n]
+	return deleted
+}
+
+func (s *Storage) Append(r upstream.Rule, ttl uint32) {
+	rule := RuleFromUpstream(r, ttl)
+	s.rules = append(s.rules, rule)
+	s.toAdd = append(s.toAdd, rule.record)
+}
+
+func (s *Storage) ChangeSet() ChangeSetReq {
+	return ChangeSetReq{
+		Deletes: nonNilRecords(s.toDelete),
+		Adds:    nonNilRecords(s.toAdd),
+	}
+}
+
+func (s *Storage) Changed() bool {
+	return len(s.toDelete) > 0 || len(s.toAdd) > 0
+}
+
+func (s *Storage) dropPending(r Record) {
+	for i, rec := range s.toAdd {
+		if rec == r {
+			s.toAdd = append(s.toAdd[:i], s.toAdd[i+1:]...)
+			return
+		}
+	}
+}
+
+func nonNilRecords(in []Record) []Record {
+	if in == nil {
+		return []Record{}
+	}
+
+	return in
+}
@@ -0,0 +1,78 @@
+package uwebhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buglloc/DNSGateway/internal/upstream"
+)
+
+var (
+	_ upstream.Tx     = (*Tx)(nil)
+	_ upstream.Differ = (*Tx)(nil)
+)
+
+type Tx struct {
+	upc   *Upstream
+	store *Storage
+}
+
+func (t *Tx) Delete(r upstream.Rule) error {
+	_ = t.store.Delete(r)
+	return nil
+}
+
+func (t *Tx) Append(r upstream.Rule) error {
+	if !r.Scope.IsZero() {
+		return upstream.ErrScopeUnsupported
+	}
+
+	if r.IsNegative() {
+		return upstream.ErrNegativeUnsupported
+	}
+
+	if r.IsOpaque() {
+		return fmt.Errorf("%w: %s", upstream.ErrOpaqueUnsupported, upstream.TypeString(r.Type))
+	}
+
+	if r.ValueStr == "" {
+		r.ValueStr = fmt.Sprint(r.Value)
+	}
+
+	t.store.Append(r, t.upc.ttl)
+	return nil
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	if !t.store.Changed() {
+		return nil
+	}
+
+	changes := t.store.ChangeSet()
+	if err := t.upc.commit(ctx, changes); err != nil {
+		return err
+	}
+
+	t.upc.log.Info().
+		Int("deletes", len(changes.Deletes)).
+		Int("adds", len(changes.Adds)).
+		Msg("change-set committed")
+	return nil
+}
+
+// Diff returns the change-set to be sent to the commit endpoint.
+func (t *Tx) Diff(_ context.Context) ([]string, error) {
+	changes := t.store.ChangeSet()
+	out := make([]string, 0, len(changes.Deletes)+len(changes.Adds))
+	for _, r := range changes.Deletes {
+		out = append(out, "- "+r.String())
+	}
+
+	for _, r := range changes.Adds {
+		out = append(out, "+ "+r.String())
+	}
+
+	return out, nil
+}
+
+func (t *Tx) Close() {}
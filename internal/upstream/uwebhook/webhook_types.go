@@ -0,0 +1,37 @@
+package uwebhook
+
+import (
+	"fmt"
+)
+
+// Record is a single DNS record as seen by the webhook sidecar.
+// The JSON schema is documented in docs/webhook.md.
+type Record struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   uint32 `json:"ttl,omitempty"`
+	Owner string `json:"owner,omitempty"`
+}
+
+func (r Record) String() string {
+	out := fmt.Sprintf("%s %s %s", r.Name, r.Type, r.Value)
+	if r.Owner != "" {
+		out += " ; owner=" + r.Owner
+	}
+
+	return out
+}
+
+type ListRsp struct {
+	Records []Record `json:"records"`
+}
+
+type ChangeSetReq struct {
+	Deletes []Record `json:"deletes"`
+	Adds    []Record `json:"adds"`
+}
+
+type ErrorRsp struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,63 @@
+package xhttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/buglloc/DNSGateway/internal/secret"
+)
+
+// sensitiveHeaders are the parts of header names, which values are redacted in debug logs.
+var sensitiveHeaders = []string{
+	"authorization",
+	"cookie",
+	"token",
+	"secret",
+	"signature",
+	"api-key",
+	"apikey",
+	"password",
+}
+
+// SetDebug toggles the resty debug logging, credentials in headers are redacted.
+func SetDebug(c *resty.Client, verbose bool) {
+	c.SetDebug(verbose)
+	if !verbose {
+		return
+	}
+
+	c.OnRequestLog(func(rl *resty.RequestLog) error {
+		RedactHeaders(rl.Header)
+		return nil
+	})
+	c.OnResponseLog(func(rl *resty.ResponseLog) error {
+		RedactHeaders(rl.Header)
+		return nil
+	})
+}
+
+// RedactHeaders replaces values of headers carrying credentials in place.
+func RedactHeaders(h http.Header) {
+	for name, values := range h {
+		if !IsSensitiveHeader(name) {
+			continue
+		}
+
+		for i := range values {
+			values[i] = secret.Redacted
+		}
+	}
+}
+
+func IsSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveHeaders {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,25 @@
+package xhttp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/DNSGateway/internal/secret"
+	"github.com/buglloc/DNSGateway/internal/xhttp"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Basic dXNlcjpwYXNz")
+	h.Set("X-Auth-Token", "token")
+	h.Set("X-DNSGateway-Signature", "sig")
+	h.Set("Content-Type", "application/json")
+
+	xhttp.RedactHeaders(h)
+	require.Equal(t, secret.Redacted, h.Get("Authorization"))
+	require.Equal(t, secret.Redacted, h.Get("X-Auth-Token"))
+	require.Equal(t, secret.Redacted, h.Get("X-DNSGateway-Signature"))
+	require.Equal(t, "application/json", h.Get("Content-Type"))
+}
@@ -0,0 +1,22 @@
+package xhttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Headers of the signed requests, which DNSGateway sends to webhooks.
+const (
+	HeaderTimestamp = "X-DNSGateway-Timestamp"
+	HeaderSignature = "X-DNSGateway-Signature"
+)
+
+// Sign computes the request signature: hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(timestamp))
+	_, _ = mac.Write([]byte{'.'})
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
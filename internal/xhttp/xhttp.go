@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/buglloc/certifi"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
@@ -32,7 +33,8 @@ func NewTransport() http.RoundTripper {
 		Timeout:   dialTimeout,
 		KeepAlive: keepAlive,
 	}).DialContext
-	return transport
+	// spans are dropped unless tracing is configured
+	return otelhttp.NewTransport(transport)
 }
 
 func NewTLSClientConfig() *tls.Config {